@@ -0,0 +1,1043 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Coffee4Coffee/GoBackup/scheduler"
+)
+
+// schedulerEnv chooses the scheduler by name, see scheduler.Open
+const schedulerEnv = "GOBACKUP_SCHEDULER"
+
+const cliUsage = `Usage: GoBackup <command> [flags]
+
+Commands:
+  list    [-filter <text>] [-json]                         list the scheduled backups
+  create  -src <dir> -dest <dir> [schedule flags] [-force] [-json]
+                                                           schedule a backup
+  edit    -job <job> [-src <dir>] [-dest <dir>] [schedule flags] [-json]
+                                                           change a scheduled backup, flags that are not given keep their value
+  delete  -job <job>                                       delete a scheduled backup
+  run     -job <job> [-scheduler <name>]                   run a scheduled backup now in this process
+  once    -src <dir> -dest <dir> [-limit <n>] [-overwrite]  back up a folder once without scheduling it
+  enable  -job <job>                                       enable a scheduled backup
+  disable -job <job>                                       disable a scheduled backup
+  history [-job <job>] [-filter <text>] [-n <n>] [-json]   list the recorded runs, newest first
+  plan    -file <config> [-delete-unreadable] [-json]      show what apply would change
+  apply   -file <config> [-delete-unreadable] [-yes]       create, update and delete scheduled backups to match a YAML config
+  export  [-out <file>]                                    write all scheduled backups to a portable YAML file
+  import  -file <file> [-map <from=to>]... [-preview] [-force] [-json]
+                                                           schedule the backups of an exported file, with remapped paths
+  pause   -until <YYYY-MM-DD>                              pause all scheduled backups until the given date
+  resume                                                   resume all paused backups
+  daemon                                                   run the backups of the daemon scheduler until stopped
+  xml     -job <job> [-out <file>]                         write the task scheduler XML of a scheduled backup
+  xml     -import <file>                                   schedule a backup from a task scheduler XML
+  script  -job <job> [-out <file>]                         write a standalone sh script that runs a scheduled backup once
+
+Schedule flags:
+  -label <text>                 label of the backup, the name of the src folder by default
+  -trigger <type>               daily, weekly, monthly or custom
+  -at <HH:MM>                   start time
+  -weekdays <list>              weekdays of a weekly backup or of the weeks of a monthly one, e.g. mon,wed or mon-fri
+  -monthdays <list>             days of a monthly backup, e.g. 1,15,L where L is the last day
+  -weeks <list>                 weeks of a monthly backup on weekdays, e.g. 1,L for the first and the last week
+  -expr <cron expression>       schedule of a custom backup, e.g. "*/30 9-17 * * 1-5"
+  -every <duration>             repeat the backup every given minutes or duration, e.g. 30 or 2h, 0 does not repeat
+  -until <HH:MM>                last repetition of the day
+  -jitter <minutes>             random delay of every run
+  -tz <zone>                    IANA time zone of the schedule, local time when empty
+  -limit <n>                    number of backups to keep, 0 keeps all of them
+  -overwrite                    overwrite the previous backup instead of creating a timestamped one
+  -rotate <dir>                 another drive of a rotation, can be repeated, -rotate "" removes the rotation
+  -alert <days>                 alert when a drive of the rotation has not been backed up to for the given days
+
+A job is given by its ID, its task name or, if it is unique, its label.
+Paths can contain placeholders such as {Documents}, {Pictures}, {Home} or %USERPROFILE%, which are resolved when the backup runs.
+A path on a removable drive can start with {Label:<label>} or {UUID:<uuid>} instead of its drive letter or mount point.
+A backup with a rotation goes to the first of -dest and the -rotate drives that is connected, -limit applies to every drive.
+Set GOBACKUP_SCHEDULER to daemon, taskscheduler, systemd, cron or launchd to use another scheduler than the one of the platform.
+Start GoBackup without a command to open the window.
+`
+
+// runCli handles the command line mode and returns the exit code of the application
+func runCli(args []string) int {
+	// The schedules are described with the options of the form
+	initializeOptions()
+	var err error
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	case "list":
+		err = runCliList(args[1:])
+	case "create":
+		err = runCliCreate(args[1:])
+	case "edit":
+		err = runCliEdit(args[1:])
+	case "delete":
+		err = runCliDelete(args[1:])
+	case "history":
+		err = runCliHistory(args[1:])
+	case "plan":
+		err = runCliPlan(args[1:], false)
+	case "apply":
+		err = runCliPlan(args[1:], true)
+	case "export":
+		err = runCliExport(args[1:])
+	case "import":
+		err = runCliImport(args[1:])
+	case "run":
+		err = runCliRun(args[1:])
+	case "once":
+		err = runCliOnce(args[1:])
+	case "enable":
+		err = runCliSetEnabled(args[1:], true)
+	case "disable":
+		err = runCliSetEnabled(args[1:], false)
+	case "pause":
+		err = runCliPause(args[1:])
+	case "resume":
+		err = scheduler.ResumeAll(backupScheduler)
+	case "daemon":
+		err = runCliDaemon(args[1:])
+	case "xml":
+		err = runCliXML(args[1:])
+	case "script":
+		err = runCliScript(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// cliTask is a scheduled backup in the JSON output of the command line
+type cliTask struct {
+	Name        string        `json:"name"`
+	Job         scheduler.Job `json:"job"`
+	Schedule    string        `json:"scheduleText"`
+	Enabled     bool          `json:"enabled"`
+	State       string        `json:"state"`
+	NextRunTime time.Time     `json:"nextRunTime"`
+	LastRunTime time.Time     `json:"lastRunTime"`
+	MissedRuns  uint          `json:"missedRuns"`
+	LastResult  string        `json:"lastResult"`
+	Expires     time.Time     `json:"expires"`
+	// ResolvedSrc and ResolvedDest are the paths with their placeholders expanded, they are left out without placeholders
+	ResolvedSrc  string `json:"resolvedSrc,omitempty"`
+	ResolvedDest string `json:"resolvedDest,omitempty"`
+	// Drives are the drives of a rotation with their last successful backup, only list fills them in
+	Drives []scheduler.DriveStatus `json:"drives,omitempty"`
+	Err    string                  `json:"error,omitempty"`
+}
+
+func newCliTask(task scheduler.Task) cliTask {
+	t := cliTask{
+		Name:        task.Name,
+		Job:         task.Job,
+		Enabled:     task.Enabled,
+		State:       getTaskState(task),
+		NextRunTime: task.NextRunTime,
+		LastRunTime: task.LastRunTime,
+		MissedRuns:  task.MissedRuns,
+		LastResult:  task.LastResult,
+		Expires:     task.Expires,
+	}
+	if task.Err != nil {
+		t.Err = task.Err.Error()
+	} else {
+		t.Schedule = getTriggerIntervalType(task.Job.Schedule)
+		t.ResolvedSrc, t.ResolvedDest = resolvedPath(task.Job.Src), resolvedPath(task.Job.Dest)
+	}
+	return t
+}
+
+// resolvedPath returns the expanded path of a path with placeholders, it is empty without or when they cannot be expanded
+func resolvedPath(path string) string {
+	if !scheduler.HasPlaceholders(path) {
+		return ""
+	}
+	resolved, _ := scheduler.ExpandPath(path)
+	return resolved
+}
+
+// writeJSON writes the JSON output of a command
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// containsFold reports whether any of the values contains the filter, ignoring case
+func containsFold(filter string, values ...string) bool {
+	filter = strings.ToLower(filter)
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+func runCliList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	filter := fs.String("filter", "", "only list the backups whose label, task name, src or dest contain the text")
+	asJSON := fs.Bool("json", false, "write the backups as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	tasks, err := backupScheduler.List()
+	if err != nil {
+		return err
+	}
+	// Without a history the drives of a rotation were never backed up to
+	history, _ := scheduler.ReadHistory()
+	list := []cliTask{}
+	for _, task := range tasks {
+		if containsFold(*filter, task.Job.Label, task.Name, task.Job.Src, task.Job.Dest) {
+			t := newCliTask(task)
+			t.Drives = scheduler.RotationStatus(task.Job, history, time.Now())
+			list = append(list, t)
+		}
+	}
+	if *asJSON {
+		return writeJSON(list)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tLABEL\tSCHEDULE\tSTATE\tNEXT RUN\tSRC\tDEST")
+	for _, t := range list {
+		if len(t.Err) > 0 {
+			fmt.Fprintf(w, "?\t%v\t?\tUnreadable: %v\t\t\t\n", t.Name, t.Err)
+			continue
+		}
+		dest := formatPath(t.Job.Dest)
+		if len(t.Drives) > 0 {
+			drives := make([]string, len(t.Drives))
+			for i, drive := range t.Drives {
+				drives[i] = formatDrive(drive)
+			}
+			dest = strings.Join(drives, "; ")
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", t.Job.ID, t.Job.Label, t.Schedule, t.State, formatRunTime(t.NextRunTime), formatPath(t.Job.Src), dest)
+	}
+	return w.Flush()
+}
+
+func formatRunTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// jobFlags are the flags of create and edit that set the fields of a job
+type jobFlags struct {
+	fs                                                                                *flag.FlagSet
+	src, dest, label, trigger, at, weekdays, monthdays, weeks, expr, every, until, tz *string
+	jitter, limit, alert                                                              *uint
+	overwrite                                                                         *bool
+	rotation                                                                          *destList
+}
+
+// destList collects the repeated -rotate flags, an empty value clears the list
+type destList []string
+
+func (l *destList) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *destList) Set(v string) error {
+	if len(v) == 0 {
+		*l = nil
+		return nil
+	}
+	*l = append(*l, v)
+	return nil
+}
+
+func newJobFlags(fs *flag.FlagSet) *jobFlags {
+	rotation := &destList{}
+	fs.Var(rotation, "rotate", "another drive of a rotation, can be given several times")
+	return &jobFlags{
+		rotation:  rotation,
+		fs:        fs,
+		src:       fs.String("src", "", "folder to back up"),
+		dest:      fs.String("dest", "", "destination of the backup"),
+		label:     fs.String("label", "", "label of the backup"),
+		trigger:   fs.String("trigger", "daily", "daily, weekly, monthly or custom"),
+		at:        fs.String("at", "00:00", "start time in the format HH:MM"),
+		weekdays:  fs.String("weekdays", "", "weekdays such as mon,wed or mon-fri"),
+		monthdays: fs.String("monthdays", "", "days of the month such as 1,15,L"),
+		weeks:     fs.String("weeks", "", "weeks of the month such as 1,L"),
+		expr:      fs.String("expr", "", "cron expression of a custom schedule"),
+		every:     fs.String("every", "", "repeat every given minutes or duration such as 30 or 2h"),
+		until:     fs.String("until", "", "last repetition of the day in the format HH:MM"),
+		tz:        fs.String("tz", "", "IANA time zone of the schedule"),
+		jitter:    fs.Uint("jitter", 0, "random delay of every run in minutes"),
+		limit:     fs.Uint("limit", 0, "number of backups to keep, 0 keeps all of them"),
+		alert:     fs.Uint("alert", 0, "alert when a drive of the rotation has not been backed up to for the given days"),
+		overwrite: fs.Bool("overwrite", false, "overwrite the previous backup instead of creating a timestamped one"),
+	}
+}
+
+// apply sets the fields of the given flags, the other fields of the job are left as they are
+func (f *jobFlags) apply(job *scheduler.Job) error {
+	set := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	s := &job.Schedule
+
+	if set["src"] {
+		job.Src = *f.src
+	}
+	if set["dest"] {
+		job.Dest = *f.dest
+	}
+	if set["label"] {
+		job.Label = *f.label
+	}
+	if set["rotate"] {
+		job.Rotation = *f.rotation
+		if len(job.Rotation) == 0 {
+			job.AlertAfterDays = 0
+		}
+	}
+	if set["alert"] {
+		if *f.alert >= 1<<16 {
+			return fmt.Errorf("invalid -alert: too long")
+		}
+		job.AlertAfterDays = uint16(*f.alert)
+	}
+	if job.AlertAfterDays > 0 && len(job.Rotation) == 0 {
+		return fmt.Errorf("-alert needs -rotate")
+	}
+	if set["limit"] {
+		if *f.limit > 10 {
+			return fmt.Errorf("-limit must be between 0 and 10")
+		}
+		job.BackupLimit = uint8(*f.limit)
+	}
+	if set["overwrite"] {
+		job.Overwrite = *f.overwrite
+	}
+	if set["trigger"] {
+		if err := s.Type.UnmarshalText([]byte(*f.trigger)); err != nil {
+			return fmt.Errorf("invalid -trigger: %w", err)
+		}
+	}
+	if set["expr"] {
+		s.Expr = *f.expr
+		if !set["trigger"] {
+			s.Type = scheduler.Custom
+		}
+	}
+	if set["at"] {
+		minutes, err := scheduler.ParseClock(*f.at)
+		if err != nil {
+			return fmt.Errorf("invalid -at: %w", err)
+		}
+		s.Hour, s.Minute = uint8(minutes/60), uint8(minutes%60)
+	}
+	if set["trigger"] || set["weekdays"] || set["monthdays"] || set["weeks"] {
+		weekdays, days, weeks := s.Days()
+		var err error
+		if set["weekdays"] {
+			if weekdays, err = scheduler.ParseWeekdays(*f.weekdays); err != nil {
+				return fmt.Errorf("invalid -weekdays: %w", err)
+			}
+		}
+		if set["monthdays"] {
+			if days, err = scheduler.ParseDaysOfMonth(*f.monthdays); err != nil {
+				return fmt.Errorf("invalid -monthdays: %w", err)
+			}
+			// Days of the month replace the weeks of a monthly backup on weekdays
+			weeks = 0
+		}
+		if set["weeks"] {
+			if weeks, err = scheduler.ParseWeeksOfMonth(*f.weeks); err != nil {
+				return fmt.Errorf("invalid -weeks: %w", err)
+			}
+		}
+		s.SetDays(weekdays, days, weeks)
+	}
+	if set["every"] {
+		every, err := scheduler.ParseMinutes(*f.every)
+		if err != nil {
+			return fmt.Errorf("invalid -every: %w", err)
+		}
+		s.RepeatEvery = every
+		if every == 0 {
+			s.RepeatUntil = 0
+		}
+	}
+	if set["until"] {
+		minutes, err := scheduler.ParseClock(*f.until)
+		if err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+		s.RepeatUntil = uint16(minutes)
+	}
+	if s.RepeatEvery > 0 && s.RepeatUntil == 0 {
+		return fmt.Errorf("-every needs -until")
+	}
+	if set["jitter"] {
+		if *f.jitter >= 1<<16 {
+			return fmt.Errorf("invalid -jitter: too long")
+		}
+		s.Jitter = uint16(*f.jitter)
+	}
+	if set["tz"] {
+		s.TimeZone = strings.TrimSpace(*f.tz)
+	}
+	return nil
+}
+
+// checkJob validates a job the way the form does before it is scheduled
+func checkJob(job scheduler.Job) error {
+	if len(job.Src) == 0 || len(job.Dest) == 0 {
+		return fmt.Errorf("-src and -dest are required")
+	}
+	for i, dir := range append([]string{job.Src}, job.Dests()...) {
+		resolved, err := scheduler.ExpandPath(dir)
+		var volumeErr *scheduler.ErrVolumeNotFound
+		if errors.As(err, &volumeErr) {
+			// A removable drive is checked when the backup runs
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if i > 0 && len(job.Rotation) > 0 {
+			// The drives of a rotation may be offsite
+			continue
+		}
+		if _, err := os.Stat(resolved); os.IsNotExist(err) {
+			return fmt.Errorf("directory %v does not exist", resolved)
+		}
+	}
+	if err := job.Schedule.Validate(); err != nil {
+		return fmt.Errorf("the schedule is not valid: %w", err)
+	}
+	return nil
+}
+
+// writeTask reports a created or edited backup
+func writeTask(verb string, task scheduler.Task, asJSON bool) error {
+	if asJSON {
+		return writeJSON(newCliTask(task))
+	}
+	fmt.Printf("%v %v (%v): %v\n", verb, task.Job.Label, task.Job.ID, getTriggerIntervalType(task.Job.Schedule))
+	return nil
+}
+
+func runCliCreate(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	jf := newJobFlags(fs)
+	force := fs.Bool("force", false, "create the backup even if the same one already exists")
+	asJSON := fs.Bool("json", false, "write the created backup as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	job := scheduler.NewJob(0, *jf.src, *jf.dest, false)
+	if err := jf.apply(&job); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	if err := checkJob(job); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	if !*force {
+		tasks, err := backupScheduler.List()
+		if err != nil {
+			return err
+		}
+		if duplicates := scheduler.FindDuplicates(tasks, job); len(duplicates) > 0 {
+			names := make([]string, len(duplicates))
+			for i, task := range duplicates {
+				names[i] = task.Name
+			}
+			return fmt.Errorf("create: the same backup already exists as %v, use -force to create another one", strings.Join(names, ", "))
+		}
+	}
+	task, err := backupScheduler.Create(job)
+	if err != nil {
+		return err
+	}
+	return writeTask("Created", task, *asJSON)
+}
+
+func runCliEdit(args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	ref := fs.String("job", "", "ID, task name or label of the scheduled backup")
+	jf := newJobFlags(fs)
+	asJSON := fs.Bool("json", false, "write the edited backup as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*ref) == 0 {
+		return fmt.Errorf("edit: -job is required")
+	}
+	task, err := scheduler.Find(backupScheduler, *ref)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &scheduler.ErrParseTaskFailure{Inner: task.Err, Message: "failed to read task " + task.Name}
+	}
+	// The edited job keeps its identity and pause
+	job := task.Job
+	if err := jf.apply(&job); err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+	if err := checkJob(job); err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+	task, err = backupScheduler.Update(job)
+	if err != nil {
+		return err
+	}
+	return writeTask("Saved", task, *asJSON)
+}
+
+func runCliDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	ref := fs.String("job", "", "ID, task name or label of the scheduled backup")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*ref) == 0 {
+		return fmt.Errorf("delete: -job is required")
+	}
+	task, err := scheduler.Find(backupScheduler, *ref)
+	if err != nil {
+		return err
+	}
+	// Unreadable tasks have no job ID, the scheduler also finds them by name
+	id := task.Job.ID
+	if task.Err != nil {
+		id = task.Name
+	}
+	return backupScheduler.Delete(id)
+}
+
+func runCliHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	ref := fs.String("job", "", "only list the runs of the scheduled backup with this ID, task name or label")
+	filter := fs.String("filter", "", "only list the runs whose src, dest or message contain the text")
+	n := fs.Int("n", 0, "number of runs to list, 0 lists all of them")
+	asJSON := fs.Bool("json", false, "write the runs as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var job scheduler.Job
+	if len(*ref) > 0 {
+		task, err := scheduler.Find(backupScheduler, *ref)
+		if err != nil {
+			return err
+		}
+		job = task.Job
+	}
+	history, err := scheduler.ReadHistory()
+	if err != nil {
+		return err
+	}
+	entries := []scheduler.HistoryEntry{}
+	for _, entry := range history {
+		// Runs of older versions were recorded without the job ID
+		if len(*ref) > 0 && entry.JobID != job.ID && (len(entry.JobID) > 0 || entry.Src != job.Src || entry.Dest != job.Dest) {
+			continue
+		}
+		if !containsFold(*filter, entry.Src, entry.Dest, entry.Message) {
+			continue
+		}
+		if *n > 0 && len(entries) == *n {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	if *asJSON {
+		return writeJSON(entries)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tRESULT\tSRC\tDEST\tMESSAGE")
+	for _, entry := range entries {
+		result := "Success"
+		if entry.Skipped() {
+			result = "Skipped"
+		} else if !entry.Success() {
+			result = "Failed (" + strconv.Itoa(entry.ExitCode) + ")"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", entry.Time.Format("2006-01-02 15:04:05"), result, entry.Src, entry.Dest, entry.Message)
+	}
+	return w.Flush()
+}
+
+// cliChange is a change of plan in the JSON output of the command line
+type cliChange struct {
+	Action string                  `json:"action"`
+	Name   string                  `json:"name"`
+	JobID  string                  `json:"jobId,omitempty"`
+	Diff   []scheduler.FieldChange `json:"diff"`
+}
+
+// runCliPlan shows the changes that make the scheduled backups match a config file and, for apply, makes them
+func runCliPlan(args []string, apply bool) error {
+	name := "plan"
+	if apply {
+		name = "apply"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("file", "", "YAML config of all backups of this machine")
+	deleteUnreadable := fs.Bool("delete-unreadable", false, "also delete the scheduled backups that cannot be read")
+	asJSON, yes := false, false
+	if apply {
+		fs.BoolVar(&yes, "yes", false, "delete the scheduled backups that are not in the config")
+	} else {
+		fs.BoolVar(&asJSON, "json", false, "write the changes as JSON")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*file) == 0 {
+		return fmt.Errorf("%v: -file is required", name)
+	}
+	b, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	config, err := scheduler.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	tasks, err := backupScheduler.List()
+	if err != nil {
+		return err
+	}
+	changes, err := scheduler.PlanConfig(config, tasks, *deleteUnreadable)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		list := []cliChange{}
+		for _, c := range changes {
+			// A created backup only gets its ID when it is applied
+			list = append(list, cliChange{Action: c.Action, Name: c.Name, JobID: c.Task.Job.ID, Diff: c.Diff})
+		}
+		return writeJSON(list)
+	}
+	if !*deleteUnreadable {
+		for _, task := range tasks {
+			if task.Err != nil {
+				fmt.Printf("Keeping %v, it cannot be read: %v. Use -delete-unreadable to delete it.\n", task.Name, task.Err)
+			}
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Println("The scheduled backups match the config.")
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if !apply {
+		return nil
+	}
+	// Nothing is changed before the deletes are confirmed
+	deletes := 0
+	for _, c := range changes {
+		if c.Action == scheduler.ChangeDelete {
+			deletes++
+		}
+	}
+	if deletes > 0 && !yes {
+		return fmt.Errorf("apply: %v scheduled backups are not in the config, run apply again with -yes to delete them", deletes)
+	}
+	for _, c := range changes {
+		if err := scheduler.ApplyChange(backupScheduler, c); err != nil {
+			return fmt.Errorf("apply: failed to %v %v: %w", c.Action, c.Name, err)
+		}
+	}
+	return nil
+}
+
+func runCliExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("out", "", "file to write the backups to, standard output when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	tasks, err := backupScheduler.List()
+	if err != nil {
+		return err
+	}
+	// Without a home folder the user profile paths are simply not remapped on import
+	home, _ := os.UserHomeDir()
+	b, err := scheduler.MarshalConfig(scheduler.ExportConfig(tasks, home))
+	if err != nil {
+		return err
+	}
+	if len(*out) == 0 {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(*out, b, 0o644)
+}
+
+// pathMappings collects the repeated -map flags of import
+type pathMappings []scheduler.PathMapping
+
+func (m *pathMappings) String() string {
+	return fmt.Sprint(*m)
+}
+
+func (m *pathMappings) Set(v string) error {
+	mapping, err := scheduler.ParsePathMapping(v)
+	if err != nil {
+		return err
+	}
+	*m = append(*m, mapping)
+	return nil
+}
+
+// cliImportItem is a backup of an imported file in the JSON output of the command line
+type cliImportItem struct {
+	Name         string   `json:"name"`
+	OriginalSrc  string   `json:"originalSrc"`
+	OriginalDest string   `json:"originalDest"`
+	Src          string   `json:"src"`
+	Dest         string   `json:"dest"`
+	Problems     []string `json:"problems"`
+	Duplicates   []string `json:"duplicates"`
+	Ready        bool     `json:"ready"`
+}
+
+// formatImportItem writes a backup of an imported file the way the preview shows it, with its remapped paths and problems
+func formatImportItem(item scheduler.ImportItem, force bool) string {
+	sign, action := "+", "import"
+	if !item.Ready(force) {
+		sign, action = "!", "skip"
+	}
+	lines := []string{fmt.Sprintf("%v %v %v", sign, action, item.Backup.Name)}
+	for _, path := range [][3]string{{"src", item.Backup.Src, item.Job.Src}, {"dest", item.Backup.Dest, item.Job.Dest}} {
+		if path[1] == path[2] {
+			lines = append(lines, fmt.Sprintf("    %v: %v", path[0], path[1]))
+		} else {
+			lines = append(lines, fmt.Sprintf("    %v: %v -> %v", path[0], path[1], path[2]))
+		}
+	}
+	for _, problem := range item.Problems {
+		lines = append(lines, "    "+problem)
+	}
+	for _, task := range item.Duplicates {
+		lines = append(lines, "    already scheduled as "+task.Name)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func runCliImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("file", "", "YAML file written by export")
+	var mappings pathMappings
+	fs.Var(&mappings, "map", "replace the start of the paths, such as E:=F:, can be repeated")
+	preview := fs.Bool("preview", false, "only show what would be imported")
+	force := fs.Bool("force", false, "also import backups whose src or dest does not exist")
+	asJSON := fs.Bool("json", false, "write the backups as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*file) == 0 {
+		return fmt.Errorf("import: -file is required")
+	}
+	b, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	config, err := scheduler.ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	tasks, err := backupScheduler.List()
+	if err != nil {
+		return err
+	}
+	home, _ := os.UserHomeDir()
+	items, err := scheduler.PlanImport(config, mappings, home, tasks)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		list := []cliImportItem{}
+		for _, item := range items {
+			duplicates := []string{}
+			for _, task := range item.Duplicates {
+				duplicates = append(duplicates, task.Name)
+			}
+			problems := append([]string{}, item.Problems...)
+			list = append(list, cliImportItem{
+				Name:         item.Backup.Name,
+				OriginalSrc:  item.Backup.Src,
+				OriginalDest: item.Backup.Dest,
+				Src:          item.Job.Src,
+				Dest:         item.Job.Dest,
+				Problems:     problems,
+				Duplicates:   duplicates,
+				Ready:        item.Ready(*force),
+			})
+		}
+		if err := writeJSON(list); err != nil {
+			return err
+		}
+	} else {
+		for _, item := range items {
+			fmt.Println(formatImportItem(item, *force))
+		}
+	}
+	if *preview {
+		return nil
+	}
+	n, err := scheduler.ImportJobs(backupScheduler, items, *force)
+	if err != nil {
+		return err
+	}
+	if !*asJSON {
+		fmt.Printf("Imported %v of %v backups.\n", n, len(items))
+	}
+	return nil
+}
+
+func runCliRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	job := fs.String("job", "", "ID, task name or label of the scheduled backup")
+	name := fs.String("scheduler", "", "scheduler of the backup instead of the one chosen by "+schedulerEnv+", used by the generated commands")
+	scheduled := fs.Bool("scheduled", false, "skip the run while the backup is paused, used by the crontab entries and systemd units")
+	jitter := fs.Bool("jitter", false, "wait for the random delay of the scheduled run first, used by the crontab entries and launch agents")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*job) == 0 {
+		return fmt.Errorf("run: -job is required")
+	}
+	// The generated commands name their scheduler, the environment of a scheduled run does not set the variable
+	s := backupScheduler
+	if len(*name) > 0 {
+		var err error
+		if s, err = scheduler.Open(*name); err != nil {
+			return err
+		}
+	}
+	task, err := scheduler.Find(s, *job)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &scheduler.ErrParseTaskFailure{Inner: task.Err, Message: "failed to read task " + task.Name}
+	}
+	if *scheduled && task.Paused(time.Now()) {
+		return nil
+	}
+	if *jitter {
+		time.Sleep(scheduler.JitterDelay(task.Job, time.Now()))
+	}
+	// The schedulers start this command as the action of a task, so the job runs here instead of starting its task again.
+	// The daemon records the run in its state.
+	if daemon, ok := s.(*scheduler.DaemonScheduler); ok {
+		err = daemon.Run(task.Job.ID)
+	} else {
+		err = scheduler.RunJob(task.Job, time.Now())
+	}
+	// A run skipped for a volume that is not connected is recorded in the history and does not fail the task
+	var volumeErr *scheduler.ErrVolumeNotFound
+	if errors.As(err, &volumeErr) {
+		fmt.Fprintln(os.Stderr, "Skipped: the "+volumeErr.Message)
+		err = nil
+	}
+	// Every run renews its own task, so a backup that keeps running never expires
+	if _, renewErr := scheduler.Renew(s, task, time.Now()); err == nil {
+		err = renewErr
+	}
+	return err
+}
+
+func runCliOnce(args []string) error {
+	fs := flag.NewFlagSet("once", flag.ContinueOnError)
+	src := fs.String("src", "", "folder to back up")
+	dest := fs.String("dest", "", "destination of the backup")
+	limit := fs.Uint("limit", 0, "number of backups to keep, 0 keeps all of them")
+	overwrite := fs.Bool("overwrite", false, "overwrite the previous backup instead of creating a timestamped one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*src) == 0 || len(*dest) == 0 {
+		return fmt.Errorf("once: -src and -dest are required")
+	}
+	if *limit > 10 {
+		return fmt.Errorf("once: -limit must be between 0 and 10")
+	}
+	// The placeholders of the folders are expanded before they are checked, as the engine does
+	job := scheduler.NewJob(uint8(*limit), *src, *dest, *overwrite)
+	if err := checkJob(job); err != nil {
+		return fmt.Errorf("once: %w", err)
+	}
+	return scheduler.RunBackupOnce(job)
+}
+
+func runCliSetEnabled(args []string, enabled bool) error {
+	name := "disable"
+	if enabled {
+		name = "enable"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	job := fs.String("job", "", "ID, task name or label of the scheduled backup")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*job) == 0 {
+		return fmt.Errorf("%v: -job is required", name)
+	}
+	task, err := scheduler.Find(backupScheduler, *job)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &scheduler.ErrParseTaskFailure{Inner: task.Err, Message: "failed to read task " + task.Name}
+	}
+	if enabled {
+		return backupScheduler.Enable(task.Job.ID)
+	}
+	return backupScheduler.Disable(task.Job.ID)
+}
+
+func runCliPause(args []string) error {
+	fs := flag.NewFlagSet("pause", flag.ContinueOnError)
+	until := fs.String("until", "", "date in the format YYYY-MM-DD on which the backups resume")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	untilDate, err := time.ParseInLocation("2006-01-02", *until, time.Local)
+	if err != nil {
+		return fmt.Errorf("pause: invalid -until date: %w", err)
+	}
+	if !untilDate.After(time.Now()) {
+		return fmt.Errorf("pause: -until must be in the future")
+	}
+	return scheduler.PauseAll(backupScheduler, untilDate)
+}
+
+func runCliDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, ok := backupScheduler.(*scheduler.DaemonScheduler); !ok {
+		fmt.Fprintf(os.Stderr, "daemon: set %v=daemon for the app and the other commands to manage the jobs of the daemon\n", schedulerEnv)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return scheduler.RunDaemon(ctx, &scheduler.DaemonScheduler{})
+}
+
+func runCliXML(args []string) error {
+	fs := flag.NewFlagSet("xml", flag.ContinueOnError)
+	job := fs.String("job", "", "ID, task name or label of the scheduled backup to export")
+	out := fs.String("out", "", "file to write the XML to, standard output when empty")
+	importFile := fs.String("import", "", "task scheduler XML file to schedule a backup from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*importFile) > 0 {
+		return importTaskXML(*importFile)
+	}
+	if len(*job) == 0 {
+		return fmt.Errorf("xml: -job or -import is required")
+	}
+	task, err := scheduler.Find(backupScheduler, *job)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &scheduler.ErrParseTaskFailure{Inner: task.Err, Message: "failed to read task " + task.Name}
+	}
+	action, err := scheduler.BackupTaskAction("", task.Job)
+	if err != nil {
+		return err
+	}
+	b, err := scheduler.ExportTaskXML(task.Job, action, task.Enabled, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(*out) == 0 {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(*out, b, 0o644)
+}
+
+// importTaskXML schedules the job of a task scheduler XML file, a job that is already scheduled is updated
+func importTaskXML(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	job, enabled, err := scheduler.ImportTaskXML(b)
+	if err != nil {
+		return err
+	}
+	// Only a job that is not scheduled yet is created, any other error would create a second task of the job
+	_, err = backupScheduler.Get(job.ID)
+	var notFound *scheduler.ErrTaskNotFound
+	switch {
+	case err == nil:
+		_, err = backupScheduler.Update(job)
+	case errors.As(err, &notFound):
+		_, err = backupScheduler.Create(job)
+	}
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return backupScheduler.Enable(job.ID)
+	}
+	return backupScheduler.Disable(job.ID)
+}
+
+func runCliScript(args []string) error {
+	fs := flag.NewFlagSet("script", flag.ContinueOnError)
+	job := fs.String("job", "", "ID, task name or label of the scheduled backup")
+	out := fs.String("out", "", "file to write the script to, standard output when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*job) == 0 {
+		return fmt.Errorf("script: -job is required")
+	}
+	task, err := scheduler.Find(backupScheduler, *job)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &scheduler.ErrParseTaskFailure{Inner: task.Err, Message: "failed to read task " + task.Name}
+	}
+	script, err := scheduler.ShellScript(task.Job)
+	if err != nil {
+		return err
+	}
+	if len(*out) == 0 {
+		_, err = fmt.Fprint(os.Stdout, script)
+		return err
+	}
+	return os.WriteFile(*out, []byte(script), 0o755)
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package main
+
+// attachConsole is a no-op, the command line mode writes to the terminal it was started from
+func attachConsole() {}
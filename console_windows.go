@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// attachParentProcess is ATTACH_PARENT_PROCESS of AttachConsole
+const attachParentProcess = ^uintptr(0)
+
+var procAttachConsole = syscall.NewLazyDLL("kernel32.dll").NewProc("AttachConsole")
+
+// attachConsole connects the output of the command line mode to the console it was started from.
+// The app is built as a GUI application, which windows starts without a console. Nothing changes
+// when there is no parent console, as for the runs of the task scheduler.
+func attachConsole() {
+	if ret, _, _ := procAttachConsole.Call(attachParentProcess); ret == 0 {
+		return
+	}
+	if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = out
+		os.Stderr = out
+	}
+}
@@ -19,13 +19,14 @@ require (
 	github.com/rickb777/plural v1.4.1 // indirect
 	github.com/sahilm/fuzzy v0.1.0 // indirect
 	golang.org/x/image v0.0.0-20220302094943-723b81ca9867 // indirect
-	golang.org/x/sys v0.0.0-20220315194320-039c03cc5b86 // indirect
 	gopkg.in/eapache/queue.v1 v1.1.0 // indirect
 )
 
 require (
 	github.com/AllenDang/giu v0.6.2
 	github.com/sqweek/dialog v0.0.0-20220227145630-7a1c9e333fcf
+	golang.org/x/sys v0.0.0-20220315194320-039c03cc5b86
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 replace github.com/capnspacehook/taskmaster => github.com/Coffee4Coffee/taskmaster v1.0.0
@@ -1,64 +1,93 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
-	"runtime"
 	"strconv"
 	"strings"
-	"syscall"
-	"unsafe"
+	"sync"
+	"time"
+	_ "time/tzdata" // the time zones of schedules, windows has no zone database
 
 	g "github.com/AllenDang/giu"
 	"github.com/Coffee4Coffee/GoBackup/scheduler"
-	"github.com/capnspacehook/taskmaster"
 	"github.com/sqweek/dialog"
 )
 
 var (
 	srcDir              string
 	destDir             string
+	rotationDests       string
+	label               string
+	scheduleExpr        string
+	timeZone            string
 	weekdays            []string
-	monthlyDays         []string
+	weeksOfMonth        []string
 	backupLimitOptions  []string
 	hours               []string
-	scheduledTasks      taskmaster.RegisteredTaskCollection
+	minutes             []string
+	repeatUnits         []string
+	backupScheduler     scheduler.Scheduler
+	scheduledTasks      []scheduler.Task
+	editJob             scheduler.Job
 	tableData           []*g.TableRowWidget
+	historyData         []*g.TableRowWidget
 	overwrite           bool
 	disabled            bool
-	weekdaySelected     int32
+	runningOnce         bool
+	runningJobs         = map[string]bool{}
+	pauseUntil          time.Time
+	weekdaysChecked     [7]bool
+	monthlyDaysChecked  [32]bool // the 1st to the 31st and the last day of the month
+	weeksChecked        [5]bool
+	monthlyByWeekday    bool
 	backupLimitSelected int32
-	monthlyDaySelected  int32
 	hourSelected        int32
+	minuteSelected      int32
+	repeat              bool
+	repeatEvery         int32
+	repeatUnitSelected  int32
+	repeatUntilHour     int32
+	repeatUntilMinute   int32
+	jitter              int32
+	alertAfterDays      int32
 	radioOp             int
-
-	user32         = syscall.NewLazyDLL("user32.dll")
-	procMessageBox = user32.NewProc("MessageBoxW")
+	importConfig        *scheduler.Config
+	importItems         []scheduler.ImportItem
+	importMappings      string
+	importError         string
+	importForce         bool
+	importData          []*g.TableRowWidget
+	// previewSchedule is the schedule the preview of its next runs was computed for, see showSchedulePreview
+	previewSchedule scheduler.Schedule
+	previewComputed bool
+	previewRuns     []time.Time
+	previewErr      error
+	// uiQueue are the functions goroutines hand to the render loop, see runOnUI
+	uiQueue      []func()
+	uiQueueMutex sync.Mutex
 )
 
 // https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-messagebox
 const (
+	MB_YESNO       = 0x00000004
 	MB_RETRYCANCEL = 0x00000005
 	MB_ICONERROR   = 0x00000010
+	MB_ICONWARNING = 0x00000030
 	MB_DEFBUTTON2  = 0x00000100
+	IDOK           = 1
 	IDCANCEL       = 2
 	IDRETRY        = 4
+	IDYES          = 6
 )
 
-func MessageBox(caption, text string, flags uint) int {
-	hwnd := uintptr(0)
-	ret, _, _ := procMessageBox.Call(
-		hwnd,
-		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(text))),
-		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(caption))),
-		uintptr(flags))
-
-	return int(ret)
-}
-
+// handleError shows the error and returns the button that closed the message. Messages the user can only acknowledge
+// return IDCANCEL, so the callers carry on as if the user cancelled instead of ending the app.
 func handleError(err error) int {
-	switch err.(type) {
+	switch e := err.(type) {
 	case *scheduler.ErrConnectSchedulerFailure:
-		return MessageBox("Connection Error", "Could not connect to the windows task scheduler\nDo you want to try again?", MB_RETRYCANCEL|MB_ICONERROR|MB_DEFBUTTON2)
+		return MessageBox("Connection Error", "Could not connect to the task scheduler\nDo you want to try again?", MB_RETRYCANCEL|MB_ICONERROR|MB_DEFBUTTON2)
 	case *scheduler.ErrRetrieveTaskFolderFailure, *scheduler.ErrRetrieveTasksFailure:
 		return MessageBox("Fetch Error", "Could not fetch the scheduled backup tasks\nDo you want to try again?", MB_RETRYCANCEL|MB_ICONERROR|MB_DEFBUTTON2)
 	case *scheduler.ErrCreateTaskFailure:
@@ -67,6 +96,26 @@ func handleError(err error) int {
 		return MessageBox("Delete Error", "Could not delete the scheduled backup task\nDo you want to try again?", MB_RETRYCANCEL|MB_ICONERROR|MB_DEFBUTTON2)
 	case *scheduler.ErrDeleteTaskFolderFailure:
 		return MessageBox("Delete Error", "Could not delete the task folder\nDo you want to try again?", MB_RETRYCANCEL|MB_ICONERROR|MB_DEFBUTTON2)
+	case *scheduler.ErrUpdateTaskFailure:
+		return MessageBox("Update Error", "Could not update the scheduled backup task\nDo you want to try again?", MB_RETRYCANCEL|MB_ICONERROR|MB_DEFBUTTON2)
+	case *scheduler.ErrParseTaskFailure:
+		MessageBox("Parse Error", "Could not read the settings of the scheduled backup task", MB_ICONERROR)
+		return IDCANCEL
+	case *scheduler.ErrRunTaskFailure:
+		return MessageBox("Run Error", "Could not start the scheduled backup task\nDo you want to try again?", MB_RETRYCANCEL|MB_ICONERROR|MB_DEFBUTTON2)
+	case *scheduler.ErrTaskNotFound:
+		MessageBox("Not Found Error", "The scheduled backup task does not exist anymore", MB_ICONERROR)
+		return IDCANCEL
+	case *scheduler.ErrUnsupportedSchedule:
+		MessageBox("Schedule Error", "The scheduler cannot run the backup on this schedule:\n"+e.Inner.Error()+"\nSimplify the schedule or set "+schedulerEnv+"=daemon", MB_ICONERROR)
+		return IDCANCEL
+	case *scheduler.ErrVolumeNotFound:
+		return MessageBox("Volume Error", "The backup has been skipped, the "+e.Message+"\nDo you want to try again?", MB_RETRYCANCEL|MB_ICONWARNING|MB_DEFBUTTON2)
+	case *scheduler.ErrRunBackupFailure:
+		return MessageBox("Run Error", "Could not run the backup\nDo you want to try again?", MB_RETRYCANCEL|MB_ICONERROR|MB_DEFBUTTON2)
+	case *scheduler.ErrUnknownScheduler:
+		MessageBox("Scheduler Error", "The scheduler chosen by "+schedulerEnv+" is unknown, "+e.Message+"\nChange or remove it and start the application again", MB_ICONERROR)
+		return IDCANCEL
 	default:
 		return MessageBox("Unknown Error", "An unknown error occurred\nPlease restart the application and try again", MB_ICONERROR)
 	}
@@ -85,13 +134,29 @@ func selectFolder(src bool) {
 func resetForm() {
 	srcDir = ""
 	destDir = ""
-	monthlyDaySelected = 0
-	weekdaySelected = 0
+	rotationDests = ""
+	alertAfterDays = 0
+	label = ""
+	scheduleExpr = ""
+	timeZone = ""
+	// A new backup runs on sunday, the 1st or in the first week until other days are checked
+	weekdaysChecked = [7]bool{true}
+	monthlyDaysChecked = [32]bool{true}
+	weeksChecked = [5]bool{true}
+	monthlyByWeekday = false
 	backupLimitSelected = 0
 	overwrite = false
 	hourSelected = 0
+	minuteSelected = 0
+	repeat = false
+	repeatEvery = 1
+	repeatUnitSelected = 1
+	repeatUntilHour = 17
+	repeatUntilMinute = 0
+	jitter = 0
 	radioOp = 0
 	disabled = true
+	editJob = scheduler.Job{}
 }
 
 func initializeOptions() {
@@ -100,64 +165,222 @@ func initializeOptions() {
 
 	backupLimitOptions = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "∞"}
 
-	// taskmaster.LastDayOfTheMonth does not work currently, leave it out
-	monthlyDays = make([]string, 31)
-	for i := 0; i < 31; i++ {
-		monthlyDays[i] = strconv.Itoa(i + 1)
-	}
-
 	// Weekdays
-	weekdays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	weekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+	weeksOfMonth = []string{"First", "Second", "Third", "Fourth", "Last"}
 
-	// Hours
-	for i := 0; i < 24; i++ {
-		if i <= 9 {
-			hours = append(hours, "0"+strconv.Itoa(i)+":00")
-		} else {
-			hours = append(hours, strconv.Itoa(i)+":00")
+	// Pause
+	tNow := time.Now()
+	pauseUntil = time.Date(tNow.Year(), tNow.Month(), tNow.Day()+7, 0, 0, 0, 0, tNow.Location())
+
+	// Hours and minutes of the time picker
+	for i := 0; i < 60; i++ {
+		if i < 24 {
+			hours = append(hours, fmt.Sprintf("%02d", i))
 		}
+		minutes = append(minutes, fmt.Sprintf("%02d", i))
 	}
+	repeatUnits = []string{"minutes", "hours"}
+	resetForm()
 }
 
-func getTriggerIntervalType(tr taskmaster.Trigger) string {
-	switch tr.(type) {
+func getTriggerIntervalType(s scheduler.Schedule) string {
+	var interval string
+	switch s.Type {
 	default:
 		return "Unknown"
-	case taskmaster.DailyTrigger:
-		return "Daily"
-	case taskmaster.WeeklyTrigger:
-		return "Weekly"
-	case taskmaster.MonthlyTrigger:
-		return "Monthly"
+	case scheduler.Daily:
+		interval = "Daily"
+	case scheduler.Weekly:
+		interval = "Weekly on " + describeDays(s)
+	case scheduler.Monthly:
+		interval = "Monthly on " + describeDays(s)
+	case scheduler.Custom:
+		interval = s.Expr
+	}
+	if s.Type != scheduler.Custom {
+		interval += fmt.Sprintf(" at %02d:%02d", s.Hour, s.Minute)
+	}
+	if s.Repeats() {
+		interval += fmt.Sprintf(", every %v until %02d:%02d", formatMinutes(int(s.RepeatEvery)), s.RepeatUntil/60, s.RepeatUntil%60)
+	}
+	if s.Jitter > 0 {
+		interval += fmt.Sprintf(", up to %v later", formatMinutes(int(s.Jitter)))
+	}
+	if s.TimeZone != "" {
+		interval += " (" + s.TimeZone + ")"
+	}
+	return interval
+}
+
+// describeDays lists the days of a weekly or monthly schedule, e.g. "Mon, Fri" or "the first, last Fri"
+func describeDays(s scheduler.Schedule) string {
+	weekdaysMask := s.Weekdays
+	if weekdaysMask == 0 {
+		weekdaysMask = 1 << s.DayOfWeek
+	}
+	var names []string
+	for d := range weekdays {
+		if weekdaysMask&(1<<d) != 0 {
+			names = append(names, weekdays[d])
+		}
+	}
+	if s.Type == scheduler.Weekly {
+		return strings.Join(names, ", ")
+	}
+	if s.WeeksOfMonth != 0 {
+		var weeks []string
+		for n := range weeksOfMonth {
+			if s.WeeksOfMonth&(1<<n) != 0 {
+				weeks = append(weeks, strings.ToLower(weeksOfMonth[n]))
+			}
+		}
+		return "the " + strings.Join(weeks, ", ") + " " + strings.Join(names, ", ")
+	}
+	days := s.DaysOfMonth
+	if days == 0 {
+		days = 1 << (s.DayOfMonth - 1)
 	}
+	var dayNames []string
+	for d := 0; d < 31; d++ {
+		if days&(1<<d) != 0 {
+			dayNames = append(dayNames, strconv.Itoa(d+1))
+		}
+	}
+	if days&scheduler.LastDayOfMonth != 0 {
+		dayNames = append(dayNames, "last day")
+	}
+	return strings.Join(dayNames, ", ")
+}
+
+func formatMinutes(minutes int) string {
+	if minutes%60 == 0 {
+		return strconv.Itoa(minutes/60) + "h"
+	}
+	return strconv.Itoa(minutes) + " min"
+}
+
+func getTaskState(task scheduler.Task) string {
+	state := "Enabled"
+	if !task.Enabled {
+		state = "Disabled"
+	} else if task.Paused(time.Now()) {
+		state = "Paused until " + task.Job.PausedUntil.Format("2006-01-02 15:04")
+	}
+	if task.Expiring(time.Now()) {
+		state += ", expires " + task.Expires.Format("2006-01-02")
+	}
+	return state
+}
+
+func getLimitLabel(backupLimit uint8) string {
+	return backupLimitOptions[backupLimitOption(backupLimit)]
+}
+
+// backupLimitOption returns the option of a backup limit. A limit of 0 keeps all backups and is shown as ∞, as are the
+// limits above 10 that older versions stored for it.
+func backupLimitOption(backupLimit uint8) int32 {
+	if backupLimit == 0 || int(backupLimit) >= len(backupLimitOptions) {
+		return int32(len(backupLimitOptions) - 1)
+	}
+	return int32(backupLimit - 1)
+}
+
+// formBackupLimit returns the backup limit of an option, ∞ keeps all backups as 0
+func formBackupLimit(option int32) uint8 {
+	if int(option) >= len(backupLimitOptions)-1 {
+		return 0
+	}
+	return uint8(option + 1)
+}
+
+// formatPath shows a path with placeholders together with the path it resolves to for the current user
+func formatPath(path string) string {
+	if !scheduler.HasPlaceholders(path) {
+		return path
+	}
+	resolved, err := scheduler.ExpandPath(path)
+	var volumeErr *scheduler.ErrVolumeNotFound
+	if errors.As(err, &volumeErr) {
+		return path + " (not connected)"
+	}
+	if err != nil {
+		// Without the name of the function that wrapped the reason
+		reason := err
+		if inner := errors.Unwrap(err); inner != nil {
+			reason = inner
+		}
+		return path + " (" + reason.Error() + ")"
+	}
+	return path + " (" + resolved + ")"
+}
+
+// formatDrive shows a drive of a rotation with the time of its last successful backup
+func formatDrive(drive scheduler.DriveStatus) string {
+	last := "never backed up"
+	if !drive.LastSuccess.IsZero() {
+		last = "last backup " + drive.LastSuccess.Format("2006-01-02 15:04")
+	}
+	if drive.Overdue {
+		last += ", overdue"
+	}
+	return formatPath(drive.Dest) + ": " + last
 }
 
 func updateTable() {
 	if len(tableData) > 0 {
 		tableData = tableData[:0]
 	}
+	// The drives of a rotation show their last successful backup, without a history they show none
+	history, _ := scheduler.ReadHistory()
 	for index, _task := range scheduledTasks {
 		// Closure needed
 		task := _task
 		key := index
 
-		doc := strings.Split(task.Definition.RegistrationInfo.Documentation, "|")
-		srcPath := doc[0]
-		destPath := doc[1]
-		overwrite := doc[2]
-		limit := doc[3]
+		var jobLabel, srcPath, destPath, interval, overwrite, limit string
+		job, err := task.Job, task.Err
+		if err != nil {
+			jobLabel = "?"
+			srcPath = task.Name
+			destPath = "Unreadable task, please delete and recreate it: " + err.Error()
+			interval, overwrite, limit = "?", "?", "?"
+		} else {
+			jobLabel, srcPath, destPath = job.Label, formatPath(job.Src), formatPath(job.Dest)
+			if drives := scheduler.RotationStatus(job, history, time.Now()); len(drives) > 0 {
+				lines := make([]string, len(drives))
+				for i, drive := range drives {
+					lines[i] = formatDrive(drive)
+				}
+				destPath = strings.Join(lines, "\n")
+			}
+			interval = getTriggerIntervalType(job.Schedule)
+			overwrite, limit = "No", getLimitLabel(job.BackupLimit)
+			if job.Overwrite {
+				overwrite, limit = "Yes", "-"
+			}
+		}
+		toggleLabel := "Disable"
+		if !task.Enabled {
+			toggleLabel = "Enable"
+		}
 		tableData = append(tableData, g.TableRow(
+			g.Label(jobLabel),
 			g.Label(srcPath),
 			g.Tooltip(srcPath),
 			g.Label(destPath),
 			g.Tooltip(destPath),
-			g.Label(getTriggerIntervalType(task.Definition.Triggers[0])),
-			g.Label(limit),
+			g.Label(interval),
 			g.Label(overwrite),
+			g.Label(limit),
 			g.Label(task.NextRunTime.Format("2006-01-02 15:04:05")),
 			g.Label(task.LastRunTime.Format("2006-01-02 15:04:05")),
 			g.Label(strconv.Itoa(int(task.MissedRuns))),
-			g.Label(task.LastTaskResult.String()),
+			g.Label(task.LastResult),
+			g.Label(getTaskState(task)),
+			g.Button(toggleLabel).OnClick(func() { setBackupEnabled(key, !task.Enabled) }).Disabled(err != nil),
+			g.Button("Run now").OnClick(func() { runScheduledBackup(job.ID) }).Disabled(err != nil || runningJobs[job.ID]),
+			g.Button("Edit").OnClick(func() { editScheduledBackup(key) }).Disabled(err != nil),
 			g.Button("Delete").OnClick(func() { g.OpenPopup(strconv.Itoa(key)) }),
 			g.PopupModal(strconv.Itoa(key)).Flags(g.WindowFlagsNoTitleBar|g.WindowFlagsNoResize|g.WindowFlagsNoMove).Layout(
 				g.Label("Are you sure?"),
@@ -173,9 +396,182 @@ func updateTable() {
 	}
 }
 
+func exportJobs() {
+	path, err := dialog.File().Filter("YAML file", "yaml", "yml").Title("Export jobs").Save()
+	if err != nil {
+		// The dialog was cancelled
+		return
+	}
+	if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+		path += ".yaml"
+	}
+	// Without a home folder the user profile paths are simply not remapped on import
+	home, _ := os.UserHomeDir()
+	b, err := scheduler.MarshalConfig(scheduler.ExportConfig(scheduledTasks, home))
+	if err == nil {
+		err = os.WriteFile(path, b, 0o644)
+	}
+	if err != nil {
+		MessageBox("Export Error", "Could not write the jobs to the file:\n"+err.Error(), MB_ICONERROR)
+	}
+}
+
+func importJobs() {
+	path, err := dialog.File().Filter("YAML file", "yaml", "yml").Title("Import jobs").Load()
+	if err != nil {
+		// The dialog was cancelled
+		return
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		MessageBox("Import Error", "Could not read the file:\n"+err.Error(), MB_ICONERROR)
+		return
+	}
+	config, err := scheduler.ParseConfig(b)
+	if err != nil {
+		MessageBox("Import Error", "The file does not contain exported jobs:\n"+err.Error(), MB_ICONERROR)
+		return
+	}
+	importConfig = &config
+	importMappings = ""
+	importForce = false
+	updateImportPreview()
+}
+
+// updateImportPreview remaps the paths of the imported jobs and checks them again, the folders might have been created
+func updateImportPreview() {
+	importItems, importError = nil, ""
+	importData = importData[:0]
+	if importConfig == nil {
+		return
+	}
+	mappings, err := scheduler.ParsePathMappings(importMappings)
+	if err == nil {
+		home, _ := os.UserHomeDir()
+		importItems, err = scheduler.PlanImport(*importConfig, mappings, home, scheduledTasks)
+	}
+	if err != nil {
+		importError = err.Error()
+		return
+	}
+	for _, item := range importItems {
+		problems := item.Problems
+		for _, task := range item.Duplicates {
+			problems = append(problems[:len(problems):len(problems)], "already scheduled as "+task.Name)
+		}
+		status := "Import"
+		if !item.Ready(importForce) {
+			status = "Skip"
+		}
+		src, dest := remappedLabel(item.Backup.Src, item.Job.Src), remappedLabel(item.Backup.Dest, item.Job.Dest)
+		importData = append(importData, g.TableRow(
+			g.Label(item.Backup.Name),
+			g.Label(src),
+			g.Tooltip(src),
+			g.Label(dest),
+			g.Tooltip(dest),
+			g.Label(getTriggerIntervalType(item.Job.Schedule)),
+			g.Label(strings.Join(problems, "\n")),
+			g.Label(status),
+		))
+	}
+}
+
+func remappedLabel(original, remapped string) string {
+	if original == remapped {
+		return original
+	}
+	return original + " -> " + remapped
+}
+
+func confirmImport() {
+	_, err := scheduler.ImportJobs(backupScheduler, importItems, importForce)
+	if err != nil {
+		// The jobs imported before the error are duplicates in the next preview and are not imported twice
+		initializeTable()
+		if messageBoxReturnCode := handleError(errors.Unwrap(err)); messageBoxReturnCode == IDRETRY {
+			confirmImport()
+		} else if messageBoxReturnCode != IDCANCEL {
+			os.Exit(1)
+		}
+	} else {
+		importConfig = nil
+		initializeTable()
+	}
+}
+
+func showImportPreview() g.Layout {
+	if importConfig == nil {
+		return g.Layout{}
+	}
+	ready := 0
+	for _, item := range importItems {
+		if item.Ready(importForce) {
+			ready++
+		}
+	}
+	return g.Layout{
+		g.Dummy(0, 10),
+		g.Label("Import jobs"),
+		g.Row(
+			g.InputText(&importMappings).Size(500).Hint(`Remap paths, e.g. E:=F:; D:\Data=G:\Data`).OnChange(updateImportPreview),
+			g.Tooltip("Replace drive letters or folders of the other machine, separated by semicolons. Its user profile folder is replaced by yours."),
+			g.Checkbox("Also import jobs with problems", &importForce).OnChange(updateImportPreview),
+			g.Tooltip("Import jobs whose src or dest does not exist, jobs that are already scheduled are never imported"),
+		),
+		g.Label(importError),
+		g.Table().
+			Columns(
+				g.TableColumn("Label").Flags(g.TableColumnFlagsWidthFixed),
+				g.TableColumn("Src").Flags(g.TableColumnFlagsWidthStretch),
+				g.TableColumn("Dest").Flags(g.TableColumnFlagsWidthStretch),
+				g.TableColumn("Time interval").Flags(g.TableColumnFlagsWidthFixed),
+				g.TableColumn("Problems").Flags(g.TableColumnFlagsWidthStretch),
+				g.TableColumn("Status").Flags(g.TableColumnFlagsWidthFixed),
+			).
+			Rows(
+				importData...,
+			),
+		g.Row(
+			g.Button(fmt.Sprintf("Import %v of %v jobs", ready, len(importItems))).OnClick(confirmImport).Disabled(ready == 0),
+			g.Button("Cancel").OnClick(func() {
+				importConfig = nil
+				updateImportPreview()
+			}),
+		),
+	}
+}
+
+func updateHistory() {
+	history, err := scheduler.ReadHistory()
+	if err != nil {
+		// The history is informational only, an unreadable file should not block the app
+		history = nil
+	}
+	historyData = historyData[:0]
+	for _, entry := range history {
+		result := "Success"
+		if entry.Skipped() {
+			result = "Skipped"
+		} else if !entry.Success() {
+			result = "Failed (" + strconv.Itoa(entry.ExitCode) + ")"
+		}
+		historyData = append(historyData, g.TableRow(
+			g.Label(entry.Time.Format("2006-01-02 15:04:05")),
+			g.Label(entry.Src),
+			g.Tooltip(entry.Src),
+			g.Label(entry.Dest),
+			g.Tooltip(entry.Dest),
+			g.Label(result),
+			g.Label(entry.Message),
+			g.Tooltip(entry.Message),
+		))
+	}
+}
+
 func initializeTable() {
 	var err error
-	scheduledTasks, err = scheduler.GetAllScheduledTasks()
+	scheduledTasks, err = backupScheduler.List()
 	if err != nil {
 		if messageBoxReturnCode := handleError(err); messageBoxReturnCode == IDCANCEL {
 			os.Exit(1)
@@ -186,33 +582,161 @@ func initializeTable() {
 		}
 	}
 	updateTable()
+	updateHistory()
+	updateImportPreview()
 }
 
 func setDayOption() g.Layout {
 	if radioOp == 1 {
 		return g.Layout{
 			g.Row(
-				g.Label("Weekday"),
+				g.Label("Weekdays"),
 			),
 			g.Row(
-				g.Combo("", weekdays[weekdaySelected], weekdays, &weekdaySelected).Size(110),
+				weekdayCheckboxes("weekly"),
 			),
 		}
 	}
 	if radioOp == 2 {
 		return g.Layout{
 			g.Row(
-				g.Label("Day of the month"),
+				g.RadioButton("Days of the month", !monthlyByWeekday).OnChange(func() { monthlyByWeekday = false }),
+				g.RadioButton("Weekdays of the month", monthlyByWeekday).OnChange(func() { monthlyByWeekday = true }),
+				g.Tooltip("e.g. the first monday or the last friday of every month"),
 			),
+			showMonthlyDays(),
+		}
+	}
+	if radioOp == 3 {
+		return g.Layout{
 			g.Row(
-				g.Combo("", monthlyDays[monthlyDaySelected], monthlyDays, &monthlyDaySelected).Size(110),
-				g.Tooltip("Months that do not include the given day will be skipped, e.g. the 30th for february"),
+				g.Label("Cron expression"),
+			),
+			g.Row(
+				g.InputText(&scheduleExpr).Size(300).Hint("e.g. */30 9-17 * * 1-5"),
+				g.Tooltip("minute hour day-of-month month day-of-week\nL is the last day of the month, 5L the last friday and 1#2 the second monday of the month"),
 			),
 		}
 	}
 	return g.Layout{}
 }
 
+// weekdayCheckboxes selects several weekdays, the id keeps the checkboxes of the weekly and monthly options apart
+func weekdayCheckboxes(id string) g.Layout {
+	var layout g.Layout
+	for d := range weekdays {
+		layout = append(layout, g.Checkbox(weekdays[d]+"##"+id, &weekdaysChecked[d]))
+	}
+	return layout
+}
+
+func showMonthlyDays() g.Layout {
+	if monthlyByWeekday {
+		var weeks g.Layout
+		for n := range weeksOfMonth {
+			weeks = append(weeks, g.Checkbox(weeksOfMonth[n], &weeksChecked[n]))
+		}
+		return g.Layout{
+			g.Row(weeks...),
+			g.Row(weekdayCheckboxes("monthly")),
+		}
+	}
+	// A calendar of seven days a row
+	var layout g.Layout
+	for week := 0; week < 5; week++ {
+		var row g.Layout
+		for day := week * 7; day < week*7+7 && day < 31; day++ {
+			row = append(row, g.Checkbox(fmt.Sprintf("%2d", day+1), &monthlyDaysChecked[day]))
+		}
+		if week == 4 {
+			row = append(row, g.Checkbox("Last day", &monthlyDaysChecked[31]))
+			row = append(row, g.Tooltip("Months that do not include a selected day are skipped for it, e.g. the 30th for february, the last day is in every month"))
+		}
+		layout = append(layout, g.Row(row...))
+	}
+	return layout
+}
+
+// showSchedulePreview shows the next runs of the schedule in the form while it is changed, in local time
+func showSchedulePreview() g.Widget {
+	// The runs are only computed again when the schedule changes or its first run has passed
+	schedule := getFormSchedule()
+	if schedule != previewSchedule || !previewComputed || len(previewRuns) > 0 && previewRuns[0].Before(time.Now()) {
+		previewSchedule, previewComputed, previewRuns, previewErr = schedule, true, nil, schedule.Validate()
+		if previewErr == nil {
+			previewRuns = schedule.NextRuns(time.Now(), 10)
+		}
+	}
+	if previewErr != nil {
+		return g.Label("Invalid schedule: " + previewErr.Error())
+	}
+	var runs g.Layout
+	for _, run := range previewRuns {
+		runs = append(runs, g.Label(run.Format("Mon 2006-01-02 15:04 MST")))
+	}
+	return g.TreeNode("Next 10 runs").Flags(g.TreeNodeFlagsDefaultOpen).Layout(runs...)
+}
+
+// timePicker picks a time of day with minute precision
+func timePicker(id string, hour, minute *int32) g.Layout {
+	return g.Layout{
+		g.Combo("##"+id+"Hour", hours[*hour], hours, hour).Size(50),
+		g.Label(":"),
+		g.Combo("##"+id+"Minute", minutes[*minute], minutes, minute).Size(50),
+	}
+}
+
+func showTimeOption() g.Layout {
+	// A cron expression holds its own times, the random delay applies to it as well
+	if radioOp == 3 {
+		return g.Layout{
+			showTimeZoneOption(),
+			showJitterOption(),
+		}
+	}
+	return g.Layout{
+		g.Label("Time"),
+		timePicker("start", &hourSelected, &minuteSelected),
+		showTimeZoneOption(),
+		g.Checkbox("Repeat", &repeat),
+		g.Tooltip("Run the backup several times a day, e.g. every 2 hours during working hours"),
+		showRepeatOption(),
+		showJitterOption(),
+	}
+}
+
+// showTimeZoneOption shows the time zone only to schedulers that run in other zones, or to clear the zone of a job
+func showTimeZoneOption() g.Layout {
+	if !scheduler.SupportsTimeZones(backupScheduler) && len(timeZone) == 0 {
+		return g.Layout{}
+	}
+	return g.Layout{
+		g.InputText(&timeZone).Size(140).Hint("Local time"),
+		g.Tooltip("Run the backup on the clock of another time zone, e.g. Europe/Berlin or America/New_York"),
+	}
+}
+
+func showRepeatOption() g.Layout {
+	if !repeat {
+		return g.Layout{}
+	}
+	return g.Layout{
+		g.Label("every"),
+		g.InputInt(&repeatEvery).Size(80),
+		g.Combo("##repeatUnit", repeatUnits[repeatUnitSelected], repeatUnits, &repeatUnitSelected).Size(90),
+		g.Label("until"),
+		timePicker("repeatUntil", &repeatUntilHour, &repeatUntilMinute),
+	}
+}
+
+func showJitterOption() g.Layout {
+	return g.Layout{
+		g.Label("Random delay"),
+		g.InputInt(&jitter).Size(80),
+		g.Tooltip("Start the backup up to the given number of minutes later, so backups starting at the same time do not run all at once"),
+	}
+}
+
 func showLimitOption() g.Layout {
 	if !overwrite {
 		return g.Layout{
@@ -229,12 +753,98 @@ func showLimitOption() g.Layout {
 	}
 }
 
+func showFormButtons() g.Layout {
+	if len(editJob.ID) > 0 {
+		return g.Layout{
+			g.Row(
+				g.Button("Save changes").Size(200, 50).OnClick(saveScheduledBackup).Disabled(disabled),
+				g.Button("Cancel").Size(200, 50).OnClick(resetForm),
+			),
+		}
+	}
+	return g.Layout{
+		g.Row(
+			g.Button("Create backup").Size(200, 50).OnClick(createScheduledBackup).Disabled(disabled),
+			g.Button("Back up once").Size(200, 50).OnClick(backUpOnce).Disabled(disabled || runningOnce),
+			g.Tooltip("Run the backup now with the settings above, without scheduling it"),
+		),
+	}
+}
+
+func editScheduledBackup(index int) {
+	task := scheduledTasks[index]
+	if task.Err != nil {
+		handleError(&scheduler.ErrParseTaskFailure{Inner: task.Err, Message: "failed to read task " + task.Name})
+		return
+	}
+	job := task.Job
+	srcDir = job.Src
+	destDir = job.Dest
+	rotationDests = strings.Join(job.Rotation, "; ")
+	alertAfterDays = int32(job.AlertAfterDays)
+	label = job.Label
+	radioOp = int(job.Schedule.Type)
+	setFormDays(job.Schedule)
+	scheduleExpr = job.Schedule.Expr
+	timeZone = job.Schedule.TimeZone
+	hourSelected = int32(job.Schedule.Hour)
+	minuteSelected = int32(job.Schedule.Minute)
+	repeat = job.Schedule.RepeatEvery > 0
+	repeatEvery, repeatUnitSelected = 1, 1
+	repeatUntilHour, repeatUntilMinute = 17, 0
+	if repeat {
+		repeatEvery, repeatUnitSelected = int32(job.Schedule.RepeatEvery), 0
+		if job.Schedule.RepeatEvery%60 == 0 {
+			repeatEvery, repeatUnitSelected = int32(job.Schedule.RepeatEvery/60), 1
+		}
+		repeatUntilHour, repeatUntilMinute = int32(job.Schedule.RepeatUntil/60), int32(job.Schedule.RepeatUntil%60)
+	}
+	jitter = int32(job.Schedule.Jitter)
+	overwrite = job.Overwrite
+	backupLimitSelected = backupLimitOption(job.BackupLimit)
+	editJob = job
+	checkReady()
+}
+
+func saveScheduledBackup() {
+	if !formDirExists(srcDir) {
+		MessageBox("Directory Error", "The given src directoy does not exist", MB_ICONERROR)
+		return
+	}
+	if !formDestsExist() {
+		MessageBox("Directory Error", "The given dest directoy does not exist", MB_ICONERROR)
+		return
+	}
+
+	// The edited job keeps its identity, pause and notification setting
+	job := getFormJob()
+	if !checkSchedule(job) {
+		return
+	}
+	job.ID = editJob.ID
+	job.PausedUntil = editJob.PausedUntil
+	job.Notify = editJob.Notify
+	_, err := backupScheduler.Update(job)
+	if err != nil {
+		if messageBoxReturnCode := handleError(err); messageBoxReturnCode == IDRETRY {
+			saveScheduledBackup()
+		} else if messageBoxReturnCode != IDCANCEL {
+			os.Exit(1)
+		}
+	} else {
+		initializeTable()
+		resetForm()
+	}
+}
+
 func deleteScheduledBackup(index int) {
-	deleteFolder := false
-	if len(scheduledTasks) == 1 {
-		deleteFolder = true
+	task := scheduledTasks[index]
+	// Unreadable tasks have no job ID, the scheduler also finds them by name
+	id := task.Job.ID
+	if task.Err != nil {
+		id = task.Name
 	}
-	err := scheduler.DeleteScheduledTask(scheduledTasks[index].Name, deleteFolder)
+	err := backupScheduler.Delete(id)
 	if err != nil {
 		if messageBoxReturnCode := handleError(err); messageBoxReturnCode == IDRETRY {
 			deleteScheduledBackup(index)
@@ -244,33 +854,295 @@ func deleteScheduledBackup(index int) {
 			os.Exit(1)
 		}
 	} else {
+		if task.Err == nil && task.Job.ID == editJob.ID {
+			resetForm()
+		}
 		initializeTable()
 	}
 }
 
+func setBackupEnabled(index int, enabled bool) {
+	var err error
+	if enabled {
+		err = backupScheduler.Enable(scheduledTasks[index].Job.ID)
+	} else {
+		err = backupScheduler.Disable(scheduledTasks[index].Job.ID)
+	}
+	if err != nil {
+		if messageBoxReturnCode := handleError(err); messageBoxReturnCode == IDRETRY {
+			setBackupEnabled(index, enabled)
+		} else if messageBoxReturnCode != IDCANCEL {
+			os.Exit(1)
+		}
+	} else {
+		initializeTable()
+	}
+}
+
+func pauseAllBackups() {
+	if !pauseUntil.After(time.Now()) {
+		MessageBox("Pause Error", "The backups can only be paused until a date in the future", MB_ICONERROR)
+		return
+	}
+	err := scheduler.PauseAll(backupScheduler, pauseUntil)
+	if err != nil {
+		if messageBoxReturnCode := handleError(err); messageBoxReturnCode == IDRETRY {
+			pauseAllBackups()
+		} else if messageBoxReturnCode != IDCANCEL {
+			os.Exit(1)
+		}
+	} else {
+		initializeTable()
+	}
+}
+
+func resumeAllBackups() {
+	err := scheduler.ResumeAll(backupScheduler)
+	if err != nil {
+		if messageBoxReturnCode := handleError(err); messageBoxReturnCode == IDRETRY {
+			resumeAllBackups()
+		} else if messageBoxReturnCode != IDCANCEL {
+			os.Exit(1)
+		}
+	} else {
+		initializeTable()
+	}
+}
+
+// runScheduledBackup runs a job in the background, most schedulers other than the task scheduler copy before they return
+func runScheduledBackup(id string) {
+	runningJobs[id] = true
+	updateTable()
+	go func() {
+		err := backupScheduler.Run(id)
+		runOnUI(func() {
+			delete(runningJobs, id)
+			updateTable()
+			if err != nil {
+				if messageBoxReturnCode := handleError(err); messageBoxReturnCode == IDRETRY {
+					runScheduledBackup(id)
+				} else if messageBoxReturnCode != IDCANCEL {
+					os.Exit(1)
+				}
+				return
+			}
+			initializeTable()
+		})
+	}()
+}
+
+func backUpOnce() {
+	if !formDirExists(srcDir) {
+		MessageBox("Directory Error", "The given src directoy does not exist", MB_ICONERROR)
+		return
+	}
+	if !formDestsExist() {
+		MessageBox("Directory Error", "The given dest directoy does not exist", MB_ICONERROR)
+		return
+	}
+
+	// The copy can take a while, keep the window responsive
+	runningOnce = true
+	runBackupOnce(getFormJob())
+}
+
+// runBackupOnce backs up a job in the background, the result is shown by the render loop
+func runBackupOnce(job scheduler.Job) {
+	go func() {
+		err := scheduler.RunBackupOnce(job)
+		runOnUI(func() {
+			if err != nil && handleError(err) == IDRETRY {
+				runBackupOnce(job)
+				return
+			}
+			runningOnce = false
+			updateHistory()
+		})
+	}()
+}
+
+// runOnUI hands a function to the render loop, goroutines must not change the state the window shows or open dialogs
+func runOnUI(f func()) {
+	uiQueueMutex.Lock()
+	uiQueue = append(uiQueue, f)
+	uiQueueMutex.Unlock()
+	g.Update()
+}
+
+// runUIQueue runs the functions of runOnUI at the start of a frame
+func runUIQueue() {
+	uiQueueMutex.Lock()
+	queue := uiQueue
+	uiQueue = nil
+	uiQueueMutex.Unlock()
+	for _, f := range queue {
+		f()
+	}
+}
+
+// getFormJob returns a new job with the settings of the form
+func getFormJob() scheduler.Job {
+	job := scheduler.NewJob(formBackupLimit(backupLimitSelected), srcDir, destDir, overwrite)
+	if len(label) > 0 {
+		job.Label = label
+	}
+	job.Rotation = splitPaths(rotationDests)
+	if len(job.Rotation) > 0 {
+		job.AlertAfterDays = uint16(clampInput(alertAfterDays, 1<<16-1))
+	}
+	job.Schedule = getFormSchedule()
+	return job
+}
+
+// getFormSchedule returns the schedule of the form
+func getFormSchedule() scheduler.Schedule {
+	s := scheduler.Schedule{
+		Type:     scheduler.TriggerType(radioOp),
+		Hour:     uint8(hourSelected),
+		Minute:   uint8(minuteSelected),
+		TimeZone: strings.TrimSpace(timeZone),
+	}
+	setScheduleDays(&s)
+	if jitter > 0 {
+		// Out of range values fail the validation instead of wrapping around
+		s.Jitter = uint16(clampInput(jitter, 1<<16-1))
+	}
+	if s.Type == scheduler.Custom {
+		s.Expr = scheduleExpr
+	} else if repeat {
+		every := repeatEvery
+		if repeatUnitSelected == 1 {
+			every *= 60
+		}
+		s.RepeatEvery = uint16(clampInput(every, 1<<16-1))
+		s.RepeatUntil = uint16(repeatUntilHour*60 + repeatUntilMinute)
+	}
+	return s
+}
+
+// setScheduleDays sets the days checked in the form, a single weekday or day of the month is stored as before
+func setScheduleDays(s *scheduler.Schedule) {
+	var weekdays uint8
+	for d, checked := range weekdaysChecked {
+		if checked {
+			weekdays |= 1 << d
+		}
+	}
+	var days uint32
+	for d, checked := range monthlyDaysChecked {
+		if checked {
+			days |= 1 << d
+		}
+	}
+	var weeks uint8
+	for n, checked := range weeksChecked {
+		if checked {
+			weeks |= 1 << n
+		}
+	}
+	if !monthlyByWeekday {
+		weeks = 0
+	}
+	s.SetDays(weekdays, days, weeks)
+}
+
+// formDaysChecked reports whether the days the selected interval needs are checked
+func formDaysChecked() bool {
+	anyChecked := func(checked []bool) bool {
+		for _, c := range checked {
+			if c {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case radioOp == 1:
+		return anyChecked(weekdaysChecked[:])
+	case radioOp == 2 && monthlyByWeekday:
+		return anyChecked(weekdaysChecked[:]) && anyChecked(weeksChecked[:])
+	case radioOp == 2:
+		return anyChecked(monthlyDaysChecked[:])
+	}
+	return true
+}
+
+// setFormDays checks the days of a schedule in the form
+func setFormDays(s scheduler.Schedule) {
+	weekdays, days, weeks := s.Days()
+	for d := range weekdaysChecked {
+		weekdaysChecked[d] = weekdays&(1<<d) != 0
+	}
+	for d := range monthlyDaysChecked {
+		monthlyDaysChecked[d] = days&(1<<d) != 0
+	}
+	for n := range weeksChecked {
+		weeksChecked[n] = weeks&(1<<n) != 0
+	}
+	monthlyByWeekday = weeks != 0
+}
+
+// clampInput keeps a number typed into the form within 0 and max
+func clampInput(value, max int32) int32 {
+	if value < 0 {
+		return 0
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// checkSchedule tells the user about an invalid schedule, the preview shows it as well
+func checkSchedule(job scheduler.Job) bool {
+	if !formDaysChecked() {
+		MessageBox("Schedule Error", "Please select at least one day", MB_ICONERROR)
+		return false
+	}
+	if repeat && job.Schedule.Type != scheduler.Custom && job.Schedule.RepeatEvery == 0 {
+		MessageBox("Schedule Error", "The schedule is not valid:\nrepeat every must be at least 1", MB_ICONERROR)
+		return false
+	}
+	if err := job.Schedule.Validate(); err != nil {
+		MessageBox("Schedule Error", "The schedule is not valid:\n"+err.Error(), MB_ICONERROR)
+		return false
+	}
+	return true
+}
+
 func createScheduledBackup() {
-	if _, err := os.Stat(srcDir); os.IsNotExist(err) {
-		MessageBox("Directory Error", "The given src directoy does not exist\nPlease restart the application and try again", MB_ICONERROR)
-		os.Exit(1)
+	if !formDirExists(srcDir) {
+		MessageBox("Directory Error", "The given src directoy does not exist", MB_ICONERROR)
+		return
 	}
-	if _, err := os.Stat(destDir); os.IsNotExist(err) {
-		MessageBox("Directory Error", "The given dest directoy does not exist\nPlease restart the application and try again", MB_ICONERROR)
-		os.Exit(1)
+	if !formDestsExist() {
+		MessageBox("Directory Error", "The given dest directoy does not exist", MB_ICONERROR)
+		return
 	}
 
-	_, err := scheduler.CreateScheduledTask(
-		scheduler.TriggerType(radioOp),
-		uint8(monthlyDaySelected),
-		uint8(weekdaySelected),
-		uint8(hourSelected),
-		uint8(backupLimitSelected+1),
-		srcDir,
-		destDir,
-		overwrite,
-	)
+	job := getFormJob()
+	if !checkSchedule(job) {
+		return
+	}
+	duplicates := scheduler.FindDuplicates(scheduledTasks, job)
+	if len(duplicates) > 0 {
+		names := make([]string, len(duplicates))
+		for i, task := range duplicates {
+			names[i] = task.Name
+		}
+		text := "A backup of this folder to the same destination with the same schedule already exists:\n" + strings.Join(names, "\n") + "\nDo you want to create another one?"
+		if MessageBox("Duplicate Backup", text, MB_YESNO|MB_ICONWARNING|MB_DEFBUTTON2) != IDYES {
+			return
+		}
+	}
+	registerScheduledBackup(job)
+}
+
+func registerScheduledBackup(job scheduler.Job) {
+	_, err := backupScheduler.Create(job)
 	if err != nil {
 		if messageBoxReturnCode := handleError(err); messageBoxReturnCode == IDRETRY {
-			createScheduledBackup()
+			registerScheduledBackup(job)
 		} else if messageBoxReturnCode != IDCANCEL && messageBoxReturnCode != IDRETRY {
 			os.Exit(1)
 		}
@@ -280,6 +1152,42 @@ func createScheduledBackup() {
 	}
 }
 
+// formDirExists reports whether a folder of the form exists, its placeholders are expanded for the current user.
+// A folder on a volume that is not connected is checked when the backup runs.
+func formDirExists(dir string) bool {
+	resolved, err := scheduler.ExpandPath(dir)
+	var volumeErr *scheduler.ErrVolumeNotFound
+	if errors.As(err, &volumeErr) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(resolved)
+	return !os.IsNotExist(err)
+}
+
+// formDestsExist reports whether the dest of the form exists. A drive of a rotation may be offsite, one of them is enough.
+func formDestsExist() bool {
+	for _, dir := range append([]string{destDir}, splitPaths(rotationDests)...) {
+		if formDirExists(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPaths returns the paths of a list separated by semicolons
+func splitPaths(list string) []string {
+	var paths []string
+	for _, path := range strings.Split(list, ";") {
+		if path = strings.TrimSpace(path); len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
 func checkReady() {
 	if len(srcDir) > 0 && len(destDir) > 0 {
 		disabled = false
@@ -288,7 +1196,11 @@ func checkReady() {
 	}
 }
 
+const placeholderTooltip = `Select a folder or type a path, placeholders such as {Documents}\Taxes or %USERPROFILE%\Music are resolved when the backup runs.
+Available folders: {Home}, {Desktop}, {Documents}, {Downloads}, {Music}, {Pictures} and {Videos}`
+
 func loop() {
+	runUIQueue()
 	g.SingleWindow().Layout(
 		g.Row(
 			g.Align(g.AlignCenter).To(
@@ -298,7 +1210,8 @@ func loop() {
 					),
 					g.Row(
 
-						g.InputTextMultiline(&srcDir).Size(1000, 30).Flags(g.InputTextFlagsReadOnly),
+						g.InputTextMultiline(&srcDir).Size(1000, 30).OnChange(checkReady),
+						g.Tooltip(placeholderTooltip),
 						g.Button("Select").Size(100, 30).OnClick(func() { selectFolder(true) }),
 					),
 				),
@@ -308,12 +1221,36 @@ func loop() {
 						g.Label("Select a destination for the backup"),
 					),
 					g.Row(
-						g.InputTextMultiline(&destDir).Size(1000, 30).Flags(g.InputTextFlagsReadOnly),
+						g.InputTextMultiline(&destDir).Size(1000, 30).OnChange(checkReady),
+						g.Tooltip(placeholderTooltip),
 						g.Button("Select").Size(100, 30).OnClick(func() { selectFolder(false) }),
 						g.Tooltip("The backup folder will be created if it does not already exist"),
 					),
 				),
 				g.Dummy(0, 10),
+				g.Column(
+					g.Row(
+						g.Label("Other drives of a rotation"),
+					),
+					g.Row(
+						g.InputText(&rotationDests).Size(780).Hint(`Optional, e.g. {Label:OFFSITE}\Backup; {Label:SPARE}\Backup`),
+						g.Tooltip("Drives that take turns with the destination, separated by semicolons. A backup goes to the first of them that is connected and keeps its own number of backups on every drive"),
+						g.Label("Alert after days"),
+						g.InputInt(&alertAfterDays).Size(100),
+						g.Tooltip("Show an alert when a drive of the rotation has not been backed up to for longer than the given number of days, 0 never does"),
+					),
+				),
+				g.Dummy(0, 10),
+				g.Column(
+					g.Row(
+						g.Label("Label"),
+					),
+					g.Row(
+						g.InputText(&label).Size(1000).Hint("Optional, defaults to the name of the folder"),
+						g.Tooltip("A name to tell several backups of the same folder apart"),
+					),
+				),
+				g.Dummy(0, 10),
 				g.Column(
 					g.Row(
 						g.Label("Select an interval"),
@@ -331,24 +1268,29 @@ func loop() {
 							radioOp = 0
 							setDayOption()
 						}),
+						g.RadioButton("Advanced", radioOp == 3).OnChange(func() {
+							radioOp = 3
+							setDayOption()
+						}),
+						g.Tooltip("Run the backup on a cron expression, e.g. every 30 minutes during working hours"),
 					),
 				),
 				g.Dummy(0, 10),
 				g.Column(
 					g.Row(
 						setDayOption(),
-						g.Label("Time"),
-						g.Combo("", hours[hourSelected], hours, &hourSelected).Size(100),
+						showTimeOption(),
 						g.Checkbox("Overwrite", &overwrite),
 						g.Tooltip("Overwrite the previous backup folder with a new one, or create a new backup folder with a timestamp on every execution"),
 						showLimitOption(),
 					),
+					g.Row(
+						showSchedulePreview(),
+					),
 				),
 				g.Dummy(0, 30),
 				g.Column(
-					g.Row(
-						g.Button("Create backup").Size(200, 50).OnClick(createScheduledBackup).Disabled(disabled),
-					),
+					showFormButtons(),
 				),
 			),
 		),
@@ -356,6 +1298,7 @@ func loop() {
 		g.Row(
 			g.Table().
 				Columns(
+					g.TableColumn("Label").Flags(g.TableColumnFlagsWidthFixed),
 					g.TableColumn("Src").Flags(g.TableColumnFlagsWidthStretch),
 					g.TableColumn("Dest").Flags(g.TableColumnFlagsWidthFixed),
 					g.TableColumn("Time interval").Flags(g.TableColumnFlagsWidthFixed),
@@ -365,21 +1308,65 @@ func loop() {
 					g.TableColumn("Last Run Time").Flags(g.TableColumnFlagsWidthFixed),
 					g.TableColumn("Missed Runs").Flags(g.TableColumnFlagsWidthFixed),
 					g.TableColumn("Last Task Result").Flags(g.TableColumnFlagsWidthFixed),
+					g.TableColumn("State").Flags(g.TableColumnFlagsWidthFixed),
+					g.TableColumn("Enable").Flags(g.TableColumnFlagsWidthFixed),
+					g.TableColumn("Run").Flags(g.TableColumnFlagsWidthFixed),
+					g.TableColumn("Edit").Flags(g.TableColumnFlagsWidthFixed),
 					g.TableColumn("Delete").Flags(g.TableColumnFlagsWidthFixed),
 				).
 				Rows(
 					tableData...,
 				),
 		),
+		g.Dummy(0, 10),
+		g.Row(
+			g.Label("Pause all backups until"),
+			g.DatePicker("##pauseUntil", &pauseUntil),
+			g.Button("Pause all").OnClick(pauseAllBackups).Disabled(len(scheduledTasks) == 0),
+			g.Tooltip("Scheduled backups resume on their own once the date is reached"),
+			g.Button("Resume all").OnClick(resumeAllBackups).Disabled(len(scheduledTasks) == 0),
+			g.Button("Export jobs").OnClick(exportJobs).Disabled(len(scheduledTasks) == 0),
+			g.Tooltip("Write the settings of all scheduled backups to a file that can be imported on another machine"),
+			g.Button("Import jobs").OnClick(importJobs),
+		),
+		showImportPreview(),
+		g.Dummy(0, 30),
+		g.TreeNode("History").Layout(
+			g.Table().
+				Columns(
+					g.TableColumn("Time").Flags(g.TableColumnFlagsWidthFixed),
+					g.TableColumn("Src").Flags(g.TableColumnFlagsWidthStretch),
+					g.TableColumn("Dest").Flags(g.TableColumnFlagsWidthStretch),
+					g.TableColumn("Result").Flags(g.TableColumnFlagsWidthFixed),
+					g.TableColumn("Message").Flags(g.TableColumnFlagsWidthStretch),
+				).
+				Rows(
+					historyData...,
+				),
+		),
 	)
 }
 
 func main() {
-	if runtime.GOOS != "windows" {
-		dialog.Message(runtime.GOOS + " is currently not supported by this application").Title("OS not supported").Error()
+	var err error
+	backupScheduler, err = scheduler.Open(os.Getenv(schedulerEnv))
+	if len(os.Args) > 1 {
+		attachConsole()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(runCli(os.Args[1:]))
+	}
+	if err != nil {
+		handleError(err)
 		os.Exit(1)
 	}
 	initializeOptions()
+	// Tasks that cannot be migrated are still read with the same ID, the migration is retried on the next start
+	_ = scheduler.Migrate(backupScheduler)
+	// Tasks that cannot be renewed keep their warning in the table
+	_ = scheduler.RenewAll(backupScheduler, time.Now())
 	initializeTable()
 
 	w := g.NewMasterWindow("GoBackup", 1600, 800, 0)
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Coffee4Coffee/GoBackup/scheduler"
+)
+
+func TestFormBackupLimit(t *testing.T) {
+	initializeOptions()
+	testcases := []struct {
+		backupLimit uint8
+		want        uint8
+		wantLabel   string
+	}{
+		{0, 0, "∞"},
+		{1, 1, "1"},
+		{10, 10, "10"},
+		// Older versions stored ∞ as 11
+		{11, 0, "∞"},
+	}
+	for _, tc := range testcases {
+		job := scheduler.NewJob(tc.backupLimit, "/home/user/Documents", "/mnt/backup", false)
+		job.Schedule = scheduler.Schedule{Type: scheduler.Daily, Hour: 17}
+		scheduledTasks = []scheduler.Task{{Name: job.ID, Job: job}}
+		editScheduledBackup(0)
+		if label := backupLimitOptions[backupLimitSelected]; label != tc.wantLabel {
+			t.Errorf(`editScheduledBackup(...) with limit %v selects %v, want %v`, tc.backupLimit, label, tc.wantLabel)
+		}
+		if result := getFormJob().BackupLimit; result != tc.want {
+			t.Errorf(`getFormJob().BackupLimit = %v after editing a job with limit %v, want %v`, result, tc.backupLimit, tc.want)
+		}
+	}
+	resetForm()
+}
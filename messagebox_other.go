@@ -0,0 +1,27 @@
+//go:build !windows
+
+package main
+
+import "github.com/sqweek/dialog"
+
+// MessageBox mimics the windows message box with the dialogs of the platform, retry and yes are both a yes
+func MessageBox(caption, text string, flags uint) int {
+	switch flags & 0x0f {
+	case MB_YESNO:
+		if dialog.Message("%s", text).Title(caption).YesNo() {
+			return IDYES
+		}
+		return IDCANCEL
+	case MB_RETRYCANCEL:
+		if dialog.Message("%s", text).Title(caption).YesNo() {
+			return IDRETRY
+		}
+		return IDCANCEL
+	}
+	if flags&MB_ICONERROR != 0 {
+		dialog.Message("%s", text).Title(caption).Error()
+	} else {
+		dialog.Message("%s", text).Title(caption).Info()
+	}
+	return IDOK
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32         = syscall.NewLazyDLL("user32.dll")
+	procMessageBox = user32.NewProc("MessageBoxW")
+)
+
+func MessageBox(caption, text string, flags uint) int {
+	hwnd := uintptr(0)
+	ret, _, _ := procMessageBox.Call(
+		hwnd,
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(text))),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(caption))),
+		uintptr(flags))
+
+	return int(ret)
+}
@@ -0,0 +1,19 @@
+package scheduler
+
+import "time"
+
+// Clock tells the time and waits for it to pass, tests replace it to move time forward
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
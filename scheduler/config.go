@@ -0,0 +1,405 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes all backups of a machine in a YAML file, see PlanConfig
+type Config struct {
+	// Home is the user profile folder of the machine an exported config comes from, see ExportConfig
+	Home    string         `yaml:"home,omitempty"`
+	Backups []ConfigBackup `yaml:"backups"`
+}
+
+// ConfigBackup is a backup of a config file. Its name is the label of the job, which matches it with a scheduled backup.
+type ConfigBackup struct {
+	Name     string         `yaml:"name"`
+	Src      string         `yaml:"src"`
+	Dest     string         `yaml:"dest"`
+	Schedule ConfigSchedule `yaml:"schedule,omitempty"`
+	// Keep is the number of backups to keep, 0 keeps all of them
+	Keep      uint8 `yaml:"keep,omitempty"`
+	Overwrite bool  `yaml:"overwrite,omitempty"`
+	// Notify is always, failure or never, always when it is left out
+	Notify string `yaml:"notify,omitempty"`
+	// Rotation are the other drives of a drive rotation, see Job
+	Rotation []string `yaml:"rotation,omitempty"`
+	// AlertAfterDays is how long a drive of the rotation may go without a backup, 0 never alerts
+	AlertAfterDays uint16 `yaml:"alertAfterDays,omitempty"`
+	// Enabled is true when it is left out
+	Enabled *bool `yaml:"enabled,omitempty"`
+}
+
+// ConfigSchedule is a schedule in the format of the command line flags
+type ConfigSchedule struct {
+	Trigger   string `yaml:"trigger,omitempty"`
+	At        string `yaml:"at,omitempty"`
+	Weekdays  string `yaml:"weekdays,omitempty"`
+	Monthdays string `yaml:"monthdays,omitempty"`
+	Weeks     string `yaml:"weeks,omitempty"`
+	Expr      string `yaml:"expr,omitempty"`
+	Every     string `yaml:"every,omitempty"`
+	Until     string `yaml:"until,omitempty"`
+	Jitter    uint16 `yaml:"jitter,omitempty"`
+	TimeZone  string `yaml:"timeZone,omitempty"`
+}
+
+// ParseConfig reads a config file, every backup needs a unique name and a valid schedule.
+// The backups have to be listed, a config without backups is written as backups: [], so an empty or cut off file is not
+// taken for a config that deletes every scheduled backup.
+func ParseConfig(data []byte) (Config, error) {
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("ParseConfig: %w", errors.New("the config is empty"))
+	} else if err != nil {
+		return Config{}, fmt.Errorf("ParseConfig: %w", err)
+	}
+	if c.Backups == nil {
+		return Config{}, fmt.Errorf("ParseConfig: %w", errors.New("backups is missing, write backups: [] for a config without backups"))
+	}
+	names := map[string]bool{}
+	for i, b := range c.Backups {
+		if len(b.Name) == 0 {
+			return Config{}, fmt.Errorf("ParseConfig: %w", fmt.Errorf("backup %d has no name", i+1))
+		}
+		if names[b.Name] {
+			return Config{}, fmt.Errorf("ParseConfig: %w", fmt.Errorf("backup %q is listed twice", b.Name))
+		}
+		names[b.Name] = true
+		if _, err := b.job(Job{}); err != nil {
+			return Config{}, fmt.Errorf("ParseConfig: backup %q: %w", b.Name, err)
+		}
+	}
+	return c, nil
+}
+
+func (b ConfigBackup) enabled() bool {
+	return b.Enabled == nil || *b.Enabled
+}
+
+// job returns the job of a backup. An existing job keeps its ID and pause, a new one is created when it is empty.
+func (b ConfigBackup) job(existing Job) (Job, error) {
+	if len(b.Src) == 0 || len(b.Dest) == 0 {
+		return Job{}, errors.New("src and dest are required")
+	}
+	if b.Keep > 10 {
+		return Job{}, errors.New("keep must be between 0 and 10")
+	}
+	job := existing
+	if len(job.ID) == 0 {
+		job = NewJob(0, b.Src, b.Dest, false)
+	}
+	if b.AlertAfterDays > 0 && len(b.Rotation) == 0 {
+		return Job{}, errors.New("alertAfterDays needs a rotation")
+	}
+	job.Label, job.Src, job.Dest, job.BackupLimit, job.Overwrite = b.Name, b.Src, b.Dest, b.Keep, b.Overwrite
+	job.Rotation, job.AlertAfterDays = b.Rotation, b.AlertAfterDays
+	switch b.Notify {
+	case "", "always":
+		job.Notify = NotifyAlways
+	case NotifyFailure, NotifyNever:
+		job.Notify = b.Notify
+	default:
+		return Job{}, fmt.Errorf("invalid notify %q", b.Notify)
+	}
+	s, err := b.Schedule.schedule()
+	if err != nil {
+		return Job{}, err
+	}
+	job.Schedule = s
+	return job, nil
+}
+
+func (c ConfigSchedule) schedule() (Schedule, error) {
+	var s Schedule
+	if len(c.Trigger) > 0 {
+		if err := s.Type.UnmarshalText([]byte(c.Trigger)); err != nil {
+			return Schedule{}, err
+		}
+	} else if len(c.Expr) > 0 {
+		s.Type = Custom
+	}
+	s.Expr, s.Jitter, s.TimeZone = c.Expr, c.Jitter, c.TimeZone
+	if len(c.At) > 0 {
+		minutes, err := ParseClock(c.At)
+		if err != nil {
+			return Schedule{}, err
+		}
+		s.Hour, s.Minute = uint8(minutes/60), uint8(minutes%60)
+	}
+
+	var weekdays, weeks uint8
+	var days uint32
+	var err error
+	if len(c.Weekdays) > 0 {
+		if weekdays, err = ParseWeekdays(c.Weekdays); err != nil {
+			return Schedule{}, err
+		}
+	}
+	if len(c.Monthdays) > 0 {
+		if days, err = ParseDaysOfMonth(c.Monthdays); err != nil {
+			return Schedule{}, err
+		}
+	}
+	if len(c.Weeks) > 0 {
+		if weeks, err = ParseWeeksOfMonth(c.Weeks); err != nil {
+			return Schedule{}, err
+		}
+	}
+	switch {
+	case s.Type == Weekly && weekdays == 0:
+		return Schedule{}, errors.New("a weekly schedule needs weekdays")
+	case s.Type == Monthly && days == 0 && (weekdays == 0 || weeks == 0):
+		return Schedule{}, errors.New("a monthly schedule needs monthdays or weekdays and weeks")
+	case s.Type == Monthly && days != 0 && weeks != 0:
+		return Schedule{}, errors.New("a monthly schedule runs either on monthdays or on weekdays of weeks")
+	}
+	s.SetDays(weekdays, days, weeks)
+
+	if len(c.Every) > 0 {
+		if s.RepeatEvery, err = ParseMinutes(c.Every); err != nil {
+			return Schedule{}, err
+		}
+	}
+	if len(c.Until) > 0 {
+		until, err := ParseClock(c.Until)
+		if err != nil {
+			return Schedule{}, err
+		}
+		s.RepeatUntil = uint16(until)
+	}
+	if s.RepeatEvery > 0 && len(c.Until) == 0 {
+		return Schedule{}, errors.New("every needs until")
+	}
+	return s, s.Validate()
+}
+
+// newConfigBackup returns the backup of a config file that describes a job
+func newConfigBackup(job Job, enabled bool) ConfigBackup {
+	s := job.Schedule
+	cs := ConfigSchedule{Trigger: s.Type.String(), Expr: s.Expr, Jitter: s.Jitter, TimeZone: s.TimeZone}
+	if s.Type != Custom {
+		cs.At = fmt.Sprintf("%02d:%02d", s.Hour, s.Minute)
+	}
+	weekdays, days, weeks := s.Days()
+	switch {
+	case s.Type == Weekly:
+		cs.Weekdays = FormatWeekdays(weekdays)
+	case s.Type == Monthly && weeks != 0:
+		cs.Weekdays, cs.Weeks = FormatWeekdays(weekdays), FormatWeeksOfMonth(weeks)
+	case s.Type == Monthly:
+		cs.Monthdays = FormatDaysOfMonth(days)
+	}
+	if s.Repeats() {
+		cs.Every = strconv.Itoa(int(s.RepeatEvery))
+		cs.Until = fmt.Sprintf("%02d:%02d", s.RepeatUntil/60, s.RepeatUntil%60)
+	}
+	notify := job.Notify
+	if notify == NotifyAlways {
+		notify = "always"
+	}
+	// The window stores keeping all backups as the limit after 10, which the engine treats as no limit
+	keep := job.BackupLimit
+	if keep > 10 {
+		keep = 0
+	}
+	return ConfigBackup{
+		Name:           job.Label,
+		Src:            job.Src,
+		Dest:           job.Dest,
+		Schedule:       cs,
+		Keep:           keep,
+		Overwrite:      job.Overwrite,
+		Notify:         notify,
+		Rotation:       job.Rotation,
+		AlertAfterDays: job.AlertAfterDays,
+		Enabled:        &enabled,
+	}
+}
+
+// fields lists the settings of a backup in a fixed order, settings that are not set are empty
+func (b ConfigBackup) fields() [][2]string {
+	s := b.Schedule
+	jitter := ""
+	if s.Jitter > 0 {
+		jitter = strconv.Itoa(int(s.Jitter))
+	}
+	alertAfterDays := ""
+	if b.AlertAfterDays > 0 {
+		alertAfterDays = strconv.Itoa(int(b.AlertAfterDays))
+	}
+	return [][2]string{
+		{"src", b.Src},
+		{"dest", b.Dest},
+		{"rotation", strings.Join(b.Rotation, ", ")},
+		{"alertAfterDays", alertAfterDays},
+		{"trigger", s.Trigger},
+		{"at", s.At},
+		{"weekdays", s.Weekdays},
+		{"monthdays", s.Monthdays},
+		{"weeks", s.Weeks},
+		{"expr", s.Expr},
+		{"every", s.Every},
+		{"until", s.Until},
+		{"jitter", jitter},
+		{"timeZone", s.TimeZone},
+		{"keep", strconv.Itoa(int(b.Keep))},
+		{"overwrite", strconv.FormatBool(b.Overwrite)},
+		{"notify", b.Notify},
+		{"enabled", strconv.FormatBool(b.enabled())},
+	}
+}
+
+// Actions of a Change
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// Change is what applying a config does to a single scheduled backup
+type Change struct {
+	Action string
+	// Name is the name of the backup in the config, or the task name of a backup that is deleted
+	Name string
+	// Task is the scheduled backup that is updated or deleted
+	Task Task
+	// Job and Enabled are the backup that is created or updated
+	Job     Job
+	Enabled bool
+	Diff    []FieldChange
+}
+
+// FieldChange is a setting of a backup that changes, Old is empty for a new backup and New for a deleted one
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// String writes a change the way plan shows it, one line for the backup followed by its changed settings
+func (c Change) String() string {
+	sign := map[string]string{ChangeCreate: "+", ChangeUpdate: "~", ChangeDelete: "-"}[c.Action]
+	lines := []string{fmt.Sprintf("%v %v %v", sign, c.Action, c.Name)}
+	for _, f := range c.Diff {
+		switch {
+		case c.Action == ChangeCreate:
+			lines = append(lines, fmt.Sprintf("    %v: %v", f.Field, f.New))
+		case c.Action == ChangeDelete:
+			lines = append(lines, fmt.Sprintf("    %v: %v", f.Field, f.Old))
+		default:
+			lines = append(lines, fmt.Sprintf("    %v: %v -> %v", f.Field, orNone(f.Old), orNone(f.New)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func orNone(v string) string {
+	if len(v) == 0 {
+		return "(none)"
+	}
+	return v
+}
+
+// diffFields compares the settings of two backups, nil stands for a backup that does not exist
+func diffFields(from, to [][2]string) []FieldChange {
+	var diff []FieldChange
+	for i := 0; i < len(from) || i < len(to); i++ {
+		var f FieldChange
+		if i < len(from) {
+			f.Field, f.Old = from[i][0], from[i][1]
+		}
+		if i < len(to) {
+			f.Field, f.New = to[i][0], to[i][1]
+		}
+		if f.Old != f.New {
+			diff = append(diff, f)
+		}
+	}
+	return diff
+}
+
+// PlanConfig returns the changes that make the scheduled backups match a config. A scheduled backup is matched with
+// the backup of the config that has its label as name, scheduled backups that match no backup of the config are deleted.
+// Unreadable tasks match no backup, they are only deleted with deleteUnreadable. Unchanged backups are left out.
+func PlanConfig(c Config, tasks []Task, deleteUnreadable bool) ([]Change, error) {
+	matched := make([]bool, len(tasks))
+	var changes []Change
+	for _, b := range c.Backups {
+		i := -1
+		for j, task := range tasks {
+			if !matched[j] && task.Err == nil && task.Job.Label == b.Name {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			job, err := b.job(Job{})
+			if err != nil {
+				return nil, fmt.Errorf("PlanConfig: backup %q: %w", b.Name, err)
+			}
+			changes = append(changes, Change{Action: ChangeCreate, Name: b.Name, Job: job, Enabled: b.enabled(), Diff: diffFields(nil, newConfigBackup(job, b.enabled()).fields())})
+			continue
+		}
+		matched[i] = true
+		task := tasks[i]
+		job, err := b.job(task.Job)
+		if err != nil {
+			return nil, fmt.Errorf("PlanConfig: backup %q: %w", b.Name, err)
+		}
+		diff := diffFields(newConfigBackup(task.Job, task.Enabled).fields(), newConfigBackup(job, b.enabled()).fields())
+		if len(diff) > 0 {
+			changes = append(changes, Change{Action: ChangeUpdate, Name: b.Name, Task: task, Job: job, Enabled: b.enabled(), Diff: diff})
+		}
+	}
+	for i, task := range tasks {
+		if matched[i] || (task.Err != nil && !deleteUnreadable) {
+			continue
+		}
+		change := Change{Action: ChangeDelete, Name: task.Name, Task: task}
+		if task.Err == nil {
+			change.Diff = diffFields(newConfigBackup(task.Job, task.Enabled).fields(), nil)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// ApplyChange makes a single change of PlanConfig
+func ApplyChange(s Scheduler, c Change) error {
+	switch c.Action {
+	case ChangeCreate:
+		if _, err := s.Create(c.Job); err != nil {
+			return err
+		}
+		if !c.Enabled {
+			return s.Disable(c.Job.ID)
+		}
+	case ChangeUpdate:
+		if _, err := s.Update(c.Job); err != nil {
+			return err
+		}
+		// Some schedulers enable a task when it is updated
+		if c.Enabled {
+			return s.Enable(c.Job.ID)
+		}
+		return s.Disable(c.Job.ID)
+	case ChangeDelete:
+		// Unreadable tasks have no job ID, the scheduler also finds them by name
+		id := c.Task.Job.ID
+		if c.Task.Err != nil {
+			id = c.Task.Name
+		}
+		return s.Delete(id)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+backups:
+  - name: documents
+    src: C:\Users\Test\Documents
+    dest: E:\Backup
+    rotation:
+      - F:\Backup
+    alertAfterDays: 21
+    schedule:
+      trigger: weekly
+      weekdays: mon-fri
+      at: "08:30"
+      every: 2h
+      until: "18:00"
+    keep: 5
+    notify: failure
+  - name: photos
+    src: C:\Users\Test\Pictures
+    dest: E:\Backup
+    schedule:
+      trigger: monthly
+      monthdays: 1,L
+      at: "22:00"
+    overwrite: true
+    enabled: false
+`
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig([]byte(testConfig))
+	if err != nil || len(c.Backups) != 2 || c.Backups[1].enabled() || c.Backups[0].Schedule.Weekdays != "mon-fri" {
+		t.Fatalf(`ParseConfig(testConfig) = %+v, %v want 2 backups`, c, err)
+	}
+
+	testcases := []struct {
+		config, wantErr string
+	}{
+		{"", "the config is empty"},
+		{"home: C:\\Users\\Test\n", "backups is missing"},
+		{"backups:\n", "backups is missing"},
+		{"backups:\n  - src: a\n    dest: b\n", "has no name"},
+		{"backups:\n  - name: a\n    src: a\n    dest: b\n  - name: a\n    src: a\n    dest: b\n", "listed twice"},
+		{"backups:\n  - name: a\n    src: a\n", "src and dest are required"},
+		{"backups:\n  - name: a\n    src: a\n    dest: b\n    keep: 11\n", "keep must be between 0 and 10"},
+		{"backups:\n  - name: a\n    src: a\n    dest: b\n    notify: sometimes\n", "invalid notify"},
+		{"backups:\n  - name: a\n    src: a\n    dest: b\n    schedule:\n      trigger: weekly\n", "needs weekdays"},
+		{"backups:\n  - name: a\n    src: a\n    dest: b\n    schedule:\n      every: 30\n", "every needs until"},
+		{"backups:\n  - name: a\n    src: a\n    dest: b\n    schedule:\n      at: \"25:00\"\n", "ParseClock"},
+		{"backups:\n  - name: a\n    src: a\n    dest: b\n    retention: 3\n", "not found"},
+		{"backups:\n  - name: a\n    src: a\n    dest: b\n    alertAfterDays: 14\n", "alertAfterDays needs a rotation"},
+	}
+	for _, tc := range testcases {
+		if _, err := ParseConfig([]byte(tc.config)); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf(`ParseConfig(%q) = %v, want an error containing %q`, tc.config, err, tc.wantErr)
+		}
+	}
+}
+
+func TestPlanConfig(t *testing.T) {
+	s := NewMemoryScheduler()
+	s.Now = func() time.Time { return time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC) }
+	paused := time.Date(2022, 4, 10, 0, 0, 0, 0, time.UTC)
+	documents := NewJob(3, `C:\Users\Test\Documents`, `E:\Backup`, false)
+	documents.Label = "documents"
+	documents.PausedUntil = paused
+	documents.Schedule = Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 8, Minute: 30}
+	old := NewJob(0, `C:\Old`, `E:\Backup`, false)
+	for _, job := range []Job{documents, old} {
+		if _, err := s.Create(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, _ := s.List()
+	// An unreadable task is kept unless it is deleted explicitly
+	unreadable := Task{Name: "GoBackup 1", Err: errors.New("failed to parse job metadata")}
+	if changes, err := PlanConfig(c, append(tasks, unreadable), false); err != nil || len(changes) != 3 {
+		t.Errorf(`PlanConfig(..., false) = %v, %v want 3 changes that keep the unreadable task`, changes, err)
+	}
+	if changes, err := PlanConfig(c, append(tasks, unreadable), true); err != nil || len(changes) != 4 || changes[3].Action != ChangeDelete || changes[3].Name != unreadable.Name {
+		t.Errorf(`PlanConfig(..., true) = %v, %v want the unreadable task deleted`, changes, err)
+	}
+	changes, err := PlanConfig(c, tasks, false)
+	if err != nil || len(changes) != 3 {
+		t.Fatalf(`PlanConfig(...) = %v, %v want 3 changes`, changes, err)
+	}
+	wantActions := []string{ChangeUpdate, ChangeCreate, ChangeDelete}
+	for i, change := range changes {
+		if change.Action != wantActions[i] {
+			t.Errorf(`PlanConfig(...)[%v] = %v, want %v`, i, change.Action, wantActions[i])
+		}
+	}
+	wantDiff := "~ update documents\n    rotation: (none) -> F:\\Backup\n    alertAfterDays: (none) -> 21\n    weekdays: mon -> mon,tue,wed,thu,fri\n    every: (none) -> 120\n    until: (none) -> 18:00\n    keep: 3 -> 5\n    notify: always -> failure"
+	if result := changes[0].String(); result != wantDiff {
+		t.Errorf(`PlanConfig(...)[0].String() = %q, want %q`, result, wantDiff)
+	}
+	if changes[0].Job.ID != documents.ID || !changes[0].Job.PausedUntil.Equal(paused) {
+		t.Errorf(`PlanConfig(...)[0].Job = %+v, want the job to keep its ID and pause`, changes[0].Job)
+	}
+	if changes[2].Task.Job.ID != old.ID {
+		t.Errorf(`PlanConfig(...)[2] deletes %v, want %v`, changes[2].Task.Job.ID, old.ID)
+	}
+
+	for _, change := range changes {
+		if err := ApplyChange(s, change); err != nil {
+			t.Fatalf(`ApplyChange(%v) returned error %v`, change, err)
+		}
+	}
+	tasks, _ = s.List()
+	if len(tasks) != 2 || tasks[1].Enabled || tasks[1].Job.Label != "photos" {
+		t.Errorf(`List() after ApplyChange = %+v, want documents and the disabled photos`, tasks)
+	}
+	if changes, err := PlanConfig(c, tasks, false); err != nil || len(changes) != 0 {
+		t.Errorf(`PlanConfig(...) after ApplyChange = %v, %v want no changes`, changes, err)
+	}
+}
+
+func TestConfigUnlimitedKeep(t *testing.T) {
+	s := NewMemoryScheduler()
+	// The window keeps all backups with the limit after the largest one it offers
+	job := NewJob(11, `C:\Users\Test\Documents`, `E:\Backup`, false)
+	if _, err := s.Create(job); err != nil {
+		t.Fatal(err)
+	}
+	tasks, _ := s.List()
+	b, err := MarshalConfig(ExportConfig(tasks, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ParseConfig(b)
+	if err != nil || len(c.Backups) != 1 || c.Backups[0].Keep != 0 {
+		t.Fatalf(`ParseConfig(MarshalConfig(...)) = %+v, %v want keep 0 for %s`, c, err, b)
+	}
+	if changes, err := PlanConfig(c, tasks, false); err != nil || len(changes) != 0 {
+		t.Errorf(`PlanConfig(...) = %v, %v want no changes`, changes, err)
+	}
+}
@@ -0,0 +1,444 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMarker starts the comment line that holds the job of the cron entries below it
+const cronMarker = "# GoBackup job "
+
+// CronScheduler registers jobs in the crontab of the user. Every job is a marker comment with its ID and metadata,
+// followed by its cron entries, which are commented out while the job is disabled. Other lines are left untouched.
+type CronScheduler struct {
+	// Path of a crontab file to edit directly, the crontab command is used when it is empty
+	Path string
+	// Command is the GoBackup executable cron runs, the running executable when it is empty
+	Command string
+	// Now returns the current time, time.Now is used when it is nil
+	Now func() time.Time
+}
+
+// cronJob is a job as found in the crontab, line is the index of its marker and entries the number of cron entries below it
+type cronJob struct {
+	line    int
+	id      string
+	task    Task
+	entries int
+}
+
+func (s *CronScheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *CronScheduler) read() ([]string, error) {
+	var b []byte
+	var err error
+	if len(s.Path) > 0 {
+		b, err = os.ReadFile(s.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	} else {
+		var stderr bytes.Buffer
+		cmd := exec.Command("crontab", "-l")
+		cmd.Stderr = &stderr
+		b, err = cmd.Output()
+		// A user without a crontab is not an error
+		if err != nil && strings.Contains(stderr.String(), "no crontab") {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, &ErrRetrieveTasksFailure{Inner: err, Message: "failed to read crontab"}
+	}
+	content := strings.TrimRight(string(b), "\n")
+	if len(content) == 0 {
+		return nil, nil
+	}
+	return strings.Split(content, "\n"), nil
+}
+
+func (s *CronScheduler) write(lines []string) error {
+	content := ""
+	if len(lines) > 0 {
+		// cron ignores a last line without a newline
+		content = strings.Join(lines, "\n") + "\n"
+	}
+	if len(s.Path) > 0 {
+		return os.WriteFile(s.Path, []byte(content), 0o600)
+	}
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(content)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("write: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (s *CronScheduler) command() (string, error) {
+	if len(s.Command) > 0 {
+		return s.Command, nil
+	}
+	return os.Executable()
+}
+
+// parseCrontab finds the jobs in the lines of a crontab
+func (s *CronScheduler) parseCrontab(lines []string) []cronJob {
+	var jobs []cronJob
+	var history map[string]HistoryEntry
+	if entries, err := ReadHistory(); err == nil {
+		history = lastRuns(entries)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, cronMarker) {
+			continue
+		}
+		id, doc, _ := strings.Cut(strings.TrimPrefix(line, cronMarker), " ")
+		cj := cronJob{line: i, id: id, task: Task{Name: id}}
+		// A line that does not run the job was not written by us and is left alone
+		for cj.line+cj.entries+1 < len(lines) && strings.Contains(lines[cj.line+cj.entries+1], " -job "+cronQuote(id)+" ") {
+			cj.entries++
+		}
+		jobs = append(jobs, cj)
+		task := &jobs[len(jobs)-1].task
+
+		job, _, err := ParseJob(doc)
+		if err != nil {
+			task.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to parse job metadata"}
+			continue
+		}
+		if cj.entries == 0 {
+			task.Err = &ErrParseTaskFailure{Inner: errors.New("missing cron entry"), Message: "failed to parse task"}
+			continue
+		}
+		entries := lines[i+1 : i+1+cj.entries]
+		task.Enabled = !strings.HasPrefix(entries[0], "#")
+		// The schedule of the metadata is kept as long as the entries still run on it
+		if !sameCronEntries(job.Schedule, entries) {
+			if len(entries) > 1 {
+				err = errors.New("unsupported cron entries")
+			} else {
+				job.Schedule, err = parseCronEntry(strings.TrimLeft(entries[0], "# "))
+			}
+			if err != nil {
+				task.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to parse cron entry"}
+				continue
+			}
+		}
+		job.ID = id
+		task.Job = job
+		task.Name = parseTaskPath(job.Label, job.ID)
+		if task.Enabled {
+			after := s.now()
+			if job.PausedUntil.After(after) {
+				after = job.PausedUntil
+			}
+			task.NextRunTime = nextRunTime(job.Schedule, after)
+		}
+		if run, ok := history[id]; ok {
+			task.LastRunTime = run.Time
+			task.LastResult = run.Message
+		}
+	}
+	return jobs
+}
+
+// cronSchedules returns the first five fields of the cron entries of a schedule
+func cronSchedules(schedule Schedule) ([]string, error) {
+	exprs, err := schedule.CronExprs()
+	if err != nil {
+		return nil, fmt.Errorf("cronSchedules: %w", err)
+	}
+	if err := localOnly(schedule, "cron"); err != nil {
+		return nil, err
+	}
+	var schedules []string
+	for _, e := range exprs {
+		if e.extended() {
+			return nil, &ErrUnsupportedSchedule{Inner: fmt.Errorf("cron does not support L or # in %q", e), Message: "use the daemon scheduler for this schedule"}
+		}
+		schedules = append(schedules, e.String())
+	}
+	return schedules, nil
+}
+
+// sameCronEntries reports whether the entries of a job run on the schedule
+func sameCronEntries(schedule Schedule, entries []string) bool {
+	schedules, err := cronSchedules(schedule)
+	if err != nil || len(schedules) != len(entries) {
+		return false
+	}
+	for i, entry := range entries {
+		if !strings.HasPrefix(strings.TrimLeft(entry, "# "), schedules[i]+" ") {
+			return false
+		}
+	}
+	return true
+}
+
+// cronEntries returns the crontab lines that run the job on its schedule
+func (s *CronScheduler) cronEntries(job Job, enabled bool) ([]string, error) {
+	schedules, err := cronSchedules(job.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	command, err := s.command()
+	if err != nil {
+		return nil, fmt.Errorf("cronEntries: %w", err)
+	}
+	// -scheduled skips the run while the job is paused, cron has no start date to move
+	args := "-scheduled"
+	if job.Schedule.Jitter > 0 {
+		// cron starts every job at the full minute, -jitter waits for the random delay of the run first
+		args += " -jitter"
+	}
+	var entries []string
+	for _, schedule := range schedules {
+		entry := fmt.Sprintf("%v %v run -scheduler cron -job %v %v", schedule, cronQuote(command), cronQuote(job.ID), args)
+		if !enabled {
+			entry = "#" + entry
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseCronEntry reads the schedule of a cron entry as written by cronEntry
+func parseCronEntry(entry string) (Schedule, error) {
+	fields := strings.Fields(entry)
+	if len(fields) < 6 || fields[3] != "*" {
+		return Schedule{}, fmt.Errorf("parseCronEntry: %w", errors.New("unsupported cron entry"))
+	}
+	minute, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("parseCronEntry: %w", err)
+	}
+	hour, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("parseCronEntry: %w", err)
+	}
+	s := Schedule{Type: Daily, Hour: uint8(hour), Minute: uint8(minute)}
+	switch {
+	case fields[2] == "*" && fields[4] == "*":
+	case fields[2] == "*":
+		day, err := strconv.ParseUint(fields[4], 10, 8)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("parseCronEntry: %w", err)
+		}
+		s.Type, s.DayOfWeek = Weekly, time.Weekday(day%7)
+	case fields[4] == "*":
+		day, err := strconv.ParseUint(fields[2], 10, 8)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("parseCronEntry: %w", err)
+		}
+		s.Type, s.DayOfMonth = Monthly, uint8(day)
+	default:
+		return Schedule{}, fmt.Errorf("parseCronEntry: %w", errors.New("unsupported cron entry"))
+	}
+	if err := s.Validate(); err != nil {
+		return Schedule{}, fmt.Errorf("parseCronEntry: %w", err)
+	}
+	return s, nil
+}
+
+// cronQuote quotes an argument for sh, cron additionally turns an unescaped % into a newline
+func cronQuote(arg string) string {
+	return strings.ReplaceAll(shellQuote(arg), "%", `\%`)
+}
+
+// shellQuote quotes an argument for sh, single quotes are the only character that needs escaping
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func (s *CronScheduler) notFound(id string) error {
+	return &ErrTaskNotFound{Inner: fmt.Errorf("no cron entry for job %q", id), Message: "failed to find task"}
+}
+
+// find returns the job with the given ID or name
+func (s *CronScheduler) find(jobs []cronJob, id string) (cronJob, bool) {
+	for _, cj := range jobs {
+		if cj.id == id || cj.task.Name == id {
+			return cj, true
+		}
+	}
+	return cronJob{}, false
+}
+
+func (s *CronScheduler) List() ([]Task, error) {
+	lines, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	for _, cj := range s.parseCrontab(lines) {
+		tasks = append(tasks, cj.task)
+	}
+	return tasks, nil
+}
+
+func (s *CronScheduler) Get(id string) (Task, error) {
+	tasks, err := s.List()
+	if err != nil {
+		return Task{}, err
+	}
+	for _, task := range tasks {
+		if task.Job.ID == id || task.Name == id {
+			return task, nil
+		}
+	}
+	return Task{}, s.notFound(id)
+}
+
+// edit reads the crontab, lets change modify its lines and writes it back
+func (s *CronScheduler) edit(change func(lines []string, jobs []cronJob) ([]string, error)) error {
+	lines, err := s.read()
+	if err != nil {
+		return err
+	}
+	lines, err = change(lines, s.parseCrontab(lines))
+	if err != nil {
+		return err
+	}
+	return s.write(lines)
+}
+
+func (s *CronScheduler) Create(job Job) (Task, error) {
+	if len(job.ID) == 0 || strings.ContainsAny(job.ID, " \n") {
+		return Task{}, &ErrCreateTaskFailure{Inner: errors.New("invalid job ID"), Message: "failed to create task"}
+	}
+	err := s.edit(func(lines []string, jobs []cronJob) ([]string, error) {
+		if _, ok := s.find(jobs, job.ID); ok {
+			return nil, errors.New("a job with the same ID already exists")
+		}
+		jobLines, err := s.jobLines(job, true)
+		if err != nil {
+			return nil, err
+		}
+		return append(lines, jobLines...), nil
+	})
+	var unsupported *ErrUnsupportedSchedule
+	if errors.As(err, &unsupported) {
+		return Task{}, unsupported
+	}
+	if err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to create cron entry"}
+	}
+	return s.Get(job.ID)
+}
+
+// jobLines returns the marker of a job followed by its entries
+func (s *CronScheduler) jobLines(job Job, enabled bool) ([]string, error) {
+	doc, err := job.encode()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.cronEntries(job, enabled)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{cronMarker + job.ID + " " + doc}, entries...), nil
+}
+
+// Update replaces the job in place, it keeps its enabled state
+func (s *CronScheduler) Update(job Job) (Task, error) {
+	err := s.edit(func(lines []string, jobs []cronJob) ([]string, error) {
+		cj, ok := s.find(jobs, job.ID)
+		if !ok {
+			return nil, s.notFound(job.ID)
+		}
+		// An unreadable job is replaced by an enabled one, like a newly created job
+		jobLines, err := s.jobLines(job, cj.task.Enabled || cj.task.Err != nil)
+		if err != nil {
+			return nil, err
+		}
+		return replaceJobLines(lines, cj, jobLines...), nil
+	})
+	var notFound *ErrTaskNotFound
+	var unsupported *ErrUnsupportedSchedule
+	if errors.As(err, &unsupported) {
+		return Task{}, unsupported
+	}
+	if err != nil && !errors.As(err, &notFound) {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "failed to update crontab"}
+	}
+	if err != nil {
+		return Task{}, err
+	}
+	return s.Get(job.ID)
+}
+
+// replaceJobLines replaces the marker and entries of a job, no lines removes the job
+func replaceJobLines(lines []string, cj cronJob, replacement ...string) []string {
+	end := cj.line + 1 + cj.entries
+	result := append([]string{}, lines[:cj.line]...)
+	result = append(result, replacement...)
+	return append(result, lines[end:]...)
+}
+
+func (s *CronScheduler) Delete(id string) error {
+	err := s.edit(func(lines []string, jobs []cronJob) ([]string, error) {
+		cj, ok := s.find(jobs, id)
+		if !ok {
+			return nil, s.notFound(id)
+		}
+		return replaceJobLines(lines, cj), nil
+	})
+	var notFound *ErrTaskNotFound
+	if err != nil && !errors.As(err, &notFound) {
+		return &ErrDeleteTaskFailure{Inner: err, Message: "failed to update crontab"}
+	}
+	return err
+}
+
+// Run backs up the job right away through the engine and waits for it to finish
+func (s *CronScheduler) Run(id string) error {
+	task, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &ErrRunTaskFailure{Inner: task.Err, Message: "failed to read task"}
+	}
+	return RunJob(task.Job, s.now())
+}
+
+func (s *CronScheduler) Enable(id string) error {
+	return s.setEnabled(id, true)
+}
+
+func (s *CronScheduler) Disable(id string) error {
+	return s.setEnabled(id, false)
+}
+
+func (s *CronScheduler) setEnabled(id string, enabled bool) error {
+	err := s.edit(func(lines []string, jobs []cronJob) ([]string, error) {
+		cj, ok := s.find(jobs, id)
+		if !ok {
+			return nil, s.notFound(id)
+		}
+		if cj.task.Err != nil {
+			return nil, cj.task.Err
+		}
+		jobLines, err := s.jobLines(cj.task.Job, enabled)
+		if err != nil {
+			return nil, err
+		}
+		return replaceJobLines(lines, cj, jobLines...), nil
+	})
+	var notFound *ErrTaskNotFound
+	if err != nil && !errors.As(err, &notFound) {
+		return &ErrUpdateTaskFailure{Inner: err, Message: "failed to update crontab"}
+	}
+	return err
+}
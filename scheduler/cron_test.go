@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCronEntry(t *testing.T) {
+	testcases := []struct {
+		entry     string
+		want      Schedule
+		wantError bool
+	}{
+		{`0 17 * * * '/usr/bin/gobackup' run -scheduler cron -job 'a1' -scheduled`, Schedule{Type: Daily, Hour: 17}, false},
+		{`0 0 * * 3 '/usr/bin/gobackup' run -scheduler cron -job 'a1' -scheduled`, Schedule{Type: Weekly, DayOfWeek: time.Wednesday}, false},
+		{`0 9 * * 7 '/usr/bin/gobackup' run -scheduler cron -job 'a1' -scheduled`, Schedule{Type: Weekly, DayOfWeek: time.Sunday, Hour: 9}, false},
+		{`0 23 31 * * '/usr/bin/gobackup' run -scheduler cron -job 'a1' -scheduled`, Schedule{Type: Monthly, DayOfMonth: 31, Hour: 23}, false},
+		{`30 17 * * * '/usr/bin/gobackup' run -scheduler cron -job 'a1' -scheduled -jitter`, Schedule{Type: Daily, Hour: 17, Minute: 30}, false},
+		{`60 17 * * * '/usr/bin/gobackup' run -scheduler cron -job 'a1'`, Schedule{}, true},
+		{`*/30 17 * * * '/usr/bin/gobackup' run -scheduler cron -job 'a1'`, Schedule{}, true},
+		{`0 24 * * * '/usr/bin/gobackup' run -scheduler cron -job 'a1'`, Schedule{}, true},
+		{`0 17 1 * 1 '/usr/bin/gobackup' run -scheduler cron -job 'a1'`, Schedule{}, true},
+		{`0 17 32 * * '/usr/bin/gobackup' run -scheduler cron -job 'a1'`, Schedule{}, true},
+		{`0 17 * *`, Schedule{}, true},
+	}
+	for _, tc := range testcases {
+		result, err := parseCronEntry(tc.entry)
+		if tc.wantError && err == nil {
+			t.Errorf(`parseCronEntry(%v) did not return an error`, tc.entry)
+		}
+		if !tc.wantError && (err != nil || result != tc.want) {
+			t.Errorf(`parseCronEntry(%v) = %+v, %v want match for %+v`, tc.entry, result, err, tc.want)
+		}
+	}
+}
+
+func TestCronQuote(t *testing.T) {
+	testcases := []struct {
+		arg, want string
+	}{
+		{`/usr/bin/gobackup`, `'/usr/bin/gobackup'`},
+		{`/home/o'brien/gobackup`, `'/home/o'\''brien/gobackup'`},
+		{`/opt/100%/gobackup`, `'/opt/100\%/gobackup'`},
+	}
+	for _, tc := range testcases {
+		if result := cronQuote(tc.arg); result != tc.want {
+			t.Errorf(`cronQuote(%v) = %v, want %v`, tc.arg, result, tc.want)
+		}
+	}
+}
+
+func TestCronScheduler(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	crontab := filepath.Join(t.TempDir(), "crontab")
+	foreign := "MAILTO=admin@example.com\n# nightly cleanup\n0 3 * * * /usr/local/bin/cleanup\n"
+	if err := os.WriteFile(crontab, []byte(foreign), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2022, 4, 2, 17, 0, 0, 0, time.Local)
+	s := &CronScheduler{Path: crontab, Command: "/usr/bin/gobackup", Now: func() time.Time { return now }}
+
+	testcases := []struct {
+		schedule  Schedule
+		wantEntry string
+	}{
+		{Schedule{Type: Daily, Hour: 18}, "0 18 * * * '/usr/bin/gobackup' run -scheduler cron -job '%v' -scheduled"},
+		{Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 9}, "0 9 * * 1 '/usr/bin/gobackup' run -scheduler cron -job '%v' -scheduled"},
+		{Schedule{Type: Monthly, DayOfMonth: 31, Hour: 0}, "0 0 31 * * '/usr/bin/gobackup' run -scheduler cron -job '%v' -scheduled"},
+		{Schedule{Type: Custom, Expr: "*/30 9-17 * * 1-5"}, "*/30 9-17 * * 1-5 '/usr/bin/gobackup' run -scheduler cron -job '%v' -scheduled"},
+		{Schedule{Type: Weekly, Weekdays: 1<<time.Monday | 1<<time.Friday, Hour: 9}, "0 9 * * 1,5 '/usr/bin/gobackup' run -scheduler cron -job '%v' -scheduled"},
+		{Schedule{Type: Monthly, DaysOfMonth: 1 | 1<<14, Hour: 9}, "0 9 1,15 * * '/usr/bin/gobackup' run -scheduler cron -job '%v' -scheduled"},
+		{
+			Schedule{Type: Daily, Hour: 9, Minute: 15, RepeatEvery: 45, RepeatUntil: 11 * 60, Jitter: 5},
+			"0 10 * * * '/usr/bin/gobackup' run -scheduler cron -job '%[1]v' -scheduled -jitter\n15 9 * * * '/usr/bin/gobackup' run -scheduler cron -job '%[1]v' -scheduled -jitter\n45 10 * * * '/usr/bin/gobackup' run -scheduler cron -job '%[1]v' -scheduled -jitter",
+		},
+	}
+	var jobs []Job
+	for _, tc := range testcases {
+		job := NewJob(3, `/home/user/Documents`, `/mnt/backup`, false)
+		job.Schedule = tc.schedule
+		task, err := s.Create(job)
+		if err != nil || !reflect.DeepEqual(task.Job, job) || !task.Enabled || task.NextRunTime.IsZero() {
+			t.Fatalf(`Create(%+v) = %+v, %v want an enabled task of the job`, job, task, err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	b, _ := os.ReadFile(crontab)
+	content := string(b)
+	if !strings.HasPrefix(content, foreign) {
+		t.Errorf(`Create(...) changed the foreign lines of the crontab: %v`, content)
+	}
+	for i, tc := range testcases {
+		if entry := strings.NewReplacer("%v", jobs[i].ID, "%[1]v", jobs[i].ID).Replace(tc.wantEntry); !strings.Contains(content, "\n"+entry+"\n") || !strings.Contains(content, cronMarker+jobs[i].ID+" {") {
+			t.Errorf(`Create(...) crontab = %v, want the marker and entry %v`, content, entry)
+		}
+	}
+	if _, err := s.Create(jobs[0]); err == nil {
+		t.Errorf(`Create(jobs[0]) did not return an error for a duplicate ID`)
+	}
+
+	tasks, err := s.List()
+	if err != nil || len(tasks) != len(jobs) {
+		t.Fatalf(`List() = %v, %v want %v tasks`, tasks, err, len(jobs))
+	}
+	for i, task := range tasks {
+		if task.Err != nil || !reflect.DeepEqual(task.Job, jobs[i]) {
+			t.Errorf(`List()[%v] = %+v, %v want match for %+v`, i, task.Job, task.Err, jobs[i])
+		}
+	}
+
+	if err := s.Disable(jobs[1].ID); err != nil {
+		t.Fatalf(`Disable(%v) returned error %v`, jobs[1].ID, err)
+	}
+	jobs[1].Label = "renamed"
+	if task, err := s.Update(jobs[1]); err != nil || task.Enabled || task.Job.Label != "renamed" || !task.NextRunTime.IsZero() {
+		t.Errorf(`Update(%+v) = %+v, %v want the renamed job still disabled`, jobs[1], task, err)
+	}
+	if err := s.Enable(jobs[1].ID); err != nil {
+		t.Fatalf(`Enable(%v) returned error %v`, jobs[1].ID, err)
+	}
+	if task, _ := s.Get(jobs[1].ID); !task.Enabled || !task.NextRunTime.Equal(time.Date(2022, 4, 4, 9, 0, 0, 0, time.Local)) {
+		t.Errorf(`Enable(%v) = %+v, want an enabled task running on monday`, jobs[1].ID, task)
+	}
+
+	for _, job := range jobs {
+		if err := s.Delete(job.ID); err != nil {
+			t.Fatalf(`Delete(%v) returned error %v`, job.ID, err)
+		}
+	}
+	if b, _ := os.ReadFile(crontab); string(b) != foreign {
+		t.Errorf(`Delete(...) crontab = %v, want only the foreign lines %v`, string(b), foreign)
+	}
+	if err := s.Delete(jobs[0].ID); err == nil {
+		t.Errorf(`Delete(%v) did not return an error for a deleted job`, jobs[0].ID)
+	}
+}
+
+func TestCronSchedulerUnsupportedSchedule(t *testing.T) {
+	crontab := filepath.Join(t.TempDir(), "crontab")
+	s := &CronScheduler{Path: crontab, Command: "/usr/bin/gobackup"}
+	for _, schedule := range []Schedule{
+		{Type: Custom, Expr: "0 9 * 3,6,9,12 5L"},
+		{Type: Daily, Hour: 9, TimeZone: "Asia/Tokyo"},
+	} {
+		job := NewJob(3, `/home/user/Documents`, `/mnt/backup`, false)
+		job.Schedule = schedule
+
+		_, err := s.Create(job)
+		if _, ok := err.(*ErrUnsupportedSchedule); !ok {
+			t.Errorf(`Create(%+v) = %v, want ErrUnsupportedSchedule`, job, err)
+		}
+		if _, err := os.Stat(crontab); err == nil {
+			t.Errorf(`Create(%+v) wrote the crontab`, job)
+		}
+	}
+}
+
+func TestSupportsTimeZones(t *testing.T) {
+	testcases := []struct {
+		scheduler Scheduler
+		want      bool
+	}{
+		{&DaemonScheduler{}, true},
+		{&SystemdScheduler{}, true},
+		{&CronScheduler{}, false},
+		{&LaunchdScheduler{}, false},
+	}
+	for _, tc := range testcases {
+		if result := SupportsTimeZones(tc.scheduler); result != tc.want {
+			t.Errorf(`SupportsTimeZones(%T) = %v, want %v`, tc.scheduler, result, tc.want)
+		}
+	}
+}
+
+func TestCronSchedulerBrokenEntry(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	crontab := filepath.Join(t.TempDir(), "crontab")
+	// The entry of the job was removed by hand, the line below it is not ours
+	content := cronMarker + "a1 {\"version\":1,\"id\":\"a1\",\"src\":\"/a\",\"dest\":\"/b\"}\n0 3 * * * /usr/local/bin/cleanup\n"
+	if err := os.WriteFile(crontab, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := &CronScheduler{Path: crontab, Command: "/usr/bin/gobackup"}
+
+	tasks, err := s.List()
+	if err != nil || len(tasks) != 1 || tasks[0].Err == nil || tasks[0].Name != "a1" {
+		t.Fatalf(`List() = %+v, %v want a single unreadable task`, tasks, err)
+	}
+	if err := s.Delete("a1"); err != nil {
+		t.Fatalf(`Delete("a1") returned error %v`, err)
+	}
+	if b, _ := os.ReadFile(crontab); string(b) != "0 3 * * * /usr/local/bin/cleanup\n" {
+		t.Errorf(`Delete("a1") crontab = %v, want the foreign line kept`, string(b))
+	}
+}
@@ -0,0 +1,309 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Occurrences of a weekday in a month, bits 0-4 are the 1st to the 5th occurrence
+const (
+	allOccurrences uint8 = 1<<5 - 1
+	lastOccurrence uint8 = 1 << 5
+)
+
+// maxCronYears bounds the search for the next run, a leap day on a given weekday comes around within 28 years
+const maxCronYears = 28
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// CronExpr is a parsed cron expression with the fields minute, hour, day of month, month and day of week.
+// Besides lists, ranges, steps and names it supports L for the last day of the month, 5L for the last friday
+// and 1#2 for the second monday of the month.
+type CronExpr struct {
+	fields  [5]string
+	minutes uint64
+	hours   uint32
+	days    uint32 // bit d is day d of the month
+	months  uint16 // bit m is month m
+	lastDay bool
+	// weeks holds the occurrences of every weekday the expression runs on
+	weeks [7]uint8
+	// As in cron, the day of the month and the day of the week are combined with OR, unless one of them starts with *
+	domStar, dowStar bool
+}
+
+// ParseCronExpr parses a cron expression of five fields or one of the macros such as @daily
+func ParseCronExpr(expr string) (CronExpr, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return CronExpr{}, fmt.Errorf("ParseCronExpr: %w", fmt.Errorf("expected 5 fields, found %d", len(fields)))
+	}
+	var e CronExpr
+	copy(e.fields[:], fields)
+	minutes, err := parseCronField(fields[0], 0, 59, nil)
+	if err != nil {
+		return CronExpr{}, fmt.Errorf("ParseCronExpr: minute: %w", err)
+	}
+	hours, err := parseCronField(fields[1], 0, 23, nil)
+	if err != nil {
+		return CronExpr{}, fmt.Errorf("ParseCronExpr: hour: %w", err)
+	}
+	months, err := parseCronField(fields[3], 1, 12, monthNames)
+	if err != nil {
+		return CronExpr{}, fmt.Errorf("ParseCronExpr: month: %w", err)
+	}
+	e.minutes, e.hours, e.months = minutes, uint32(hours), uint16(months)
+	if err := e.parseDays(fields[2]); err != nil {
+		return CronExpr{}, fmt.Errorf("ParseCronExpr: day of month: %w", err)
+	}
+	if err := e.parseWeekdays(fields[4]); err != nil {
+		return CronExpr{}, fmt.Errorf("ParseCronExpr: day of week: %w", err)
+	}
+	return e, nil
+}
+
+func (e *CronExpr) parseDays(field string) error {
+	e.domStar = strings.HasPrefix(field, "*") || field == "?"
+	if field == "?" {
+		field = "*"
+	}
+	var items []string
+	for _, item := range strings.Split(field, ",") {
+		if strings.EqualFold(item, "L") {
+			e.lastDay = true
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	days, err := parseCronField(strings.Join(items, ","), 1, 31, nil)
+	e.days = uint32(days)
+	return err
+}
+
+func (e *CronExpr) parseWeekdays(field string) error {
+	e.dowStar = strings.HasPrefix(field, "*") || field == "?"
+	if field == "?" {
+		field = "*"
+	}
+	var items []string
+	for _, item := range strings.Split(field, ",") {
+		if day, n, ok := strings.Cut(item, "#"); ok {
+			weekday, err := parseCronValue(day, 0, 7, weekdayNames)
+			if err != nil {
+				return err
+			}
+			nth, err := strconv.Atoi(n)
+			if err != nil || nth < 1 || nth > 5 {
+				return fmt.Errorf("invalid occurrence %q", n)
+			}
+			e.weeks[weekday%7] |= 1 << (nth - 1)
+			continue
+		}
+		if day := strings.TrimSuffix(strings.ToUpper(item), "L"); len(day) > 0 && len(day) < len(item) {
+			weekday, err := parseCronValue(day, 0, 7, weekdayNames)
+			if err != nil {
+				return err
+			}
+			e.weeks[weekday%7] |= lastOccurrence
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	weekdays, err := parseCronField(strings.Join(items, ","), 0, 7, weekdayNames)
+	if err != nil {
+		return err
+	}
+	for d := 0; d <= 7; d++ {
+		if weekdays&(1<<d) != 0 {
+			e.weeks[d%7] |= allOccurrences
+		}
+	}
+	return nil
+}
+
+// parseCronField returns the values of a list of values, ranges and steps as bits
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, step, hasStep := strings.Cut(item, "/")
+		first, last := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			from, to, _ := strings.Cut(rng, "-")
+			var err error
+			if first, err = parseCronValue(from, min, max, names); err != nil {
+				return 0, err
+			}
+			if last, err = parseCronValue(to, min, max, names); err != nil {
+				return 0, err
+			}
+			if last < first {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			var err error
+			if first, err = parseCronValue(rng, min, max, names); err != nil {
+				return 0, err
+			}
+			// A single value with a step runs from the value to the end of the range
+			if !hasStep {
+				last = first
+			}
+		}
+		inc := 1
+		if hasStep {
+			var err error
+			inc, err = strconv.Atoi(step)
+			if err != nil || inc < 1 {
+				return 0, fmt.Errorf("invalid step %q", step)
+			}
+		}
+		for v := first; v <= last; v += inc {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseCronValue(value string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(value, name) {
+			// Months count from 1, weekdays from 0
+			return i + min, nil
+		}
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	return v, nil
+}
+
+// String returns the fields of the expression, macros are expanded
+func (e CronExpr) String() string {
+	return strings.Join(e.fields[:], " ")
+}
+
+// extended reports whether the expression uses L or # which standard cron does not know
+func (e CronExpr) extended() bool {
+	if e.lastDay {
+		return true
+	}
+	for _, occurrences := range e.weeks {
+		if occurrences != 0 && occurrences != allOccurrences {
+			return true
+		}
+	}
+	return false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func (e CronExpr) matchDay(year int, month time.Month, day int) bool {
+	if e.months&(1<<month) == 0 {
+		return false
+	}
+	last := daysIn(year, month)
+	dom := e.days&(1<<day) != 0 || (e.lastDay && day == last)
+	occurrences := e.weeks[time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday()]
+	dow := occurrences&(1<<((day-1)/7)) != 0 || (occurrences&lastOccurrence != 0 && day+7 > last)
+	if e.domStar || e.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first run of the expression after the given time in its location, or the zero time if it never runs.
+// A run at a time the clock skips when daylight saving time starts runs when the clock skips it,
+// a run at a time the clock shows twice when it ends runs only the first time.
+func (e CronExpr) Next(after time.Time) time.Time {
+	loc := after.Location()
+	for i := 0; i <= maxCronYears*366; i++ {
+		// The calendar day, days are not always 24 hours long
+		year, month, day := time.Date(after.Year(), after.Month(), after.Day()+i, 12, 0, 0, 0, time.UTC).Date()
+		if !e.matchDay(year, month, day) {
+			continue
+		}
+		for hours := e.hours; hours != 0; hours &= hours - 1 {
+			hour := bits.TrailingZeros32(hours)
+			for minutes := e.minutes; minutes != 0; minutes &= minutes - 1 {
+				t := wallClock(year, month, day, hour, bits.TrailingZeros64(minutes), loc)
+				if t.After(after) {
+					return t
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// maxOffsetChange bounds the time around a wall clock time in which its location changes its offset
+const maxOffsetChange = 36 * time.Hour
+
+// wallClock returns the first instant the clock of the location shows the given time.
+// If the clock skips the time, e.g. from 02:00 to 03:00 when daylight saving time starts, it returns the instant it skips it.
+func wallClock(year int, month time.Month, day, hour, min int, loc *time.Location) time.Time {
+	// The instants the clock shows the time at are the time as UTC minus one of the offsets around it
+	naive := time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	_, before := naive.Add(-maxOffsetChange).In(loc).Zone()
+	_, after := naive.Add(maxOffsetChange).In(loc).Zone()
+	var first time.Time
+	for _, offset := range []int{before, after} {
+		t := naive.Add(-time.Duration(offset) * time.Second).In(loc)
+		if t.Hour() == hour && t.Minute() == min && t.Day() == day && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	if !first.IsZero() {
+		return first
+	}
+	// In a gap, the offset changes between the instant of the time with the new and with the old offset
+	lo, hi := naive.Unix()-int64(after), naive.Unix()-int64(before)
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if _, offset := time.Unix(mid, 0).In(loc).Zone(); offset == after {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return time.Unix(hi, 0).In(loc)
+}
+
+// validate reports an expression that can never run, such as the 30th of february
+func (e CronExpr) validate() error {
+	if e.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return errors.New("the expression never runs")
+	}
+	return nil
+}
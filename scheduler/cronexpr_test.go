@@ -0,0 +1,354 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCronExpr(t *testing.T) {
+	testcases := []struct {
+		expr, want string
+		wantError  bool
+	}{
+		{`*/30 9-17 * * 1-5`, `*/30 9-17 * * 1-5`, false},
+		{` 0  8-18/4 * * mon-fri `, `0 8-18/4 * * mon-fri`, false},
+		{`@daily`, `0 0 * * *`, false},
+		{`@Weekly`, `0 0 * * 0`, false},
+		{`0 9 * 3,6,9,12 5L`, `0 9 * 3,6,9,12 5L`, false},
+		{`0 9 L * *`, `0 9 L * *`, false},
+		{`0 9 ? * 1#2`, `0 9 ? * 1#2`, false},
+		{`0 9 1,15 jan-jun 7`, `0 9 1,15 jan-jun 7`, false},
+		{`0 9 * *`, ``, true},
+		{`0 9 * * * *`, ``, true},
+		{`60 9 * * *`, ``, true},
+		{`0 24 * * *`, ``, true},
+		{`0 9 0 * *`, ``, true},
+		{`0 9 32 * *`, ``, true},
+		{`0 9 * 13 *`, ``, true},
+		{`0 9 * * 8`, ``, true},
+		{`0 9 * * 1#6`, ``, true},
+		{`0 9 * * 1#0`, ``, true},
+		{`0 9 * * L`, ``, true},
+		{`0 17-9 * * *`, ``, true},
+		{`*/0 9 * * *`, ``, true},
+		{`0 9 * * foo`, ``, true},
+		{`@fortnightly`, ``, true},
+	}
+	for _, tc := range testcases {
+		result, err := ParseCronExpr(tc.expr)
+		if tc.wantError && err == nil {
+			t.Errorf(`ParseCronExpr(%v) did not return an error`, tc.expr)
+		}
+		if !tc.wantError && (err != nil || result.String() != tc.want) {
+			t.Errorf(`ParseCronExpr(%v) = %v, %v want match for %v`, tc.expr, result, err, tc.want)
+		}
+	}
+}
+
+func TestCronExprNext(t *testing.T) {
+	after := time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC) // a Saturday
+	testcases := []struct {
+		expr string
+		want []time.Time
+	}{
+		{`*/30 9-17 * * 1-5`, []time.Time{
+			time.Date(2022, 4, 4, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 4, 4, 9, 30, 0, 0, time.UTC),
+			time.Date(2022, 4, 4, 10, 0, 0, 0, time.UTC),
+		}},
+		{`0 8-18/4 * * 1-5`, []time.Time{
+			time.Date(2022, 4, 4, 8, 0, 0, 0, time.UTC),
+			time.Date(2022, 4, 4, 12, 0, 0, 0, time.UTC),
+			time.Date(2022, 4, 4, 16, 0, 0, 0, time.UTC),
+			time.Date(2022, 4, 5, 8, 0, 0, 0, time.UTC),
+		}},
+		{`0 17-23/2 * * *`, []time.Time{
+			time.Date(2022, 4, 2, 19, 0, 0, 0, time.UTC),
+			time.Date(2022, 4, 2, 21, 0, 0, 0, time.UTC),
+		}},
+		// The last friday of every quarter
+		{`0 9 * 3,6,9,12 5L`, []time.Time{
+			time.Date(2022, 6, 24, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 9, 30, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 12, 30, 9, 0, 0, 0, time.UTC),
+		}},
+		{`0 9 L * *`, []time.Time{
+			time.Date(2022, 4, 30, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 5, 31, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 6, 30, 9, 0, 0, 0, time.UTC),
+		}},
+		{`0 9 * * 1#2`, []time.Time{
+			time.Date(2022, 4, 11, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 5, 9, 9, 0, 0, 0, time.UTC),
+		}},
+		{`0 9 * * mon#5`, []time.Time{
+			time.Date(2022, 5, 30, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 8, 29, 9, 0, 0, 0, time.UTC),
+		}},
+		// The day of the month and the weekday are combined with OR, unless one of them starts with *
+		{`0 9 15 * 1`, []time.Time{
+			time.Date(2022, 4, 4, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 4, 11, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 4, 15, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 4, 18, 9, 0, 0, 0, time.UTC),
+		}},
+		{`0 9 */10 * 1`, []time.Time{
+			time.Date(2022, 4, 11, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 7, 11, 9, 0, 0, 0, time.UTC),
+		}},
+		{`0 0 29 2 *`, []time.Time{
+			time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+			time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		}},
+		{`0 0 30 2 *`, nil},
+	}
+	for _, tc := range testcases {
+		e, err := ParseCronExpr(tc.expr)
+		if err != nil {
+			t.Fatalf(`ParseCronExpr(%v) returned error %v`, tc.expr, err)
+		}
+		var result []time.Time
+		for next := e.Next(after); !next.IsZero() && len(result) < len(tc.want); next = e.Next(next) {
+			result = append(result, next)
+		}
+		if len(result) != len(tc.want) {
+			t.Errorf(`%v: Next(%v) = %v, want %v`, tc.expr, after, result, tc.want)
+			continue
+		}
+		for i := range result {
+			if !result[i].Equal(tc.want[i]) {
+				t.Errorf(`%v: Next(%v) = %v, want %v`, tc.expr, after, result, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCronExprNextDST(t *testing.T) {
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2022, month, day, hour, min, 0, 0, time.UTC)
+	}
+	testcases := []struct {
+		zone  string
+		expr  string
+		after time.Time
+		want  []time.Time
+	}{
+		// Days are 23 hours long when daylight saving time starts, 09:00 stays 09:00
+		{"Europe/Berlin", `0 9 * * *`, utc(3, 26, 0, 0), []time.Time{utc(3, 26, 8, 0), utc(3, 27, 7, 0), utc(3, 28, 7, 0)}},
+		// The clock skips from 02:00 to 03:00, the run moves to 03:00
+		{"Europe/Berlin", `30 2 * * *`, utc(3, 26, 12, 0), []time.Time{utc(3, 27, 1, 0), utc(3, 28, 0, 30)}},
+		{"America/New_York", `0 2 * * *`, utc(3, 12, 12, 0), []time.Time{utc(3, 13, 7, 0), utc(3, 14, 6, 0)}},
+		// Several runs in the skipped hour run once
+		{"Europe/Berlin", `*/20 2,3 * * *`, utc(3, 26, 23, 0), []time.Time{utc(3, 27, 1, 0), utc(3, 27, 1, 20), utc(3, 27, 1, 40), utc(3, 28, 0, 0)}},
+		// The clock shows 02:00 to 03:00 twice when daylight saving time ends, the runs are not repeated
+		{"Europe/Berlin", `30 2 * * *`, utc(10, 29, 12, 0), []time.Time{utc(10, 30, 0, 30), utc(10, 31, 1, 30)}},
+		{"Europe/Berlin", `*/30 2 * * *`, utc(10, 29, 12, 0), []time.Time{utc(10, 30, 0, 0), utc(10, 30, 0, 30), utc(10, 31, 1, 0)}},
+		{"America/New_York", `0 1-3 * * *`, utc(11, 6, 0, 0), []time.Time{utc(11, 6, 5, 0), utc(11, 6, 7, 0), utc(11, 6, 8, 0), utc(11, 7, 6, 0)}},
+		// Days are 25 hours long, the last day of the month too
+		{"America/New_York", `0 23 L * *`, utc(10, 1, 12, 0), []time.Time{utc(11, 1, 3, 0), utc(12, 1, 4, 0)}},
+		// Half an hour of daylight saving time on Lord Howe Island
+		{"Australia/Lord_Howe", `15 2 * * *`, utc(10, 1, 0, 0), []time.Time{utc(10, 1, 15, 30), utc(10, 2, 15, 15)}},
+	}
+	for _, tc := range testcases {
+		loc, err := time.LoadLocation(tc.zone)
+		if err != nil {
+			t.Fatalf(`LoadLocation(%v) returned error %v`, tc.zone, err)
+		}
+		e, err := ParseCronExpr(tc.expr)
+		if err != nil {
+			t.Fatalf(`ParseCronExpr(%v) returned error %v`, tc.expr, err)
+		}
+		after := tc.after.In(loc)
+		var result []time.Time
+		for next := e.Next(after); !next.IsZero() && len(result) < len(tc.want); next = e.Next(next) {
+			result = append(result, next)
+		}
+		if len(result) != len(tc.want) {
+			t.Errorf(`%v in %v: Next(%v) = %v, want %v`, tc.expr, tc.zone, after, result, tc.want)
+			continue
+		}
+		for i := range result {
+			if !result[i].Equal(tc.want[i]) {
+				t.Errorf(`%v in %v: Next(%v) = %v, want %v`, tc.expr, tc.zone, after, result, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestScheduleTimeZone(t *testing.T) {
+	after := time.Date(2022, 3, 26, 12, 0, 0, 0, time.UTC)
+	testcases := []struct {
+		schedule Schedule
+		want     []time.Time
+	}{
+		{Schedule{Type: Daily, Hour: 9, TimeZone: "Asia/Tokyo"}, []time.Time{
+			time.Date(2022, 3, 27, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, 3, 28, 0, 0, 0, 0, time.UTC),
+		}},
+		{Schedule{Type: Weekly, DayOfWeek: time.Sunday, Hour: 9, TimeZone: "Europe/Berlin"}, []time.Time{
+			time.Date(2022, 3, 27, 7, 0, 0, 0, time.UTC),
+			time.Date(2022, 4, 3, 7, 0, 0, 0, time.UTC),
+		}},
+		{Schedule{Type: Monthly, DayOfMonth: 1, Hour: 9, TimeZone: "UTC"}, []time.Time{
+			time.Date(2022, 4, 1, 9, 0, 0, 0, time.UTC),
+			time.Date(2022, 5, 1, 9, 0, 0, 0, time.UTC),
+		}},
+		{Schedule{Type: Daily, Hour: 9, TimeZone: "Mars/Olympus_Mons"}, nil},
+	}
+	for _, tc := range testcases {
+		if err := tc.schedule.Validate(); (err != nil) != (tc.want == nil) {
+			t.Errorf(`%+v.Validate() = %v`, tc.schedule, err)
+		}
+		result := tc.schedule.NextRuns(after, len(tc.want))
+		if len(result) != len(tc.want) {
+			t.Errorf(`%+v.NextRuns(%v) = %v, want %v`, tc.schedule, after, result, tc.want)
+			continue
+		}
+		for i := range result {
+			if !result[i].Equal(tc.want[i]) || result[i].Location() != after.Location() {
+				t.Errorf(`%+v.NextRuns(%v) = %v, want %v`, tc.schedule, after, result, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCustomSchedule(t *testing.T) {
+	testcases := []struct {
+		schedule  Schedule
+		wantError bool
+	}{
+		{Schedule{Type: Custom, Expr: `*/30 9-17 * * 1-5`}, false},
+		{Schedule{Type: Custom, Expr: `@monthly`}, false},
+		{Schedule{Type: Custom}, true},
+		{Schedule{Type: Custom, Expr: `0 0 30 2 *`}, true},
+		{Schedule{Type: Custom, Expr: `0 0 * * 1#6`}, true},
+	}
+	for _, tc := range testcases {
+		err := tc.schedule.Validate()
+		if tc.wantError != (err != nil) {
+			t.Errorf(`%+v.Validate() = %v, want error %v`, tc.schedule, err, tc.wantError)
+		}
+	}
+
+	if !sameSchedule(Schedule{Type: Daily, Hour: 9}, Schedule{Type: Custom, Expr: `0 9 * * *`}) {
+		t.Errorf(`sameSchedule(daily at 9, 0 9 * * *) = false, want true`)
+	}
+	runs := Schedule{Type: Custom, Expr: `0 12 * * 6,0`}.NextRuns(time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC), 3)
+	want := []time.Time{time.Date(2022, 4, 3, 12, 0, 0, 0, time.UTC), time.Date(2022, 4, 9, 12, 0, 0, 0, time.UTC), time.Date(2022, 4, 10, 12, 0, 0, 0, time.UTC)}
+	if len(runs) != len(want) || !runs[0].Equal(want[0]) || !runs[1].Equal(want[1]) || !runs[2].Equal(want[2]) {
+		t.Errorf(`NextRuns(...) = %v, want %v`, runs, want)
+	}
+}
+
+func TestRepeatedSchedule(t *testing.T) {
+	testcases := []struct {
+		schedule  Schedule
+		want      []string
+		wantError bool
+	}{
+		{Schedule{Type: Daily, Hour: 9, Minute: 30}, []string{`30 9 * * *`}, false},
+		{Schedule{Type: Weekly, DayOfWeek: time.Friday, Hour: 9, RepeatEvery: 120, RepeatUntil: 17 * 60}, []string{`0 9,11,13,15,17 * * 5`}, false},
+		{Schedule{Type: Daily, Hour: 8, RepeatEvery: 30, RepeatUntil: 18*60 + 15}, []string{`0 8-18 * * *`, `30 8-17 * * *`}, false},
+		{Schedule{Type: Monthly, DayOfMonth: 1, Hour: 9, Minute: 15, RepeatEvery: 45, RepeatUntil: 12 * 60}, []string{`0 10 1 * *`, `15 9 1 * *`, `30 11 1 * *`, `45 10 1 * *`}, false},
+		// A repetition that ends before the second run runs once
+		{Schedule{Type: Daily, Hour: 9, RepeatEvery: 60, RepeatUntil: 9*60 + 30}, []string{`0 9 * * *`}, false},
+		{Schedule{Type: Daily, Hour: 9, Minute: 60}, nil, true},
+		{Schedule{Type: Daily, Hour: 9, RepeatEvery: 60, RepeatUntil: 8 * 60}, nil, true},
+		{Schedule{Type: Daily, Hour: 9, RepeatEvery: 60, RepeatUntil: 24 * 60}, nil, true},
+		{Schedule{Type: Custom, Expr: `0 9 * * *`, RepeatEvery: 60, RepeatUntil: 17 * 60}, nil, true},
+		{Schedule{Type: Daily, Hour: 9, Jitter: maxJitter + 1}, nil, true},
+	}
+	for _, tc := range testcases {
+		exprs, err := tc.schedule.CronExprs()
+		if tc.wantError {
+			if err == nil {
+				t.Errorf(`%+v.CronExprs() did not return an error`, tc.schedule)
+			}
+			continue
+		}
+		var result []string
+		for _, e := range exprs {
+			result = append(result, e.String())
+		}
+		if err != nil || strings.Join(result, "; ") != strings.Join(tc.want, "; ") {
+			t.Errorf(`%+v.CronExprs() = %v, %v want %v`, tc.schedule, result, err, tc.want)
+		}
+	}
+
+	runs := Schedule{Type: Daily, Hour: 9, Minute: 15, RepeatEvery: 45, RepeatUntil: 11 * 60}.NextRuns(time.Date(2022, 4, 2, 10, 0, 0, 0, time.UTC), 3)
+	want := []time.Time{time.Date(2022, 4, 2, 10, 45, 0, 0, time.UTC), time.Date(2022, 4, 3, 9, 15, 0, 0, time.UTC), time.Date(2022, 4, 3, 10, 0, 0, 0, time.UTC)}
+	if len(runs) != len(want) || !runs[0].Equal(want[0]) || !runs[1].Equal(want[1]) || !runs[2].Equal(want[2]) {
+		t.Errorf(`NextRuns(...) = %v, want %v`, runs, want)
+	}
+}
+
+func TestJitterDelay(t *testing.T) {
+	job := NewJob(0, "/a", "/b", false)
+	at := time.Date(2022, 4, 2, 9, 0, 0, 0, time.UTC)
+	if delay := JitterDelay(job, at); delay != 0 {
+		t.Errorf(`JitterDelay(%+v, %v) = %v, want no delay without jitter`, job, at, delay)
+	}
+	job.Schedule.Jitter = 10
+	delays := map[time.Duration]bool{}
+	for i := 0; i < 50; i++ {
+		run := at.AddDate(0, 0, i)
+		delay := JitterDelay(job, run)
+		if delay < 0 || delay >= 10*time.Minute {
+			t.Errorf(`JitterDelay(%+v, %v) = %v, want less than 10 minutes`, job, run, delay)
+		}
+		// The run is found again when the job starts a few seconds late
+		if again := JitterDelay(job, run.Add(20*time.Second)); again != delay {
+			t.Errorf(`JitterDelay(%+v, %v) = %v, want the delay %v of the same run`, job, run, again, delay)
+		}
+		delays[delay] = true
+	}
+	if len(delays) < 10 {
+		t.Errorf(`JitterDelay(...) returned %v different delays for 50 runs, want them spread`, len(delays))
+	}
+}
+
+func TestScheduleDays(t *testing.T) {
+	testcases := []struct {
+		schedule  Schedule
+		want      string
+		wantError bool
+	}{
+		{Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 9}, `0 9 * * 1`, false},
+		{Schedule{Type: Weekly, Weekdays: 1<<time.Monday | 1<<time.Wednesday | 1<<time.Friday, Hour: 9}, `0 9 * * 1,3,5`, false},
+		{Schedule{Type: Weekly, Weekdays: 1<<7 - 1<<1, Hour: 9}, `0 9 * * 1-6`, false},
+		{Schedule{Type: Monthly, DayOfMonth: 15, Hour: 9}, `0 9 15 * *`, false},
+		{Schedule{Type: Monthly, DaysOfMonth: 1 | 1<<14, Hour: 9}, `0 9 1,15 * *`, false},
+		{Schedule{Type: Monthly, DaysOfMonth: 1 | LastDayOfMonth, Hour: 9}, `0 9 1,L * *`, false},
+		{Schedule{Type: Monthly, DaysOfMonth: LastDayOfMonth, Hour: 9}, `0 9 L * *`, false},
+		{Schedule{Type: Monthly, Weekdays: 1 << time.Monday, WeeksOfMonth: 1, Hour: 9}, `0 9 * * 1#1`, false},
+		{Schedule{Type: Monthly, DayOfWeek: time.Friday, WeeksOfMonth: 1<<1 | LastWeekOfMonth, Hour: 9}, `0 9 * * 5#2,5L`, false},
+		{Schedule{Type: Monthly, Hour: 9}, ``, true},
+		{Schedule{Type: Monthly, DaysOfMonth: 1, WeeksOfMonth: 1, Hour: 9}, ``, true},
+		{Schedule{Type: Weekly, Weekdays: 1 << 7, Hour: 9}, ``, true},
+		{Schedule{Type: Monthly, Weekdays: 1, WeeksOfMonth: 1 << 5, Hour: 9}, ``, true},
+	}
+	for _, tc := range testcases {
+		exprs, err := tc.schedule.CronExprs()
+		if tc.wantError {
+			if err == nil {
+				t.Errorf(`%+v.CronExprs() did not return an error`, tc.schedule)
+			}
+			continue
+		}
+		if err != nil || len(exprs) != 1 || exprs[0].String() != tc.want {
+			t.Errorf(`%+v.CronExprs() = %v, %v want %v`, tc.schedule, exprs, err, tc.want)
+		}
+	}
+
+	// The first monday of the month
+	runs := Schedule{Type: Monthly, Weekdays: 1 << time.Monday, WeeksOfMonth: 1, Hour: 9}.NextRuns(time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC), 2)
+	want := []time.Time{time.Date(2022, 4, 4, 9, 0, 0, 0, time.UTC), time.Date(2022, 5, 2, 9, 0, 0, 0, time.UTC)}
+	if len(runs) != len(want) || !runs[0].Equal(want[0]) || !runs[1].Equal(want[1]) {
+		t.Errorf(`NextRuns(...) = %v, want %v`, runs, want)
+	}
+}
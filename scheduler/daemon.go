@@ -0,0 +1,451 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const daemonStateFile = "daemon.json"
+
+const (
+	// pollInterval bounds how long the daemon sleeps, so it picks up jobs changed by the app or the command line
+	pollInterval = time.Minute
+	// missedAfter is how late a run may start before it counts as missed, e.g. after the machine was asleep
+	missedAfter = 5 * time.Minute
+)
+
+// DaemonScheduler keeps jobs in a state file that the daemon runs on its own schedule, without the scheduler of the OS.
+// The app, the command line and the daemon share the state file, every change reads and writes it as a whole while it
+// holds the lock file next to it.
+type DaemonScheduler struct {
+	// Path of the state file, daemon.json in the config dir when it is empty
+	Path string
+	// Clock is the system clock when it is nil
+	Clock Clock
+	// RunFunc backs up a job, RunJob when it is nil
+	RunFunc func(job Job, now time.Time) error
+
+	mu sync.Mutex
+}
+
+type daemonState struct {
+	Jobs []daemonJob `json:"jobs"`
+}
+
+// daemonJob is a job with the state the daemon keeps for it.
+// Scheduled is the last run time of the schedule the daemon handled, whether it ran the job or counted it as missed.
+// Deferred is set when the last run was skipped because a volume was not connected, the daemon runs the job again as
+// soon as it is.
+type daemonJob struct {
+	Job         Job       `json:"job"`
+	Enabled     bool      `json:"enabled"`
+	Scheduled   time.Time `json:"scheduled"`
+	LastRunTime time.Time `json:"lastRunTime"`
+	LastResult  string    `json:"lastResult"`
+	MissedRuns  uint      `json:"missedRuns"`
+	Deferred    bool      `json:"deferred,omitempty"`
+}
+
+func (s *DaemonScheduler) clock() Clock {
+	if s.Clock != nil {
+		return s.Clock
+	}
+	return systemClock{}
+}
+
+func (s *DaemonScheduler) path() (string, error) {
+	if len(s.Path) > 0 {
+		return s.Path, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("path: %w", err)
+	}
+	return filepath.Join(configDir, appTitle, daemonStateFile), nil
+}
+
+func (s *DaemonScheduler) load() (daemonState, error) {
+	var state daemonState
+	path, err := s.path()
+	if err != nil {
+		return state, &ErrRetrieveTasksFailure{Inner: err, Message: "failed to find daemon state"}
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &state)
+	}
+	if err != nil {
+		return state, &ErrRetrieveTasksFailure{Inner: err, Message: "failed to read daemon state"}
+	}
+	return state, nil
+}
+
+// save replaces the state file in one step, so a concurrent reader never sees a partial file
+func (s *DaemonScheduler) save(state daemonState) error {
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// lock serializes the changes of the state file. The mutex covers the goroutines of this process, the lock file the
+// other processes, e.g. the command line editing a job while the daemon records a run.
+func (s *DaemonScheduler) lock() (unlock func(), err error) {
+	s.mu.Lock()
+	defer func() {
+		if err != nil {
+			s.mu.Unlock()
+		}
+	}()
+	path, err := s.path()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+		s.mu.Unlock()
+	}, nil
+}
+
+func (s *DaemonScheduler) index(state daemonState, id string) int {
+	for i, dj := range state.Jobs {
+		if dj.Job.ID == id || parseTaskPath(dj.Job.Label, dj.Job.ID) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *DaemonScheduler) notFound(id string) error {
+	return &ErrTaskNotFound{Inner: fmt.Errorf("no daemon job %q", id), Message: "failed to find task"}
+}
+
+// due returns the next run of the schedule after the last one handled, paused runs are skipped
+func (dj daemonJob) due() time.Time {
+	after := dj.Scheduled
+	if dj.Job.PausedUntil.After(after) {
+		after = dj.Job.PausedUntil
+	}
+	return nextRunTime(dj.Job.Schedule, after)
+}
+
+func (dj daemonJob) task() Task {
+	task := Task{
+		Job:         dj.Job,
+		Name:        parseTaskPath(dj.Job.Label, dj.Job.ID),
+		Enabled:     dj.Enabled,
+		LastRunTime: dj.LastRunTime,
+		MissedRuns:  dj.MissedRuns,
+		LastResult:  dj.LastResult,
+	}
+	if dj.Enabled {
+		// A time in the past is a run the daemon catches up on once it runs
+		if due := dj.due(); !due.IsZero() {
+			task.NextRunTime = due.Add(JitterDelay(dj.Job, due))
+		}
+	}
+	return task
+}
+
+func (s *DaemonScheduler) List() ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	for _, dj := range state.Jobs {
+		tasks = append(tasks, dj.task())
+	}
+	return tasks, nil
+}
+
+func (s *DaemonScheduler) Get(id string) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.load()
+	if err != nil {
+		return Task{}, err
+	}
+	i := s.index(state, id)
+	if i < 0 {
+		return Task{}, s.notFound(id)
+	}
+	return state.Jobs[i].task(), nil
+}
+
+// change loads the state, lets change modify the job with the given ID and saves it
+func (s *DaemonScheduler) change(id string, change func(dj *daemonJob) error) (Task, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "failed to lock daemon state"}
+	}
+	defer unlock()
+	state, err := s.load()
+	if err != nil {
+		return Task{}, err
+	}
+	i := s.index(state, id)
+	if i < 0 {
+		return Task{}, s.notFound(id)
+	}
+	if err := change(&state.Jobs[i]); err != nil {
+		return Task{}, err
+	}
+	if err := s.save(state); err != nil {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "failed to write daemon state"}
+	}
+	return state.Jobs[i].task(), nil
+}
+
+func (s *DaemonScheduler) Create(job Job) (Task, error) {
+	if err := job.Schedule.Validate(); err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "invalid schedule"}
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to lock daemon state"}
+	}
+	defer unlock()
+	state, err := s.load()
+	if err != nil {
+		return Task{}, err
+	}
+	if len(job.ID) == 0 || s.index(state, job.ID) >= 0 {
+		return Task{}, &ErrCreateTaskFailure{Inner: errors.New("missing or duplicate job ID"), Message: "failed to create task"}
+	}
+	// Runs before the job was created are not missed
+	dj := daemonJob{Job: job, Enabled: true, Scheduled: s.clock().Now()}
+	state.Jobs = append(state.Jobs, dj)
+	if err := s.save(state); err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to write daemon state"}
+	}
+	return dj.task(), nil
+}
+
+func (s *DaemonScheduler) Update(job Job) (Task, error) {
+	if err := job.Schedule.Validate(); err != nil {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "invalid schedule"}
+	}
+	return s.change(job.ID, func(dj *daemonJob) error {
+		dj.Job = job
+		return nil
+	})
+}
+
+func (s *DaemonScheduler) Delete(id string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return &ErrDeleteTaskFailure{Inner: err, Message: "failed to lock daemon state"}
+	}
+	defer unlock()
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	i := s.index(state, id)
+	if i < 0 {
+		return s.notFound(id)
+	}
+	state.Jobs = append(state.Jobs[:i], state.Jobs[i+1:]...)
+	if err := s.save(state); err != nil {
+		return &ErrDeleteTaskFailure{Inner: err, Message: "failed to write daemon state"}
+	}
+	return nil
+}
+
+// Run backs up the job right away and waits for it to finish, it does not change the schedule of the job
+func (s *DaemonScheduler) Run(id string) error {
+	task, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	return s.run(task.Job)
+}
+
+// run backs up a job and records the result, the lock is not held while the backup runs
+func (s *DaemonScheduler) run(job Job) error {
+	now := s.clock().Now()
+	runFunc := s.RunFunc
+	if runFunc == nil {
+		runFunc = RunJob
+	}
+	runErr := runFunc(job, now)
+	var volumeErr *ErrVolumeNotFound
+	_, err := s.change(job.ID, func(dj *daemonJob) error {
+		dj.LastRunTime = now
+		dj.LastResult = "OK"
+		dj.Deferred = errors.As(runErr, &volumeErr)
+		switch {
+		case dj.Deferred:
+			dj.LastResult = "Waiting: " + volumeErr.Message
+		case runErr != nil:
+			dj.LastResult = runErr.Error()
+		}
+		return nil
+	})
+	// The job may have been deleted while it ran
+	var notFound *ErrTaskNotFound
+	if err != nil && !errors.As(err, &notFound) {
+		return err
+	}
+	if runErr != nil {
+		return &ErrRunTaskFailure{Inner: runErr, Message: "failed to run task"}
+	}
+	return nil
+}
+
+func (s *DaemonScheduler) Enable(id string) error {
+	_, err := s.change(id, func(dj *daemonJob) error {
+		if !dj.Enabled {
+			// Runs while the job was disabled are not missed
+			dj.Enabled = true
+			dj.Scheduled = s.clock().Now()
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *DaemonScheduler) Disable(id string) error {
+	_, err := s.change(id, func(dj *daemonJob) error {
+		dj.Enabled = false
+		return nil
+	})
+	return err
+}
+
+// RunDaemon runs the jobs of the scheduler on their schedule until the context is canceled.
+// A job that missed runs while the daemon was not running is run once as soon as possible, and the missed runs are counted.
+func RunDaemon(ctx context.Context, s *DaemonScheduler) error {
+	for {
+		next, err := s.runDue()
+		if err != nil {
+			return err
+		}
+		wait := pollInterval
+		if !next.IsZero() && next.Sub(s.clock().Now()) < wait {
+			wait = next.Sub(s.clock().Now())
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.clock().After(wait):
+		}
+	}
+}
+
+// runDue runs every job that is due and returns the next time a job is due
+func (s *DaemonScheduler) runDue() (time.Time, error) {
+	var due []Job
+	err := s.changeAll(func(dj *daemonJob, now time.Time) bool {
+		if !dj.Enabled {
+			return false
+		}
+		var runs uint
+		var last time.Time
+		// A run is due once its random delay has passed too
+		for t := dj.due(); !t.IsZero() && !t.Add(JitterDelay(dj.Job, t)).After(now); t = nextRunTime(dj.Job.Schedule, t) {
+			runs++
+			last = t
+		}
+		if runs == 0 {
+			// A deferred job runs once its volumes are connected, the check is cheap enough for every poll
+			if dj.Deferred {
+				if _, _, err := resolveJob(dj.Job); err == nil {
+					due = append(due, dj.Job)
+				}
+			}
+			return false
+		}
+		// Only the latest run counts as on time, and only if it is not too late
+		missed := runs - 1
+		if now.Sub(last.Add(JitterDelay(dj.Job, last))) > missedAfter {
+			missed++
+		}
+		dj.MissedRuns += missed
+		dj.Scheduled = last
+		due = append(due, dj.Job)
+		return true
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, job := range due {
+		// A failed backup is recorded in the history and the state, it does not stop the daemon
+		s.run(job)
+	}
+
+	tasks, err := s.List()
+	if err != nil {
+		return time.Time{}, err
+	}
+	var next time.Time
+	for _, task := range tasks {
+		if task.Enabled && !task.NextRunTime.IsZero() && (next.IsZero() || task.NextRunTime.Before(next)) {
+			next = task.NextRunTime
+		}
+	}
+	return next, nil
+}
+
+// changeAll lets change modify every job and saves the state if change reports a modification
+func (s *DaemonScheduler) changeAll(change func(dj *daemonJob, now time.Time) bool) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return &ErrUpdateTaskFailure{Inner: err, Message: "failed to lock daemon state"}
+	}
+	defer unlock()
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	now := s.clock().Now()
+	changed := false
+	for i := range state.Jobs {
+		if change(&state.Jobs[i], now) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := s.save(state); err != nil {
+		return &ErrUpdateTaskFailure{Inner: err, Message: "failed to write daemon state"}
+	}
+	return nil
+}
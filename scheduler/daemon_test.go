@@ -0,0 +1,257 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock hands every sleep to the test, which moves the time forward and wakes the sleeper
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps chan fakeSleep
+}
+
+type fakeSleep struct {
+	d  time.Duration
+	ch chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, sleeps: make(chan fakeSleep)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.sleeps <- fakeSleep{d, ch}
+	return ch
+}
+
+func TestDaemonCatchUp(t *testing.T) {
+	start := time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC) // a Saturday
+	clock := newFakeClock(start)
+	var ran []time.Time
+	s := &DaemonScheduler{
+		Path:    filepath.Join(t.TempDir(), "daemon.json"),
+		Clock:   clock,
+		RunFunc: func(job Job, now time.Time) error { ran = append(ran, now); return nil },
+	}
+	daily := NewJob(0, "/a", "/b", false)
+	daily.Schedule = Schedule{Type: Daily, Hour: 18}
+	weekly := NewJob(0, "/c", "/d", false)
+	weekly.Schedule = Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 9}
+	for _, job := range []Job{daily, weekly} {
+		if _, err := s.Create(job); err != nil {
+			t.Fatalf(`Create(%+v) returned error %v`, job, err)
+		}
+	}
+
+	// On time
+	clock.Set(start.Add(time.Hour + time.Minute))
+	next, err := s.runDue()
+	if err != nil || len(ran) != 1 || !next.Equal(time.Date(2022, 4, 3, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf(`runDue() = %v, %v ran %v want a run and the next on sunday`, next, err, ran)
+	}
+	if task, _ := s.Get(daily.ID); task.MissedRuns != 0 || task.LastResult != "OK" {
+		t.Errorf(`runDue() task = %+v, want no missed runs`, task)
+	}
+
+	// The machine was off from saturday to wednesday, the daily job missed 3 runs and the weekly job 1, each runs once
+	clock.Set(time.Date(2022, 4, 6, 12, 0, 0, 0, time.UTC))
+	ran = nil
+	if _, err := s.runDue(); err != nil || len(ran) != 2 {
+		t.Fatalf(`runDue() = %v ran %v want both jobs caught up once`, err, ran)
+	}
+	if task, _ := s.Get(daily.ID); task.MissedRuns != 3 || !task.NextRunTime.Equal(time.Date(2022, 4, 6, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf(`runDue() daily task = %+v, want 3 missed runs and the next today`, task)
+	}
+	if task, _ := s.Get(weekly.ID); task.MissedRuns != 1 {
+		t.Errorf(`runDue() weekly task = %+v, want 1 missed run`, task)
+	}
+
+	// Runs while disabled or paused are not missed
+	s.Disable(weekly.ID)
+	daily.PausedUntil = time.Date(2022, 4, 9, 0, 0, 0, 0, time.UTC)
+	s.Update(daily)
+	clock.Set(time.Date(2022, 4, 20, 12, 0, 0, 0, time.UTC))
+	s.Enable(weekly.ID)
+	ran = nil
+	if _, err := s.runDue(); err != nil || len(ran) != 1 {
+		t.Fatalf(`runDue() = %v ran %v want only the paused daily job caught up`, err, ran)
+	}
+	if task, _ := s.Get(daily.ID); task.MissedRuns != 3+11 {
+		t.Errorf(`runDue() daily task = %+v, want the runs from the end of the pause missed`, task)
+	}
+	if task, _ := s.Get(weekly.ID); task.MissedRuns != 1 || !task.NextRunTime.Equal(time.Date(2022, 4, 25, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf(`runDue() weekly task = %+v, want no runs missed while disabled`, task)
+	}
+}
+
+func TestDaemonJitter(t *testing.T) {
+	start := time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	var ran []time.Time
+	s := &DaemonScheduler{
+		Path:    filepath.Join(t.TempDir(), "daemon.json"),
+		Clock:   clock,
+		RunFunc: func(job Job, now time.Time) error { ran = append(ran, now); return nil },
+	}
+	job := NewJob(0, "/a", "/b", false)
+	job.Schedule = Schedule{Type: Daily, Hour: 18, Jitter: 10}
+	scheduled := time.Date(2022, 4, 2, 18, 0, 0, 0, time.UTC)
+	delay := JitterDelay(job, scheduled)
+	task, err := s.Create(job)
+	if err != nil || !task.NextRunTime.Equal(scheduled.Add(delay)) {
+		t.Fatalf(`Create(%+v) = %+v, %v want the next run delayed by %v`, job, task, err, delay)
+	}
+
+	// The run waits for its delay and is not missed because of it
+	clock.Set(scheduled.Add(delay - time.Second))
+	if _, err := s.runDue(); err != nil || len(ran) != 0 {
+		t.Fatalf(`runDue() = %v ran %v want no run before the delay`, err, ran)
+	}
+	clock.Set(scheduled.Add(delay + time.Minute))
+	if _, err := s.runDue(); err != nil || len(ran) != 1 {
+		t.Fatalf(`runDue() = %v ran %v want the delayed run`, err, ran)
+	}
+	if task, _ := s.Get(job.ID); task.MissedRuns != 0 {
+		t.Errorf(`runDue() task = %+v, want no missed runs`, task)
+	}
+}
+
+func TestDaemonDefer(t *testing.T) {
+	start := time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	mounted := map[string]string{}
+	stubVolumes(t, mounted)
+	var ran []time.Time
+	s := &DaemonScheduler{
+		Path:  filepath.Join(t.TempDir(), "daemon.json"),
+		Clock: clock,
+		// The engine skips the run when the volume is not connected
+		RunFunc: func(job Job, now time.Time) error {
+			if _, err := expandJob(job); err != nil {
+				return err
+			}
+			ran = append(ran, now)
+			return nil
+		},
+	}
+	job := NewJob(0, "/a", "{Label:BACKUP}/GoBackup", false)
+	job.Schedule = Schedule{Type: Daily, Hour: 18}
+	if _, err := s.Create(job); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Set(start.Add(time.Hour))
+	if _, err := s.runDue(); err != nil || len(ran) != 0 {
+		t.Fatalf(`runDue() = %v ran %v want the run skipped`, err, ran)
+	}
+	if task, _ := s.Get(job.ID); task.LastResult != `Waiting: volume "BACKUP" is not connected` || task.MissedRuns != 0 {
+		t.Errorf(`runDue() task = %+v, want the run deferred`, task)
+	}
+
+	// The job waits until the volume is connected, then it runs once
+	clock.Set(start.Add(2 * time.Hour))
+	if _, err := s.runDue(); err != nil || len(ran) != 0 {
+		t.Fatalf(`runDue() = %v ran %v want no run without the volume`, err, ran)
+	}
+	mounted["BACKUP"] = "/media/backup"
+	clock.Set(start.Add(3 * time.Hour))
+	for i := 0; i < 2; i++ {
+		if _, err := s.runDue(); err != nil || len(ran) != 1 {
+			t.Fatalf(`runDue() = %v ran %v want a single run once the volume is connected`, err, ran)
+		}
+	}
+	if task, _ := s.Get(job.ID); task.LastResult != "OK" {
+		t.Errorf(`runDue() task = %+v, want the deferred run done`, task)
+	}
+}
+
+func TestRunDaemon(t *testing.T) {
+	start := time.Date(2022, 4, 2, 17, 58, 30, 0, time.UTC)
+	clock := newFakeClock(start)
+	ran := make(chan time.Time, 1)
+	s := &DaemonScheduler{
+		Path:  filepath.Join(t.TempDir(), "daemon.json"),
+		Clock: clock,
+		RunFunc: func(job Job, now time.Time) error {
+			ran <- now
+			return errors.New("disk full")
+		},
+	}
+	job := NewJob(0, "/a", "/b", false)
+	job.Schedule = Schedule{Type: Daily, Hour: 18}
+	s.Create(job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- RunDaemon(ctx, s) }()
+
+	// The daemon polls every minute and then sleeps exactly until the run
+	for _, want := range []time.Duration{time.Minute, 30 * time.Second} {
+		sleep := <-clock.sleeps
+		if sleep.d != want {
+			t.Errorf(`RunDaemon(...) slept %v, want %v`, sleep.d, want)
+		}
+		clock.Set(clock.Now().Add(sleep.d))
+		sleep.ch <- clock.Now()
+	}
+	if now := <-ran; !now.Equal(time.Date(2022, 4, 2, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf(`RunDaemon(...) ran at %v, want 18:00`, now)
+	}
+	// A failed backup does not stop the daemon
+	<-clock.sleeps
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf(`RunDaemon(...) returned error %v`, err)
+	}
+	if task, _ := s.Get(job.ID); task.LastResult != "disk full" || task.MissedRuns != 0 {
+		t.Errorf(`RunDaemon(...) task = %+v, want the failed run recorded`, task)
+	}
+}
+
+func TestDaemonLock(t *testing.T) {
+	// Every scheduler stands for another process with its own mutex, only the lock file keeps their changes apart
+	path := filepath.Join(t.TempDir(), daemonStateFile)
+	clock := newFakeClock(time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC))
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := &DaemonScheduler{Path: path, Clock: clock}
+			_, err := s.Create(NewJob(0, `C:\Documents`, `E:\Backup`, false))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf(`Create(...) returned error %v`, err)
+		}
+	}
+	s := &DaemonScheduler{Path: path, Clock: clock}
+	if tasks, err := s.List(); err != nil || len(tasks) != n {
+		t.Errorf(`List() = %v tasks, %v want %v, no change may be lost`, len(tasks), err, n)
+	}
+}
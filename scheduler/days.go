@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Days returns the weekdays, days of the month and weeks of the month of a schedule as bits, see SetDays.
+// A single weekday or day of the month is returned as its bit.
+func (s Schedule) Days() (weekdays uint8, days uint32, weeks uint8) {
+	weekdays = s.Weekdays
+	if weekdays == 0 {
+		weekdays = 1 << s.DayOfWeek
+	}
+	days = s.DaysOfMonth
+	if days == 0 && s.DayOfMonth > 0 {
+		days = 1 << (s.DayOfMonth - 1)
+	}
+	return weekdays, days, s.WeeksOfMonth
+}
+
+// SetDays sets the days a weekly or monthly schedule runs on, a single weekday or day of the month is stored as before.
+// A monthly schedule with weeks runs on the weekdays of those weeks, otherwise on the days of the month.
+func (s *Schedule) SetDays(weekdays uint8, days uint32, weeks uint8) {
+	s.DayOfWeek = time.Weekday(bits.TrailingZeros8(weekdays) % 8)
+	s.DayOfMonth = uint8(bits.TrailingZeros32(days&^LastDayOfMonth)%32) + 1
+	s.Weekdays, s.DaysOfMonth, s.WeeksOfMonth = 0, 0, 0
+	switch {
+	case s.Type == Weekly && bits.OnesCount8(weekdays) != 1:
+		s.Weekdays = weekdays
+	case s.Type == Monthly && weeks != 0:
+		s.Weekdays, s.WeeksOfMonth = weekdays, weeks
+	case s.Type == Monthly && (bits.OnesCount32(days) != 1 || days&LastDayOfMonth != 0):
+		s.DaysOfMonth = days
+	}
+}
+
+// ParseWeekdays reads a list of weekdays such as "mon,wed,fri" or "mon-fri" into the bits of Schedule.Weekdays, 0 is sunday
+func ParseWeekdays(list string) (uint8, error) {
+	mask, err := parseDayList(list, func(v string) (int, error) {
+		for d, name := range weekdayNames {
+			if strings.EqualFold(v, name) || strings.EqualFold(v, time.Weekday(d).String()) {
+				return d, nil
+			}
+		}
+		return parseDayNumber(v, 0, 6)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ParseWeekdays: %w", err)
+	}
+	return uint8(mask), nil
+}
+
+// ParseDaysOfMonth reads a list of days of the month such as "1,15,L" or "1-5" into the bits of Schedule.DaysOfMonth
+func ParseDaysOfMonth(list string) (uint32, error) {
+	mask, err := parseDayList(list, func(v string) (int, error) {
+		if strings.EqualFold(v, "L") {
+			return bits.TrailingZeros32(LastDayOfMonth), nil
+		}
+		d, err := parseDayNumber(v, 1, 31)
+		return d - 1, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ParseDaysOfMonth: %w", err)
+	}
+	return uint32(mask), nil
+}
+
+// ParseWeeksOfMonth reads a list of weeks of the month such as "1,3" or "1,L" into the bits of Schedule.WeeksOfMonth
+func ParseWeeksOfMonth(list string) (uint8, error) {
+	mask, err := parseDayList(list, func(v string) (int, error) {
+		if strings.EqualFold(v, "L") {
+			return bits.TrailingZeros8(LastWeekOfMonth), nil
+		}
+		n, err := parseDayNumber(v, 1, 4)
+		return n - 1, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ParseWeeksOfMonth: %w", err)
+	}
+	return uint8(mask), nil
+}
+
+// FormatWeekdays writes the bits of Schedule.Weekdays as a list that ParseWeekdays reads
+func FormatWeekdays(mask uint8) string {
+	return formatDayList(uint64(mask), func(b int) string { return weekdayNames[b] })
+}
+
+// FormatDaysOfMonth writes the bits of Schedule.DaysOfMonth as a list that ParseDaysOfMonth reads
+func FormatDaysOfMonth(mask uint32) string {
+	return formatDayList(uint64(mask), func(b int) string {
+		if 1<<b == LastDayOfMonth {
+			return "L"
+		}
+		return strconv.Itoa(b + 1)
+	})
+}
+
+// FormatWeeksOfMonth writes the bits of Schedule.WeeksOfMonth as a list that ParseWeeksOfMonth reads
+func FormatWeeksOfMonth(mask uint8) string {
+	return formatDayList(uint64(mask), func(b int) string {
+		if 1<<b == LastWeekOfMonth {
+			return "L"
+		}
+		return strconv.Itoa(b + 1)
+	})
+}
+
+func formatDayList(mask uint64, format func(int) string) string {
+	var items []string
+	for ; mask != 0; mask &= mask - 1 {
+		items = append(items, format(bits.TrailingZeros64(mask)))
+	}
+	return strings.Join(items, ",")
+}
+
+// ParseClock returns the minutes after midnight of a time in the format HH:MM
+func ParseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("ParseClock: %w", err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseMinutes reads a number of minutes or a duration such as 2h
+func ParseMinutes(v string) (uint16, error) {
+	if n, err := strconv.ParseUint(v, 10, 16); err == nil {
+		return uint16(n), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("ParseMinutes: %w", err)
+	}
+	if d < 0 || d%time.Minute != 0 || d >= (1<<16)*time.Minute {
+		return 0, fmt.Errorf("ParseMinutes: %w", errors.New("not a number of whole minutes"))
+	}
+	return uint16(d / time.Minute), nil
+}
+
+// parseDayList reads a comma separated list of values and ranges, parse returns the bit of a value
+func parseDayList(list string, parse func(string) (int, error)) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			return 0, errors.New("empty list item")
+		}
+		from, to, isRange := strings.Cut(item, "-")
+		first, err := parse(strings.TrimSpace(from))
+		if err != nil {
+			return 0, err
+		}
+		last := first
+		if isRange {
+			if last, err = parse(strings.TrimSpace(to)); err != nil {
+				return 0, err
+			}
+		}
+		if last < first {
+			return 0, fmt.Errorf("invalid range %q", item)
+		}
+		for b := first; b <= last; b++ {
+			mask |= 1 << b
+		}
+	}
+	return mask, nil
+}
+
+func parseDayNumber(v string, min, max int) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("invalid day %q", v)
+	}
+	return n, nil
+}
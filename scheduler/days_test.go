@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDays(t *testing.T) {
+	testcases := []struct {
+		parse   func(string) (uint64, error)
+		list    string
+		want    uint64
+		wantErr bool
+	}{
+		{parseWeekdaysMask, "mon,wed,fri", 1<<1 | 1<<3 | 1<<5, false},
+		{parseWeekdaysMask, "Monday-Friday", 0b0111110, false},
+		{parseWeekdaysMask, "0, 6", 1 | 1<<6, false},
+		{parseWeekdaysMask, "fri-mon", 0, true},
+		{parseWeekdaysMask, "7", 0, true},
+		{parseWeekdaysMask, "mon,", 0, true},
+		{parseDaysOfMonthMask, "1,15,L", 1 | 1<<14 | uint64(LastDayOfMonth), false},
+		{parseDaysOfMonthMask, "1-3", 0b111, false},
+		{parseDaysOfMonthMask, "0", 0, true},
+		{parseDaysOfMonthMask, "32", 0, true},
+		{parseWeeksOfMonthMask, "1,L", 1 | uint64(LastWeekOfMonth), false},
+		{parseWeeksOfMonthMask, "2-4", 0b1110, false},
+		{parseWeeksOfMonthMask, "5", 0, true},
+	}
+	for _, tc := range testcases {
+		result, err := tc.parse(tc.list)
+		if result != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf(`parse(%v) = %b, %v, want %b, error %v`, tc.list, result, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func parseWeekdaysMask(list string) (uint64, error) {
+	mask, err := ParseWeekdays(list)
+	return uint64(mask), err
+}
+
+func parseDaysOfMonthMask(list string) (uint64, error) {
+	mask, err := ParseDaysOfMonth(list)
+	return uint64(mask), err
+}
+
+func parseWeeksOfMonthMask(list string) (uint64, error) {
+	mask, err := ParseWeeksOfMonth(list)
+	return uint64(mask), err
+}
+
+func TestSetDays(t *testing.T) {
+	testcases := []struct {
+		s                Schedule
+		weekdays         uint8
+		days             uint32
+		weeks            uint8
+		wantDayOfWeek    time.Weekday
+		wantDayOfMonth   uint8
+		wantWeekdays     uint8
+		wantDaysOfMonth  uint32
+		wantWeeksOfMonth uint8
+	}{
+		// A single day is stored as before
+		{Schedule{Type: Weekly}, 1 << 3, 1, 0, time.Wednesday, 1, 0, 0, 0},
+		{Schedule{Type: Weekly}, 1<<1 | 1<<5, 1, 0, time.Monday, 1, 1<<1 | 1<<5, 0, 0},
+		{Schedule{Type: Monthly}, 1, 1 << 14, 0, time.Sunday, 15, 0, 0, 0},
+		{Schedule{Type: Monthly}, 1, 1 | LastDayOfMonth, 0, time.Sunday, 1, 0, 1 | LastDayOfMonth, 0},
+		{Schedule{Type: Monthly, DaysOfMonth: 0b11}, 1 << 5, 1, LastWeekOfMonth, time.Friday, 1, 1 << 5, 0, LastWeekOfMonth},
+	}
+	for _, tc := range testcases {
+		s := tc.s
+		s.SetDays(tc.weekdays, tc.days, tc.weeks)
+		if s.DayOfWeek != tc.wantDayOfWeek || s.DayOfMonth != tc.wantDayOfMonth || s.Weekdays != tc.wantWeekdays || s.DaysOfMonth != tc.wantDaysOfMonth || s.WeeksOfMonth != tc.wantWeeksOfMonth {
+			t.Errorf(`SetDays(%b, %b, %b) = %+v, want other days`, tc.weekdays, tc.days, tc.weeks, s)
+		}
+		weekdays, days, weeks := s.Days()
+		if s.Type == Weekly && weekdays != tc.weekdays || s.Type == Monthly && tc.weeks == 0 && days != tc.days || weeks != tc.weeks {
+			t.Errorf(`Days() of %+v = %b, %b, %b, want the days it was set to`, s, weekdays, days, weeks)
+		}
+	}
+}
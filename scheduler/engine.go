@@ -0,0 +1,250 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Exit codes of a run as the engine records them in the history.
+// Entries of older versions that ran xcopy use the same values.
+const (
+	exitCodeOk = 0
+	// exitCodeNoFiles is a run that found no files in src
+	exitCodeNoFiles = 1
+	// exitCodeMissingFiles is a run whose src is missing, cannot be resolved or contains dest
+	exitCodeMissingFiles = 4
+	// exitCodeWriteFailure is a run that failed to copy src
+	exitCodeWriteFailure = 5
+	// exitCodeVolumeMissing is a run that was skipped because the volume of a path is not connected
+	exitCodeVolumeMissing = 6
+)
+
+const snapshotTimeFormat = "20060102_150405"
+
+// notify shows a desktop notification about a run, tests replace it
+var notify = systemNotify
+
+// RunJob backs up the src folder of a job and records the run in the history.
+// The folder is copied to dest\folder and, unless the job overwrites, renamed to a timestamped snapshot
+// of which the oldest are removed beyond the backup limit.
+// Placeholders in the paths are expanded first, the history records the expanded paths. The run is skipped with an
+// ErrVolumeNotFound when the volume of a path is not connected.
+// A job with a rotation backs up to the first of its drives that is connected, so the backup limit applies to each drive
+// on its own, and alerts about the drives that have gone without a backup for longer than its alert period.
+func RunJob(job Job, now time.Time) error {
+	entry := HistoryEntry{Time: now, JobID: job.ID, Src: job.Src, Dest: strings.Join(job.Dests(), " or ")}
+	expanded, disk, err := resolveJob(job)
+	var volumeErr *ErrVolumeNotFound
+	switch {
+	case err == nil:
+		entry.Src, entry.Dest, entry.Disk = expanded.Src, expanded.Dest, disk
+		err = runJob(expanded, now, &entry)
+	case errors.As(err, &volumeErr):
+		entry.ExitCode = exitCodeVolumeMissing
+		entry.Message = "Your folder " + job.Src + " has not been backed up to " + entry.Dest + " because the " + volumeErr.Message + ". The backup has been skipped, connect the drive before its next run."
+		appendHistory(entry)
+		if job.Notify != NotifyNever {
+			notify("Your backup was skipped", entry.Message)
+		}
+		alertOverdueDrives(job, now)
+		return volumeErr
+	default:
+		entry.ExitCode = exitCodeMissingFiles
+		entry.Message = "Your folder " + job.Src + " has not been backed up to " + entry.Dest + ". The path could not be resolved: " + expandReason(err)
+		err = fmt.Errorf("RunJob: %w", err)
+	}
+	appendHistory(entry)
+	alertOverdueDrives(job, now)
+	switch {
+	case !entry.Success() && job.Notify != NotifyNever:
+		notify("Your backup has failed", entry.Message)
+	case entry.Success() && job.Notify == NotifyAlways:
+		notify("Your backup was successful", entry.Message)
+	}
+	if err != nil {
+		return &ErrRunBackupFailure{Inner: err, Message: entry.Message}
+	}
+	return nil
+}
+
+// RunBackupOnce runs a job through the engine without scheduling it and waits for it to finish, the same way a scheduled
+// run of the job does
+func RunBackupOnce(job Job) error {
+	return RunJob(job, time.Now())
+}
+
+func runJob(job Job, now time.Time, entry *HistoryEntry) error {
+	folder := folderName(job.Src)
+	destPath := filepath.Join(job.Dest, folder)
+	contentFailure := "Your folder " + job.Src + " has not been backed up to " + job.Dest + ". "
+
+	info, err := os.Stat(job.Src)
+	if err != nil || !info.IsDir() {
+		entry.ExitCode = exitCodeMissingFiles
+		entry.Message = contentFailure + "The folder does not exist anymore."
+		return fmt.Errorf("runJob: %w", errors.New("src is not a folder"))
+	}
+	if insideFolder(job.Dest, job.Src) {
+		entry.ExitCode = exitCodeMissingFiles
+		entry.Message = contentFailure + "The destination is inside the folder that is backed up."
+		return fmt.Errorf("runJob: %w", errors.New("cyclic copy, dest is inside src"))
+	}
+	copied, err := copyDir(job.Src, destPath)
+	if err != nil {
+		entry.ExitCode = exitCodeWriteFailure
+		entry.Message = contentFailure + "A disk write error occurred."
+		return fmt.Errorf("runJob: %w", err)
+	}
+	if copied == 0 {
+		entry.ExitCode = exitCodeNoFiles
+		entry.Message = contentFailure + "No files were found to copy."
+		return fmt.Errorf("runJob: %w", errors.New("no files were found to copy"))
+	}
+
+	entry.Message = "Your folder " + job.Src + " has been backed up to " + job.Dest + ". "
+	if job.Overwrite {
+		entry.Message += "There were no errors."
+		return nil
+	}
+	if err := os.Rename(destPath, filepath.Join(job.Dest, folder+"-"+now.Format(snapshotTimeFormat))); err != nil {
+		entry.Message += "However, the backup folder could not be renamed."
+		return nil
+	}
+	removed, shouldRemove, err := pruneSnapshots(job.Dest, folder, job.BackupLimit)
+	if err != nil {
+		entry.Message += fmt.Sprintf("However, %v out of %v old backups have been removed.", removed, shouldRemove)
+		return nil
+	}
+	entry.Message += fmt.Sprintf("%v old backup(s) have been removed. There were no errors.", removed)
+	return nil
+}
+
+// insideFolder reports whether a path is the folder or inside it, which xcopy refused as a cyclic copy.
+// Paths on windows are compared regardless of case.
+func insideFolder(path, folder string) bool {
+	if runtime.GOOS == "windows" {
+		path, folder = strings.ToLower(path), strings.ToLower(folder)
+	}
+	rel, err := filepath.Rel(folder, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// copyDir copies the contents of src into dest, existing files are overwritten. It returns the number of copied files.
+// The copies keep the modes of the files and, where the OS allows it, their owners.
+func copyDir(src, dest string) (int, error) {
+	copied := 0
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(target, info.Mode().Perm()|0o700); err != nil {
+				return err
+			}
+			return copyOwner(target, info)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			copied++
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+			return copyOwner(target, info)
+		case info.Mode().IsRegular():
+			copied++
+			return copyFile(path, target, info)
+		}
+		// Sockets, devices and pipes are not backed up
+		return nil
+	})
+	return copied, err
+}
+
+func copyFile(src, dest string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// The owner is copied first as changing it clears the setuid and setgid bits, the mode is set again as the umask
+	// narrowed it and an overwritten file kept its own
+	if err := copyOwner(dest, info); err != nil {
+		return err
+	}
+	if err := os.Chmod(dest, info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// pruneSnapshots removes the oldest snapshots of a folder beyond the limit. A limit of 0 or above 10 keeps all of them.
+func pruneSnapshots(dest, folder string, backupLimit uint8) (removed, shouldRemove int, err error) {
+	if backupLimit == 0 || backupLimit > 10 {
+		return 0, 0, nil
+	}
+	snapshots, err := listSnapshots(dest, folder)
+	if err != nil {
+		return 0, 0, err
+	}
+	shouldRemove = len(snapshots) - int(backupLimit)
+	for ; removed < shouldRemove; removed++ {
+		if err := os.RemoveAll(filepath.Join(dest, snapshots[removed])); err != nil {
+			return removed, shouldRemove, err
+		}
+	}
+	if shouldRemove < 0 {
+		shouldRemove = 0
+	}
+	return removed, shouldRemove, nil
+}
+
+// listSnapshots returns the names of the snapshots of a folder, oldest first
+func listSnapshots(dest, folder string) ([]string, error) {
+	entries, err := os.ReadDir(dest)
+	if err != nil {
+		return nil, err
+	}
+	m := regexp.MustCompile(`^` + regexp.QuoteMeta(folder) + `-20\d{6}_\d{6}$`)
+	var snapshots []string
+	for _, entry := range entries {
+		if entry.IsDir() && m.MatchString(entry.Name()) {
+			snapshots = append(snapshots, entry.Name())
+		}
+	}
+	// The timestamp format sorts chronologically
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubHistory points the history at a temporary file and records the notifications instead of showing them, so tests
+// never touch the history or the desktop of the user running them
+func stubHistory(t *testing.T) *[]string {
+	hPath := filepath.Join(t.TempDir(), historyFile)
+	HistoryPath = func() (string, error) { return hPath, nil }
+	var titles []string
+	notify = func(title, message string) { titles = append(titles, title) }
+	t.Cleanup(func() {
+		HistoryPath = userHistoryPath
+		notify = systemNotify
+	})
+	return &titles
+}
+
+func TestInsideFolder(t *testing.T) {
+	folder := filepath.Join("home", "me")
+	testcases := []struct {
+		path string
+		want bool
+	}{
+		{folder, true},
+		{filepath.Join(folder, "Backups"), true},
+		{filepath.Join("home", "me2"), false},
+		{filepath.Join("home", "..me"), false},
+		{filepath.Join(folder, "..", "other"), false},
+		{"home", false},
+	}
+	for _, tc := range testcases {
+		if result := insideFolder(tc.path, folder); result != tc.want {
+			t.Errorf(`insideFolder(%q, %q) = %v, want %v`, tc.path, folder, result, tc.want)
+		}
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	notifications := stubHistory(t)
+	src := filepath.Join(t.TempDir(), "Documents")
+	dest := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "sub"), 0o755)
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644)
+	os.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("b"), 0o600)
+	os.Chmod(filepath.Join(src, "a.txt"), 0o666)
+
+	job := NewJob(2, src, dest, false)
+	start := time.Date(2022, 4, 2, 17, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		if err := RunJob(job, start.AddDate(0, 0, i)); err != nil {
+			t.Fatalf(`RunJob(job, %v) returned error %v`, start.AddDate(0, 0, i), err)
+		}
+	}
+	snapshots, err := listSnapshots(dest, "Documents")
+	if err != nil || len(snapshots) != 2 || snapshots[0] != "Documents-20220403_170000" || snapshots[1] != "Documents-20220404_170000" {
+		t.Errorf(`RunJob(...) snapshots = %v, %v want the newest 2`, snapshots, err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dest, "Documents-20220404_170000", "sub", "b.txt")); err != nil || string(b) != "b" {
+		t.Errorf(`RunJob(...) sub/b.txt = %q, %v want "b"`, b, err)
+	}
+	// The mode is kept beyond the umask, windows only knows the read-only bit
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(filepath.Join(dest, "Documents-20220404_170000", "a.txt")); err != nil || info.Mode().Perm() != 0o666 {
+			t.Errorf(`RunJob(...) a.txt = %v, %v want mode 0666`, info, err)
+		}
+	}
+
+	overwriteJob := NewJob(0, src, dest, true)
+	for i := 0; i < 2; i++ {
+		if err := RunJob(overwriteJob, start); err != nil {
+			t.Fatalf(`RunJob(overwriteJob, %v) returned error %v`, start, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, "Documents", "a.txt")); err != nil {
+		t.Errorf(`RunJob(overwriteJob, ...) did not copy into %v: %v`, filepath.Join(dest, "Documents"), err)
+	}
+
+	// xcopy refused to copy a folder into itself, the copy would never end
+	cyclicJob := NewJob(0, src, filepath.Join(src, "sub", "Backups"), false)
+	if err := RunJob(cyclicJob, start); err == nil {
+		t.Errorf(`RunJob(cyclicJob, ...) did not return an error`)
+	}
+	if _, err := os.Stat(filepath.Join(src, "sub", "Backups")); !os.IsNotExist(err) {
+		t.Errorf(`RunJob(cyclicJob, ...) created the dest inside src`)
+	}
+
+	missingJob := NewJob(0, filepath.Join(src, "missing"), dest, false)
+	if err := RunJob(missingJob, start); err == nil {
+		t.Errorf(`RunJob(missingJob, ...) did not return an error`)
+	}
+
+	// The placeholders are expanded when the job runs, the history records the expanded paths
+	t.Setenv("GOBACKUP_TEST_SRC", filepath.Dir(src))
+	placeholderJob := NewJob(0, filepath.Join("%GOBACKUP_TEST_SRC%", "Documents"), dest, true)
+	if err := RunJob(placeholderJob, start); err != nil {
+		t.Errorf(`RunJob(placeholderJob, ...) returned error %v`, err)
+	}
+	unresolvedJob := NewJob(0, filepath.Join("%GOBACKUP_TEST_UNSET%", "Documents"), dest, true)
+	if err := RunJob(unresolvedJob, start); err == nil {
+		t.Errorf(`RunJob(unresolvedJob, ...) did not return an error`)
+	}
+
+	// A run to a volume that is not connected is skipped
+	stubVolumes(t, nil)
+	skippedJob := NewJob(0, src, filepath.Join("{Label:BACKUP}", "GoBackup"), false)
+	if err := RunJob(skippedJob, start); !errors.As(err, new(*ErrVolumeNotFound)) {
+		t.Errorf(`RunJob(skippedJob, ...) = %v, want ErrVolumeNotFound`, err)
+	}
+
+	history, err := ReadHistory()
+	if err != nil || len(history) != 10 {
+		t.Fatalf(`ReadHistory() = %v, %v want 10 entries`, history, err)
+	}
+	if history[0].JobID != skippedJob.ID || !history[0].Skipped() || !strings.Contains(history[0].Message, `volume "BACKUP" is not connected`) {
+		t.Errorf(`ReadHistory()[0] = %+v, want the skipped run`, history[0])
+	}
+	if history[1].JobID != unresolvedJob.ID || history[1].ExitCode != exitCodeMissingFiles || !strings.Contains(history[1].Message, "GOBACKUP_TEST_UNSET is not set") {
+		t.Errorf(`ReadHistory()[1] = %+v, want the failed run of the unset variable`, history[1])
+	}
+	if history[2].JobID != placeholderJob.ID || !history[2].Success() || history[2].Src != src {
+		t.Errorf(`ReadHistory()[2] = %+v, want the run of %v`, history[2], src)
+	}
+	if history[3].JobID != missingJob.ID || history[3].Success() || history[3].ExitCode != exitCodeMissingFiles {
+		t.Errorf(`ReadHistory()[3] = %+v, want the failed run of the missing folder`, history[3])
+	}
+	if history[4].JobID != cyclicJob.ID || history[4].ExitCode != exitCodeMissingFiles {
+		t.Errorf(`ReadHistory()[4] = %+v, want the refused run into src`, history[4])
+	}
+	if len(*notifications) != 10 || (*notifications)[9] != "Your backup was skipped" {
+		t.Errorf(`RunJob(...) notifications = %v, want one for each run`, *notifications)
+	}
+	if run := lastRuns(history)[job.ID]; !run.Success() || !run.Time.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf(`lastRuns(history)[job.ID] = %+v, want the successful run of %v`, run, start.AddDate(0, 0, 2))
+	}
+}
@@ -10,6 +10,14 @@ type ErrCreateTaskFailure struct {
 	Inner   error
 	Message string
 }
+type ErrUpdateTaskFailure struct {
+	Inner   error
+	Message string
+}
+type ErrParseTaskFailure struct {
+	Inner   error
+	Message string
+}
 type ErrRetrieveTasksFailure struct {
 	Inner   error
 	Message string
@@ -26,6 +34,30 @@ type ErrDeleteTaskFolderFailure struct {
 	Inner   error
 	Message string
 }
+type ErrRunTaskFailure struct {
+	Inner   error
+	Message string
+}
+type ErrRunBackupFailure struct {
+	Inner   error
+	Message string
+}
+type ErrTaskNotFound struct {
+	Inner   error
+	Message string
+}
+type ErrUnsupportedSchedule struct {
+	Inner   error
+	Message string
+}
+type ErrVolumeNotFound struct {
+	Inner   error
+	Message string
+}
+type ErrUnknownScheduler struct {
+	Inner   error
+	Message string
+}
 
 func (e *ErrConnectSchedulerFailure) Error() string {
 	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
@@ -33,6 +65,12 @@ func (e *ErrConnectSchedulerFailure) Error() string {
 func (e *ErrCreateTaskFailure) Error() string {
 	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
 }
+func (e *ErrUpdateTaskFailure) Error() string {
+	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
+}
+func (e *ErrParseTaskFailure) Error() string {
+	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
+}
 func (e *ErrRetrieveTasksFailure) Error() string {
 	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
 }
@@ -45,10 +83,36 @@ func (e *ErrDeleteTaskFailure) Error() string {
 func (e *ErrDeleteTaskFolderFailure) Error() string {
 	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
 }
+func (e *ErrRunTaskFailure) Error() string {
+	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
+}
+func (e *ErrRunBackupFailure) Error() string {
+	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
+}
+func (e *ErrTaskNotFound) Error() string {
+	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
+}
+func (e *ErrUnsupportedSchedule) Error() string {
+	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
+}
+func (e *ErrVolumeNotFound) Error() string {
+	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
+}
+func (e *ErrUnknownScheduler) Error() string {
+	return fmt.Sprintf("Inner error: %v; Message: %v", e.Inner, e.Message)
+}
 
 func (e *ErrConnectSchedulerFailure) Unwrap() error   { return e.Inner }
 func (e *ErrCreateTaskFailure) Unwrap() error         { return e.Inner }
+func (e *ErrUpdateTaskFailure) Unwrap() error         { return e.Inner }
+func (e *ErrParseTaskFailure) Unwrap() error          { return e.Inner }
 func (e *ErrRetrieveTasksFailure) Unwrap() error      { return e.Inner }
 func (e *ErrRetrieveTaskFolderFailure) Unwrap() error { return e.Inner }
 func (e *ErrDeleteTaskFailure) Unwrap() error         { return e.Inner }
 func (e *ErrDeleteTaskFolderFailure) Unwrap() error   { return e.Inner }
+func (e *ErrRunTaskFailure) Unwrap() error            { return e.Inner }
+func (e *ErrRunBackupFailure) Unwrap() error          { return e.Inner }
+func (e *ErrTaskNotFound) Unwrap() error              { return e.Inner }
+func (e *ErrUnsupportedSchedule) Unwrap() error       { return e.Inner }
+func (e *ErrVolumeNotFound) Unwrap() error            { return e.Inner }
+func (e *ErrUnknownScheduler) Unwrap() error          { return e.Inner }
@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExportConfig returns a config of all readable jobs that can be imported on another machine, see PlanImport.
+// Home is the user profile folder of this machine, the import maps it to the one of the other machine.
+// Jobs with the same label get a number after their name since the names of a config are unique.
+func ExportConfig(tasks []Task, home string) Config {
+	c := Config{Home: home}
+	names := map[string]bool{}
+	for _, task := range tasks {
+		if task.Err != nil {
+			continue
+		}
+		b := newConfigBackup(task.Job, task.Enabled)
+		if len(b.Name) == 0 {
+			b.Name = folderName(b.Src)
+		}
+		name := b.Name
+		for n := 2; names[b.Name]; n++ {
+			b.Name = name + " " + strconv.Itoa(n)
+		}
+		names[b.Name] = true
+		c.Backups = append(c.Backups, b)
+	}
+	return c
+}
+
+// MarshalConfig writes a config in the format that ParseConfig reads
+func MarshalConfig(c Config) ([]byte, error) {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("MarshalConfig: %w", err)
+	}
+	return b, nil
+}
+
+// PathMapping replaces the start of a path, such as a drive letter or a user profile folder
+type PathMapping struct {
+	From string
+	To   string
+}
+
+// ParsePathMapping reads a mapping in the format from=to, such as E:=F: or C:\Users\Ann=/home/ann
+func ParsePathMapping(v string) (PathMapping, error) {
+	from, to, ok := strings.Cut(v, "=")
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if !ok || len(from) == 0 || len(to) == 0 {
+		return PathMapping{}, fmt.Errorf("ParsePathMapping: %w", fmt.Errorf("%q is not in the format from=to", v))
+	}
+	return PathMapping{From: from, To: to}, nil
+}
+
+// ParsePathMappings reads a list of mappings separated by semicolons, such as "E:=F:; D:=G:"
+func ParsePathMappings(list string) ([]PathMapping, error) {
+	var mappings []PathMapping
+	for _, item := range strings.Split(list, ";") {
+		if len(strings.TrimSpace(item)) == 0 {
+			continue
+		}
+		m, err := ParsePathMapping(item)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// RemapPath applies the first mapping that starts the path. A mapping only matches whole folder names and ignores
+// case, so E: matches E:\Backup and C:\Users\Ann does not match C:\Users\Anna. The rest of the path takes the
+// separators of the mapped start, a Windows path that is mapped to /home/ann becomes a slash separated path.
+func RemapPath(path string, mappings []PathMapping) string {
+	for _, m := range mappings {
+		from := strings.TrimRight(m.From, `\/`)
+		if len(path) < len(from) || !strings.EqualFold(path[:len(from)], from) {
+			continue
+		}
+		rest := path[len(from):]
+		if len(rest) > 0 && !isSeparator(rest[0]) {
+			continue
+		}
+		// The separator of the path, unless the mapped start only uses the other one
+		sep := `\`
+		if len(rest) > 0 && rest[0] == '/' {
+			sep = "/"
+		}
+		switch {
+		case strings.Contains(m.To, "/") && !strings.Contains(m.To, `\`):
+			sep = "/"
+		case strings.Contains(m.To, `\`) && !strings.Contains(m.To, "/"):
+			sep = `\`
+		}
+		rest = strings.TrimLeft(rest, `\/`)
+		to := strings.TrimRight(m.To, `\/`)
+		if len(rest) == 0 {
+			if len(path) > len(from) && to == m.To {
+				return to + sep
+			}
+			return m.To
+		}
+		rest = strings.NewReplacer(`\`, sep, "/", sep).Replace(rest)
+		return to + sep + rest
+	}
+	return path
+}
+
+func isSeparator(c byte) bool {
+	return c == '\\' || c == '/'
+}
+
+// ImportItem is a backup of an imported config together with what prevents it from being scheduled
+type ImportItem struct {
+	// Backup is the backup as it is written in the config
+	Backup ConfigBackup
+	// Job and Enabled are the backup that is created, with the remapped src and dest
+	Job     Job
+	Enabled bool
+	// Problems are the reasons the backup might not work, such as a src or dest that does not exist
+	Problems []string
+	// Duplicates are the scheduled backups that already back up the src to the dest on the same schedule
+	Duplicates []Task
+}
+
+// Ready reports whether the backup is imported, duplicates never are and backups with problems only when forced
+func (i ImportItem) Ready(force bool) bool {
+	return len(i.Duplicates) == 0 && (force || len(i.Problems) == 0)
+}
+
+// PlanImport remaps the paths of the backups of a config and checks them against this machine. The user profile folder
+// of the config is mapped to home after the given mappings. Every backup becomes a new job, existing jobs are never
+// changed.
+func PlanImport(c Config, mappings []PathMapping, home string, tasks []Task) ([]ImportItem, error) {
+	if len(c.Home) > 0 && len(home) > 0 {
+		mappings = append(mappings[:len(mappings):len(mappings)], PathMapping{From: c.Home, To: home})
+	}
+	var items []ImportItem
+	for _, b := range c.Backups {
+		remapped := b
+		remapped.Src, remapped.Dest = RemapPath(b.Src, mappings), RemapPath(b.Dest, mappings)
+		remapped.Rotation = nil
+		for _, dest := range b.Rotation {
+			remapped.Rotation = append(remapped.Rotation, RemapPath(dest, mappings))
+		}
+		job, err := remapped.job(Job{})
+		if err != nil {
+			return nil, fmt.Errorf("PlanImport: backup %q: %w", b.Name, err)
+		}
+		item := ImportItem{Backup: b, Job: job, Enabled: b.enabled(), Duplicates: FindDuplicates(tasks, job)}
+		paths := [][2]string{{"src", job.Src}, {"dest", job.Dest}}
+		if len(job.Rotation) > 0 {
+			// A rotation only needs one of its drives
+			paths = paths[:1]
+			_, _, err := connectedDrive(job.Dests())
+			var volumeErr *ErrVolumeNotFound
+			if errors.As(err, &volumeErr) {
+				item.Problems = append(item.Problems, fmt.Sprintf("dest %v: the %v", strings.Join(job.Dests(), ", "), volumeErr.Message))
+			} else if err != nil {
+				item.Problems = append(item.Problems, fmt.Sprintf("dest %v cannot be resolved: %v", strings.Join(job.Dests(), ", "), expandReason(err)))
+			}
+		}
+		for _, path := range paths {
+			resolved, err := ExpandPath(path[1])
+			var volumeErr *ErrVolumeNotFound
+			if errors.As(err, &volumeErr) {
+				item.Problems = append(item.Problems, fmt.Sprintf("%v %v: the %v", path[0], path[1], volumeErr.Message))
+			} else if err != nil {
+				item.Problems = append(item.Problems, fmt.Sprintf("%v %v cannot be resolved: %v", path[0], path[1], expandReason(err)))
+			} else if info, err := os.Stat(resolved); err != nil {
+				item.Problems = append(item.Problems, fmt.Sprintf("%v %v does not exist", path[0], path[1]))
+			} else if !info.IsDir() {
+				item.Problems = append(item.Problems, fmt.Sprintf("%v %v is not a folder", path[0], path[1]))
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ImportJobs schedules the backups of PlanImport that are ready and returns how many were scheduled
+func ImportJobs(s Scheduler, items []ImportItem, force bool) (int, error) {
+	n := 0
+	for _, item := range items {
+		if !item.Ready(force) {
+			continue
+		}
+		if err := ApplyChange(s, Change{Action: ChangeCreate, Name: item.Backup.Name, Job: item.Job, Enabled: item.Enabled}); err != nil {
+			return n, fmt.Errorf("ImportJobs: backup %q: %w", item.Backup.Name, err)
+		}
+		n++
+	}
+	return n, nil
+}
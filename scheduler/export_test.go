@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemapPath(t *testing.T) {
+	testcases := []struct {
+		path     string
+		mappings []PathMapping
+		want     string
+	}{
+		{`E:\Backup`, []PathMapping{{`E:`, `F:`}}, `F:\Backup`},
+		{`e:\Backup`, []PathMapping{{`E:\`, `F:\`}}, `F:\Backup`},
+		{`E:\`, []PathMapping{{`E:`, `F:`}}, `F:\`},
+		{`D:\Backup`, []PathMapping{{`E:`, `F:`}}, `D:\Backup`},
+		{`C:\Users\Anna\Documents`, []PathMapping{{`C:\Users\Ann`, `C:\Users\Bob`}}, `C:\Users\Anna\Documents`},
+		{`C:\Users\Ann\Documents\Taxes`, []PathMapping{{`C:\Users\Ann`, `/home/ann`}}, `/home/ann/Documents/Taxes`},
+		{`/home/ann/Documents`, []PathMapping{{`/home/ann`, `C:\Users\Ann`}}, `C:\Users\Ann\Documents`},
+		{`/media/usb/backup`, []PathMapping{{`/`, `/mnt/old/`}}, `/mnt/old/media/usb/backup`},
+		// The first mapping that matches wins
+		{`E:\Backup`, []PathMapping{{`E:\Backup`, `G:\`}, {`E:`, `F:`}}, `G:\`},
+	}
+	for _, tc := range testcases {
+		if result := RemapPath(tc.path, tc.mappings); result != tc.want {
+			t.Errorf(`RemapPath(%v, %v) = %v, want %v`, tc.path, tc.mappings, result, tc.want)
+		}
+	}
+}
+
+func TestParsePathMappings(t *testing.T) {
+	mappings, err := ParsePathMappings(`E:=F:; C:\Users\Ann = /home/ann;`)
+	if err != nil || len(mappings) != 2 || mappings[0] != (PathMapping{`E:`, `F:`}) || mappings[1] != (PathMapping{`C:\Users\Ann`, `/home/ann`}) {
+		t.Errorf(`ParsePathMappings(...) = %v, %v want 2 mappings`, mappings, err)
+	}
+	for _, list := range []string{"E:", "=F:", "E:="} {
+		if _, err := ParsePathMappings(list); err == nil {
+			t.Errorf(`ParsePathMappings(%v) returned no error`, list)
+		}
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	s := NewMemoryScheduler()
+	s.Now = func() time.Time { return time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC) }
+	documents := NewJob(3, `C:\Users\Ann\Documents`, `E:\Backup`, false)
+	documents.Schedule = Schedule{Type: Weekly, Weekdays: 1<<1 | 1<<5, Hour: 8, Minute: 30}
+	documents.Notify = NotifyFailure
+	documents.Rotation = []string{`C:\Users\Ann\Offsite`}
+	// A second job with the same label is exported under another name
+	other := NewJob(0, `D:\Documents`, `E:\Backup`, true)
+	for _, job := range []Job{documents, other} {
+		if _, err := s.Create(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Disable(other.ID); err != nil {
+		t.Fatal(err)
+	}
+	tasks, _ := s.List()
+
+	b, err := MarshalConfig(ExportConfig(tasks, `C:\Users\Ann`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ParseConfig(b)
+	if err != nil {
+		t.Fatalf(`ParseConfig(MarshalConfig(...)) returned error %v for %s`, err, b)
+	}
+	if c.Home != `C:\Users\Ann` || len(c.Backups) != 2 || c.Backups[0].Name != "Documents" || c.Backups[1].Name != "Documents 2" {
+		t.Fatalf(`ParseConfig(MarshalConfig(...)) = %+v, want the exported jobs`, c)
+	}
+
+	// The user profile is mapped to home, the drives by the given mappings
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	backup := filepath.Join(dir, "backup")
+	for _, d := range []string{filepath.Join(home, "Documents"), backup} {
+		writeFile(t, filepath.Join(d, "keep"), "")
+	}
+	items, err := PlanImport(c, []PathMapping{{`E:\Backup`, backup}}, home, tasks)
+	if err != nil || len(items) != 2 {
+		t.Fatalf(`PlanImport(...) = %v, %v want 2 items`, items, err)
+	}
+	job := items[0].Job
+	if job.ID == documents.ID || job.Src != filepath.Join(home, "Documents") || job.Dest != backup || job.Notify != NotifyFailure || job.Schedule.Weekdays != documents.Schedule.Weekdays ||
+		len(job.Rotation) != 1 || job.Rotation[0] != filepath.Join(home, "Offsite") {
+		t.Errorf(`PlanImport(...)[0].Job = %+v, want a new job with the remapped paths`, job)
+	}
+	// One connected drive of the rotation is enough
+	if len(items[0].Problems) != 0 || !items[0].Ready(false) {
+		t.Errorf(`PlanImport(...)[0].Problems = %v, want none`, items[0].Problems)
+	}
+	if len(items[1].Problems) != 1 || !strings.Contains(items[1].Problems[0], `src D:\Documents does not exist`) || items[1].Ready(false) || !items[1].Ready(true) {
+		t.Errorf(`PlanImport(...)[1].Problems = %v, want the missing src`, items[1].Problems)
+	}
+
+	n, err := ImportJobs(s, items, false)
+	if err != nil || n != 1 {
+		t.Fatalf(`ImportJobs(...) = %v, %v want 1`, n, err)
+	}
+	tasks, _ = s.List()
+	if len(tasks) != 3 {
+		t.Fatalf(`List() after ImportJobs = %+v, want 3 tasks`, tasks)
+	}
+
+	// Importing again finds the imported backup, the forced one is created disabled as it was exported
+	items, err = PlanImport(c, []PathMapping{{`E:\Backup`, backup}}, home, tasks)
+	if err != nil || len(items[0].Duplicates) != 1 || items[0].Ready(true) {
+		t.Fatalf(`PlanImport(...) after ImportJobs = %+v, %v want a duplicate`, items, err)
+	}
+	if n, err := ImportJobs(s, items, true); err != nil || n != 1 {
+		t.Fatalf(`ImportJobs(..., true) = %v, %v want 1`, n, err)
+	}
+	tasks, _ = s.List()
+	if len(tasks) != 4 || tasks[3].Enabled || tasks[3].Job.Label != "Documents 2" {
+		t.Errorf(`List() after ImportJobs(..., true) = %+v, want the disabled Documents 2`, tasks)
+	}
+}
+
+func TestExportImportUnlimitedKeep(t *testing.T) {
+	s := NewMemoryScheduler()
+	dir := t.TempDir()
+	src, dest := filepath.Join(dir, "Documents"), filepath.Join(dir, "Backup")
+	writeFile(t, filepath.Join(src, "keep"), "")
+	writeFile(t, filepath.Join(dest, "keep"), "")
+	// Keeping all backups in the window, which is the limit after 10
+	if _, err := s.Create(NewJob(11, src, dest, false)); err != nil {
+		t.Fatal(err)
+	}
+	tasks, _ := s.List()
+	b, err := MarshalConfig(ExportConfig(tasks, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ParseConfig(b)
+	if err != nil {
+		t.Fatalf(`ParseConfig(MarshalConfig(...)) returned error %v for %s`, err, b)
+	}
+	items, err := PlanImport(c, nil, "", nil)
+	if err != nil || len(items) != 1 || !items[0].Ready(false) || items[0].Job.BackupLimit != 0 {
+		t.Errorf(`PlanImport(...) = %+v, %v want a job that keeps all backups`, items, err)
+	}
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const historyFile = "history.jsonl"
+
+// HistoryEntry is a single backup run as recorded by the backup script or the engine
+type HistoryEntry struct {
+	Time     time.Time `json:"time"`
+	JobID    string    `json:"jobId,omitempty"`
+	Src      string    `json:"src"`
+	Dest     string    `json:"dest"`
+	ExitCode int       `json:"exitCode"`
+	Message  string    `json:"message"`
+	// Disk is the drive of a rotation the run went to, the dest as configured with its placeholders
+	Disk string `json:"disk,omitempty"`
+}
+
+func (h HistoryEntry) Success() bool {
+	return h.ExitCode == 0
+}
+
+// Skipped reports whether the run was skipped because the volume of its src or dest was not connected
+func (h HistoryEntry) Skipped() bool {
+	return h.ExitCode == exitCodeVolumeMissing
+}
+
+// HistoryPath returns the file scheduled and manual backups append their results to, tests replace it
+var HistoryPath = userHistoryPath
+
+func userHistoryPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("HistoryPath: %w", err)
+	}
+	return filepath.Join(configDir, appTitle, historyFile), nil
+}
+
+// ReadHistory returns all recorded backup runs, newest first. Lines that cannot be read are skipped.
+func ReadHistory() ([]HistoryEntry, error) {
+	hPath, err := HistoryPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(hPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReadHistory: %w", err)
+	}
+	defer f.Close()
+	return parseHistory(f)
+}
+
+// appendHistory records a backup run, failing to do so does not fail the backup
+func appendHistory(entry HistoryEntry) error {
+	hPath, err := HistoryPath()
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("appendHistory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(hPath), 0o755); err != nil {
+		return fmt.Errorf("appendHistory: %w", err)
+	}
+	f, err := os.OpenFile(hPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("appendHistory: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("appendHistory: %w", err)
+	}
+	return nil
+}
+
+// lastRuns returns the newest history entry of every job
+func lastRuns(history []HistoryEntry) map[string]HistoryEntry {
+	runs := map[string]HistoryEntry{}
+	for _, entry := range history {
+		if _, ok := runs[entry.JobID]; !ok && len(entry.JobID) > 0 {
+			runs[entry.JobID] = entry
+		}
+	}
+	return runs
+}
+
+func parseHistory(r io.Reader) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// Add-Content -Encoding UTF8 prefixes a new file with a BOM
+		line := bytes.TrimPrefix(bytes.TrimSpace(s.Bytes()), []byte("\xef\xbb\xbf"))
+		if len(line) == 0 {
+			continue
+		}
+		// A line torn by a crash during a run or by both writers at once should not hide the other runs
+		var entry HistoryEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("parseHistory: %w", err)
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jobVersion is increased whenever the meaning of a stored field changes, new optional fields do not need a new version
+const jobVersion = 1
+
+// Job is the metadata of a backup task. It is stored as JSON in the documentation of the task.
+type Job struct {
+	Version     int       `json:"version"`
+	ID          string    `json:"id"`
+	Label       string    `json:"label"`
+	Src         string    `json:"src"`
+	Dest        string    `json:"dest"`
+	BackupLimit uint8     `json:"backupLimit"`
+	Overwrite   bool      `json:"overwrite"`
+	Schedule    Schedule  `json:"schedule"`
+	PausedUntil time.Time `json:"pausedUntil"`
+	// Notify is when a run shows a notification, NotifyAlways, NotifyFailure or NotifyNever
+	Notify string `json:"notify,omitempty"`
+	// Rotation are the other drives of a drive rotation, a run backs up to the first of Dest and Rotation that is connected
+	Rotation []string `json:"rotation,omitempty"`
+	// AlertAfterDays is how long a drive of the rotation may go without a backup before a run alerts, 0 never alerts
+	AlertAfterDays uint16 `json:"alertAfterDays,omitempty"`
+}
+
+// Notification settings of a job, the history records every run regardless
+const (
+	NotifyAlways  = ""
+	NotifyFailure = "failure"
+	NotifyNever   = "never"
+)
+
+// NewJob creates a job with a new unique ID, labeled with the name of the src folder
+func NewJob(backupLimit uint8, src, dest string, overwrite bool) Job {
+	return Job{
+		Version:     jobVersion,
+		ID:          newJobID(),
+		Label:       folderName(src),
+		Src:         src,
+		Dest:        dest,
+		BackupLimit: backupLimit,
+		Overwrite:   overwrite,
+	}
+}
+
+// newJobID returns a random UUID (version 4)
+func newJobID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// The system random number generator is not supposed to fail
+		panic(fmt.Sprintf("newJobID: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// legacyJobID derives the ID of a job whose metadata has none from the path of its task, so the job keeps its ID on
+// every read until the metadata is rewritten. It has the format of a name-based UUID.
+func legacyJobID(taskPath string) string {
+	sum := sha1.Sum([]byte("GoBackup legacy job " + taskPath))
+	b := sum[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// SameTarget reports whether two jobs back up the same src folder to the same dest
+func (j Job) SameTarget(other Job) bool {
+	return strings.EqualFold(filepath.Clean(j.Src), filepath.Clean(other.Src)) &&
+		strings.EqualFold(filepath.Clean(j.Dest), filepath.Clean(other.Dest))
+}
+
+func (j Job) encode() (string, error) {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return "", fmt.Errorf("encode: %w", err)
+	}
+	return string(b), nil
+}
+
+// ParseJob reads the job metadata of a task. Tasks created before the JSON format or before job IDs are still understood,
+// they get a new ID on every parse until legacy, which reports whether the metadata should be rewritten, is acted upon.
+// Schedulers that read such tasks again replace the ID with legacyJobID.
+func ParseJob(doc string) (job Job, legacy bool, err error) {
+	if !strings.HasPrefix(strings.TrimSpace(doc), "{") {
+		job, err = parseLegacyJob(doc)
+		return job, true, err
+	}
+	if err := json.Unmarshal([]byte(doc), &job); err != nil {
+		return Job{}, false, fmt.Errorf("ParseJob: %w", err)
+	}
+	if job.Version < 1 || job.Version > jobVersion {
+		return Job{}, false, fmt.Errorf("ParseJob: %w", fmt.Errorf("unsupported job version %v", job.Version))
+	}
+	if len(job.Src) == 0 || len(job.Dest) == 0 {
+		return Job{}, false, fmt.Errorf("ParseJob: %w", errors.New("missing src or dest"))
+	}
+	if len(job.ID) == 0 {
+		job.ID = newJobID()
+		job.Label = folderName(job.Src)
+		return job, true, nil
+	}
+	return job, false, nil
+}
+
+// parseLegacyJob reads the old src|dest|limit|ov[|pausedUntil] format
+func parseLegacyJob(doc string) (Job, error) {
+	fields := strings.Split(doc, "|")
+	if len(fields) < 4 || len(fields) > 5 {
+		return Job{}, fmt.Errorf("parseLegacyJob: %w", errors.New("unexpected number of fields"))
+	}
+	job := NewJob(0, fields[0], fields[1], fields[3] == "Yes")
+	if !job.Overwrite {
+		limit, err := strconv.ParseUint(fields[2], 10, 8)
+		if err != nil {
+			return Job{}, fmt.Errorf("parseLegacyJob: %w", err)
+		}
+		job.BackupLimit = uint8(limit)
+	}
+	if len(fields) == 5 {
+		pausedUntil, err := time.Parse(time.RFC3339, fields[4])
+		if err != nil {
+			return Job{}, fmt.Errorf("parseLegacyJob: %w", err)
+		}
+		job.PausedUntil = pausedUntil
+	}
+	return job, nil
+}
@@ -0,0 +1,74 @@
+//go:build !windows
+
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// xdgUserDirs are the keys of the known folders in user-dirs.dirs, see xdg-user-dirs
+var xdgUserDirs = map[string]string{
+	"Desktop":   "XDG_DESKTOP_DIR",
+	"Documents": "XDG_DOCUMENTS_DIR",
+	"Downloads": "XDG_DOWNLOAD_DIR",
+	"Music":     "XDG_MUSIC_DIR",
+	"Pictures":  "XDG_PICTURES_DIR",
+	"Videos":    "XDG_VIDEOS_DIR",
+}
+
+// knownFolder returns the path of a known folder of the user. The XDG user directories are read from the environment
+// and from user-dirs.dirs, a folder that is not set there is the folder of that name in the home folder.
+func knownFolder(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if name == "Home" {
+		return home, nil
+	}
+	key := xdgUserDirs[name]
+	if dir := os.Getenv(key); len(dir) > 0 {
+		return dir, nil
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if len(configHome) == 0 {
+		configHome = filepath.Join(home, ".config")
+	}
+	if data, err := os.ReadFile(filepath.Join(configHome, "user-dirs.dirs")); err == nil {
+		if dir, ok := parseUserDirs(data, home)[key]; ok {
+			return dir, nil
+		}
+	}
+	if name == "Videos" && runtime.GOOS == "darwin" {
+		return filepath.Join(home, "Movies"), nil
+	}
+	return filepath.Join(home, name), nil
+}
+
+// parseUserDirs reads the folders of a user-dirs.dirs file, whose lines look like XDG_DOCUMENTS_DIR="$HOME/Documents".
+// A path is either absolute or relative to $HOME.
+func parseUserDirs(data []byte, home string) map[string]string {
+	dirs := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		value = strings.ReplaceAll(strings.Trim(value, `"`), `\"`, `"`)
+		switch {
+		case value == "$HOME" || strings.HasPrefix(value, "$HOME/"):
+			value = filepath.Join(home, strings.TrimPrefix(value, "$HOME"))
+		case !filepath.IsAbs(value):
+			continue
+		}
+		dirs[key] = value
+	}
+	return dirs
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package scheduler
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestKnownFolder(t *testing.T) {
+	configHome, err := filepath.Abs(filepath.Join("testdata", "xdg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_DOWNLOAD_DIR", "/mnt/downloads")
+	t.Setenv("XDG_DOCUMENTS_DIR", "")
+	t.Setenv("XDG_MUSIC_DIR", "")
+	t.Setenv("XDG_VIDEOS_DIR", "")
+
+	testcases := []struct {
+		name string
+		want string
+	}{
+		{"Home", home},
+		{"Documents", filepath.Join(home, "Dokumente")},
+		{"Music", "/srv/music"},
+		// The environment comes before the file
+		{"Downloads", "/mnt/downloads"},
+		// Relative paths are not supported by xdg-user-dirs, the default folder is used instead
+		{"Videos", filepath.Join(home, "Videos")},
+	}
+	for _, tc := range testcases {
+		if result, err := knownFolder(tc.name); err != nil || result != tc.want {
+			t.Errorf(`knownFolder(%v) = %v, %v want %v`, tc.name, result, err, tc.want)
+		}
+	}
+
+	// Without a user-dirs.dirs file every folder is in the home folder
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if result, err := knownFolder("Documents"); err != nil || result != filepath.Join(home, "Documents") {
+		t.Errorf(`knownFolder(Documents) without user-dirs.dirs = %v, %v want %v`, result, err, filepath.Join(home, "Documents"))
+	}
+}
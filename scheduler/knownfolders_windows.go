@@ -0,0 +1,20 @@
+package scheduler
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+var knownFolderIDs = map[string]*windows.KNOWNFOLDERID{
+	"Home":      windows.FOLDERID_Profile,
+	"Desktop":   windows.FOLDERID_Desktop,
+	"Documents": windows.FOLDERID_Documents,
+	"Downloads": windows.FOLDERID_Downloads,
+	"Music":     windows.FOLDERID_Music,
+	"Pictures":  windows.FOLDERID_Pictures,
+	"Videos":    windows.FOLDERID_Videos,
+}
+
+// knownFolder returns the path of a known folder of the user, which follows the folder when it is moved, e.g. to OneDrive
+func knownFolder(name string) (string, error) {
+	return windows.KnownFolderPath(knownFolderIDs[name], windows.KF_FLAG_DEFAULT)
+}
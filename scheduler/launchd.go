@@ -0,0 +1,634 @@
+package scheduler
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	agentPrefix = "com.github.coffee4coffee.gobackup."
+	// agentJobKey holds the job in the plist, launchd ignores keys it does not know
+	agentJobKey = "GoBackupJob"
+)
+
+// LaunchdScheduler registers every job as a LaunchAgent plist in AgentDir, run by StartCalendarInterval.
+// A disabled job keeps its plist with Disabled set and is unloaded.
+type LaunchdScheduler struct {
+	// AgentDir is the directory of the plists, ~/Library/LaunchAgents when it is empty
+	AgentDir string
+	// Command is the GoBackup executable launchd runs, the running executable when it is empty
+	Command string
+	// Launchctl runs launchctl with the given arguments after a plist changed, nil runs the command
+	Launchctl func(args ...string) error
+	// Now returns the current time, time.Now is used when it is nil
+	Now func() time.Time
+}
+
+func (s *LaunchdScheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *LaunchdScheduler) agentDir() (string, error) {
+	if len(s.AgentDir) > 0 {
+		return s.AgentDir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("agentDir: %w", err)
+	}
+	return filepath.Join(home, "Library", "LaunchAgents"), nil
+}
+
+func (s *LaunchdScheduler) launchctl(args ...string) error {
+	if s.Launchctl != nil {
+		return s.Launchctl(args...)
+	}
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (s *LaunchdScheduler) command() (string, error) {
+	if len(s.Command) > 0 {
+		return s.Command, nil
+	}
+	return os.Executable()
+}
+
+func (s *LaunchdScheduler) domain() string {
+	return "gui/" + strconv.Itoa(os.Getuid())
+}
+
+func agentLabel(id string) string {
+	return agentPrefix + id
+}
+
+// startCalendarInterval translates a schedule into the keys of a launchd calendar interval, in the order launchd documents them
+func startCalendarInterval(schedule Schedule) [][2]string {
+	var interval [][2]string
+	switch schedule.Type {
+	case Weekly:
+		interval = append(interval, [2]string{"Weekday", strconv.Itoa(int(schedule.weekday()))})
+	case Monthly:
+		interval = append(interval, [2]string{"Day", strconv.Itoa(schedule.dayOfMonth())})
+	}
+	return append(interval, [2]string{"Hour", strconv.Itoa(int(schedule.Hour))}, [2]string{"Minute", strconv.Itoa(int(schedule.Minute))})
+}
+
+// maxCalendarIntervals bounds the calendar intervals a cron expression expands to, e.g. every minute of a workday
+const maxCalendarIntervals = 1000
+
+// calendarIntervals translates a schedule into calendar intervals, launchd runs the job on every one of them
+func calendarIntervals(schedule Schedule) ([][][2]string, error) {
+	if err := schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("calendarIntervals: %w", err)
+	}
+	if err := localOnly(schedule, "launchd"); err != nil {
+		return nil, err
+	}
+	if schedule.basic() {
+		return [][][2]string{startCalendarInterval(schedule)}, nil
+	}
+	exprs, err := schedule.CronExprs()
+	if err != nil {
+		return nil, fmt.Errorf("calendarIntervals: %w", err)
+	}
+	var intervals [][][2]string
+	for _, e := range exprs {
+		exprIntervals, err := cronCalendarIntervals(e)
+		if err != nil {
+			return nil, err
+		}
+		if len(intervals)+len(exprIntervals) > maxCalendarIntervals {
+			return nil, &ErrUnsupportedSchedule{Inner: fmt.Errorf("%v runs at too many different times for launchd", schedule.Type), Message: "use the daemon scheduler for this schedule"}
+		}
+		intervals = append(intervals, exprIntervals...)
+	}
+	return intervals, nil
+}
+
+// cronCalendarIntervals expands a cron expression into one interval per combination of the values launchd has no wildcard for
+func cronCalendarIntervals(e CronExpr) ([][][2]string, error) {
+	if e.extended() {
+		return nil, &ErrUnsupportedSchedule{Inner: fmt.Errorf("launchd does not support L or # in %q", e), Message: "use the daemon scheduler for this schedule"}
+	}
+	var weekdays uint64
+	for d, occurrences := range e.weeks {
+		if occurrences != 0 {
+			weekdays |= 1 << d
+		}
+	}
+	allDays, allWeekdays := e.days == 1<<32-2, weekdays == 1<<7-1
+	var days [][2]string
+	switch {
+	case allDays && allWeekdays, !e.domStar && !e.dowStar && (allDays || allWeekdays):
+		// Every day
+	case !e.domStar && !e.dowStar:
+		days = append(intervalValues("Day", uint64(e.days), 1, 31), intervalValues("Weekday", weekdays, 0, 6)...)
+	case allWeekdays:
+		days = intervalValues("Day", uint64(e.days), 1, 31)
+	case allDays:
+		days = intervalValues("Weekday", weekdays, 0, 6)
+	default:
+		return nil, &ErrUnsupportedSchedule{Inner: fmt.Errorf("launchd cannot combine the days in %q", e), Message: "use the daemon scheduler for this schedule"}
+	}
+	intervals := [][][2]string{nil}
+	for _, values := range [][][2]string{intervalValues("Month", uint64(e.months), 1, 12), days, intervalValues("Hour", uint64(e.hours), 0, 23), intervalValues("Minute", e.minutes, 0, 59)} {
+		if len(values) == 0 {
+			continue
+		}
+		if len(intervals)*len(values) > maxCalendarIntervals {
+			return nil, &ErrUnsupportedSchedule{Inner: fmt.Errorf("%q runs at too many different times for launchd", e), Message: "use the daemon scheduler for this schedule"}
+		}
+		var expanded [][][2]string
+		for _, interval := range intervals {
+			for _, kv := range values {
+				expanded = append(expanded, append(append([][2]string{}, interval...), kv))
+			}
+		}
+		intervals = expanded
+	}
+	return intervals, nil
+}
+
+// intervalValues returns the values of a set as calendar interval keys, none if the set holds all of them
+func intervalValues(key string, set uint64, min, max int) [][2]string {
+	var values [][2]string
+	for v := min; v <= max; v++ {
+		if set&(1<<v) != 0 {
+			values = append(values, [2]string{key, strconv.Itoa(v)})
+		}
+	}
+	if len(values) == max-min+1 {
+		return nil
+	}
+	return values
+}
+
+// sameIntervals reports whether a parsed StartCalendarInterval, a dict or an array of dicts, holds the given intervals
+func sameIntervals(parsed interface{}, intervals [][][2]string) bool {
+	dicts, ok := parsed.([]interface{})
+	if !ok {
+		dicts = []interface{}{parsed}
+	}
+	if len(dicts) != len(intervals) {
+		return false
+	}
+	for i, interval := range intervals {
+		dict, ok := dicts[i].(map[string]interface{})
+		if !ok || len(dict) != len(interval) {
+			return false
+		}
+		for _, kv := range interval {
+			if v, ok := dict[kv[0]].(int64); !ok || strconv.FormatInt(v, 10) != kv[1] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseStartCalendarInterval reads a calendar interval as written by startCalendarInterval
+func parseStartCalendarInterval(interval map[string]interface{}) (Schedule, error) {
+	get := func(key string) (int64, bool) {
+		v, ok := interval[key].(int64)
+		return v, ok
+	}
+	s := Schedule{Type: Daily}
+	hour, ok := get("Hour")
+	minute, _ := get("Minute")
+	if !ok || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return Schedule{}, fmt.Errorf("parseStartCalendarInterval: %w", errors.New("unsupported time"))
+	}
+	s.Hour, s.Minute = uint8(hour), uint8(minute)
+	weekday, isWeekly := get("Weekday")
+	day, isMonthly := get("Day")
+	if _, ok := interval["Month"]; ok || (isWeekly && isMonthly) {
+		return Schedule{}, fmt.Errorf("parseStartCalendarInterval: %w", errors.New("unsupported calendar interval"))
+	}
+	if isWeekly {
+		if weekday < 0 || weekday > 7 {
+			return Schedule{}, fmt.Errorf("parseStartCalendarInterval: %w", errors.New("invalid weekday"))
+		}
+		// launchd counts sunday as 0 and 7
+		s.Type, s.DayOfWeek = Weekly, time.Weekday(weekday%7)
+	}
+	if isMonthly {
+		if day < 1 || day > 31 {
+			return Schedule{}, fmt.Errorf("parseStartCalendarInterval: %w", errors.New("invalid day of month"))
+		}
+		s.Type, s.DayOfMonth = Monthly, uint8(day)
+	}
+	return s, nil
+}
+
+// xmlEscape escapes the text of an element, quotes are left as they are to keep the plist readable
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// agentPlist returns the LaunchAgent plist of a job
+func (s *LaunchdScheduler) agentPlist(job Job, enabled bool) (string, error) {
+	intervals, err := calendarIntervals(job.Schedule)
+	if err != nil {
+		return "", err
+	}
+	command, err := s.command()
+	if err != nil {
+		return "", fmt.Errorf("agentPlist: %w", err)
+	}
+	doc, err := job.encode()
+	if err != nil {
+		return "", fmt.Errorf("agentPlist: %w", err)
+	}
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + xmlEscape(agentLabel(job.ID)) + `</string>
+	<key>ProgramArguments</key>
+	<array>
+`)
+	// -scheduled skips the run while the job is paused, -jitter waits for the random delay of the run
+	args := []string{command, "run", "-scheduler", "launchd", "-job", job.ID, "-scheduled"}
+	if job.Schedule.Jitter > 0 {
+		args = append(args, "-jitter")
+	}
+	for _, arg := range args {
+		b.WriteString("\t\t<string>" + xmlEscape(arg) + "</string>\n")
+	}
+	b.WriteString("\t</array>\n\t<key>StartCalendarInterval</key>\n")
+	// A single interval is a dict, several are an array of dicts
+	indent := "\t"
+	if len(intervals) > 1 {
+		b.WriteString("\t<array>\n")
+		indent = "\t\t"
+	}
+	for _, interval := range intervals {
+		b.WriteString(indent + "<dict>\n")
+		for _, kv := range interval {
+			b.WriteString(indent + "\t<key>" + kv[0] + "</key>\n" + indent + "\t<integer>" + kv[1] + "</integer>\n")
+		}
+		b.WriteString(indent + "</dict>\n")
+	}
+	if len(intervals) > 1 {
+		b.WriteString("\t</array>\n")
+	}
+	b.WriteString("\t<key>Disabled</key>\n")
+	if enabled {
+		b.WriteString("\t<false/>\n")
+	} else {
+		b.WriteString("\t<true/>\n")
+	}
+	b.WriteString("\t<key>" + agentJobKey + "</key>\n\t<string>" + xmlEscape(doc) + "</string>\n</dict>\n</plist>\n")
+	return b.String(), nil
+}
+
+// parseAgentPlist reads the job of a plist back, the schedule is taken from StartCalendarInterval as it is what launchd runs
+func parseAgentPlist(r io.Reader) (job Job, enabled bool, err error) {
+	root, err := parsePlist(r)
+	if err != nil {
+		return Job{}, false, fmt.Errorf("parseAgentPlist: %w", err)
+	}
+	dict, ok := root.(map[string]interface{})
+	if !ok {
+		return Job{}, false, fmt.Errorf("parseAgentPlist: %w", errors.New("plist is not a dict"))
+	}
+	doc, _ := dict[agentJobKey].(string)
+	job, _, err = ParseJob(doc)
+	if err != nil {
+		return Job{}, false, fmt.Errorf("parseAgentPlist: %w", err)
+	}
+	// A custom schedule is kept as long as the plist still runs on it
+	if intervals, err := calendarIntervals(job.Schedule); err != nil || !sameIntervals(dict["StartCalendarInterval"], intervals) {
+		interval, ok := dict["StartCalendarInterval"].(map[string]interface{})
+		if !ok {
+			return Job{}, false, fmt.Errorf("parseAgentPlist: %w", errors.New("missing StartCalendarInterval"))
+		}
+		job.Schedule, err = parseStartCalendarInterval(interval)
+		if err != nil {
+			return Job{}, false, fmt.Errorf("parseAgentPlist: %w", err)
+		}
+	}
+	disabled, _ := dict["Disabled"].(bool)
+	return job, !disabled, nil
+}
+
+// parsePlist reads an XML property list into strings, int64, bools, slices and maps
+func parsePlist(r io.Reader) (interface{}, error) {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if start.Name.Local != "plist" {
+				return nil, fmt.Errorf("parsePlist: %w", fmt.Errorf("unexpected element %v", start.Name.Local))
+			}
+			start, err := nextStart(dec)
+			if err != nil {
+				return nil, fmt.Errorf("parsePlist: %w", err)
+			}
+			return parsePlistValue(dec, start)
+		}
+	}
+}
+
+// nextStart returns the next start element, an end element in between is an error
+func nextStart(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			return xml.StartElement{}, errEndElement
+		}
+	}
+}
+
+var errEndElement = errors.New("unexpected end element")
+
+func parsePlistValue(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "true", "false":
+		if err := dec.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	case "string", "integer", "key":
+		var text string
+		if err := dec.DecodeElement(&text, &start); err != nil {
+			return nil, err
+		}
+		if start.Name.Local == "integer" {
+			return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+		}
+		return text, nil
+	case "array":
+		var values []interface{}
+		for {
+			next, err := nextStart(dec)
+			if err == errEndElement {
+				return values, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			value, err := parsePlistValue(dec, next)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+	case "dict":
+		values := map[string]interface{}{}
+		for {
+			keyStart, err := nextStart(dec)
+			if err == errEndElement {
+				return values, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if keyStart.Name.Local != "key" {
+				return nil, fmt.Errorf("expected key, found %v", keyStart.Name.Local)
+			}
+			var key string
+			if err := dec.DecodeElement(&key, &keyStart); err != nil {
+				return nil, err
+			}
+			valueStart, err := nextStart(dec)
+			if err != nil {
+				return nil, err
+			}
+			value, err := parsePlistValue(dec, valueStart)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+	}
+	// Types the agents do not use, such as real, date and data, are skipped
+	return nil, dec.Skip()
+}
+
+func (s *LaunchdScheduler) plistPath(dir, id string) string {
+	return filepath.Join(dir, agentLabel(id)+".plist")
+}
+
+func (s *LaunchdScheduler) readTask(path string) Task {
+	id := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), agentPrefix), ".plist")
+	task := Task{Name: agentLabel(id)}
+	f, err := os.Open(path)
+	if err != nil {
+		task.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to read plist"}
+		return task
+	}
+	defer f.Close()
+	job, enabled, err := parseAgentPlist(f)
+	if err != nil {
+		task.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to parse plist"}
+		return task
+	}
+	job.ID = id
+	task.Job, task.Enabled = job, enabled
+	if enabled {
+		after := s.now()
+		if job.PausedUntil.After(after) {
+			after = job.PausedUntil
+		}
+		task.NextRunTime = nextRunTime(job.Schedule, after)
+	}
+	return task
+}
+
+func (s *LaunchdScheduler) List() ([]Task, error) {
+	dir, err := s.agentDir()
+	if err != nil {
+		return nil, &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find LaunchAgents directory"}
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, agentPrefix+"*.plist"))
+	if err != nil {
+		return nil, &ErrRetrieveTasksFailure{Inner: err, Message: "failed to read LaunchAgents directory"}
+	}
+	var history map[string]HistoryEntry
+	if h, err := ReadHistory(); err == nil {
+		history = lastRuns(h)
+	}
+	var tasks []Task
+	for _, path := range paths {
+		task := s.readTask(path)
+		if run, ok := history[task.Job.ID]; ok {
+			task.LastRunTime = run.Time
+			task.LastResult = run.Message
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+func (s *LaunchdScheduler) Get(id string) (Task, error) {
+	tasks, err := s.List()
+	if err != nil {
+		return Task{}, err
+	}
+	for _, task := range tasks {
+		if task.Job.ID == id || task.Name == id {
+			return task, nil
+		}
+	}
+	return Task{}, &ErrTaskNotFound{Inner: fmt.Errorf("no LaunchAgent for job %q", id), Message: "failed to find task"}
+}
+
+func (s *LaunchdScheduler) writePlist(dir string, job Job, enabled bool) error {
+	plist, err := s.agentPlist(job, enabled)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.plistPath(dir, job.ID), []byte(plist), 0o644)
+}
+
+// load bootstraps the agent, after unloading it as launchd keeps the old plist of a loaded agent
+func (s *LaunchdScheduler) load(dir, id string) error {
+	s.unload(id)
+	return s.launchctl("bootstrap", s.domain(), s.plistPath(dir, id))
+}
+
+// unload removes the agent from launchd, an agent that is not loaded is not an error
+func (s *LaunchdScheduler) unload(id string) {
+	s.launchctl("bootout", s.domain()+"/"+agentLabel(id))
+}
+
+func (s *LaunchdScheduler) Create(job Job) (Task, error) {
+	dir, err := s.agentDir()
+	if err != nil {
+		return Task{}, &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find LaunchAgents directory"}
+	}
+	if !validUnitID(job.ID) {
+		return Task{}, &ErrCreateTaskFailure{Inner: fmt.Errorf("invalid job ID %q", job.ID), Message: "failed to create plist"}
+	}
+	if _, err := os.Stat(s.plistPath(dir, job.ID)); err == nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: errors.New("a job with the same ID already exists"), Message: "failed to create plist"}
+	}
+	var unsupported *ErrUnsupportedSchedule
+	if err := s.writePlist(dir, job, true); errors.As(err, &unsupported) {
+		return Task{}, unsupported
+	} else if err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to create plist"}
+	}
+	if err := s.load(dir, job.ID); err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to load LaunchAgent"}
+	}
+	return s.Get(job.ID)
+}
+
+// Update rewrites the plist of the job, it keeps its enabled state
+func (s *LaunchdScheduler) Update(job Job) (Task, error) {
+	task, err := s.Get(job.ID)
+	if err != nil {
+		return Task{}, err
+	}
+	dir, err := s.agentDir()
+	if err != nil {
+		return Task{}, &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find LaunchAgents directory"}
+	}
+	enabled := task.Enabled || task.Err != nil
+	var unsupported *ErrUnsupportedSchedule
+	if err := s.writePlist(dir, job, enabled); errors.As(err, &unsupported) {
+		return Task{}, unsupported
+	} else if err != nil {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "failed to update plist"}
+	}
+	if enabled {
+		if err := s.load(dir, job.ID); err != nil {
+			return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "failed to reload LaunchAgent"}
+		}
+	}
+	return s.Get(job.ID)
+}
+
+func (s *LaunchdScheduler) Delete(id string) error {
+	task, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	dir, err := s.agentDir()
+	if err != nil {
+		return &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find LaunchAgents directory"}
+	}
+	// Unreadable plists are found by their label, which is the prefix and the ID
+	id = strings.TrimPrefix(task.Name, agentPrefix)
+	s.unload(id)
+	if err := os.Remove(s.plistPath(dir, id)); err != nil {
+		return &ErrDeleteTaskFailure{Inner: err, Message: "failed to delete plist"}
+	}
+	return nil
+}
+
+// Run backs up the job right away through the engine and waits for it to finish
+func (s *LaunchdScheduler) Run(id string) error {
+	task, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &ErrRunTaskFailure{Inner: task.Err, Message: "failed to read task"}
+	}
+	return RunJob(task.Job, s.now())
+}
+
+func (s *LaunchdScheduler) Enable(id string) error {
+	return s.setEnabled(id, true)
+}
+
+func (s *LaunchdScheduler) Disable(id string) error {
+	return s.setEnabled(id, false)
+}
+
+func (s *LaunchdScheduler) setEnabled(id string, enabled bool) error {
+	task, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &ErrParseTaskFailure{Inner: task.Err, Message: "failed to read task " + task.Name}
+	}
+	dir, err := s.agentDir()
+	if err != nil {
+		return &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find LaunchAgents directory"}
+	}
+	if err := s.writePlist(dir, task.Job, enabled); err != nil {
+		return &ErrUpdateTaskFailure{Inner: err, Message: "failed to update plist"}
+	}
+	if !enabled {
+		s.unload(task.Job.ID)
+		return nil
+	}
+	if err := s.load(dir, task.Job.ID); err != nil {
+		return &ErrUpdateTaskFailure{Inner: err, Message: "failed to load LaunchAgent"}
+	}
+	return nil
+}
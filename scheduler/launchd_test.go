@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// golden compares the result with a file in testdata, -update writes the result instead
+func golden(t *testing.T, name, result string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(result), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != string(want) {
+		t.Errorf(`%v = %v, want %v`, name, result, string(want))
+	}
+}
+
+func TestAgentPlist(t *testing.T) {
+	s := &LaunchdScheduler{Command: "/Applications/GoBackup.app/Contents/MacOS/GoBackup"}
+	testcases := []struct {
+		name     string
+		schedule Schedule
+		enabled  bool
+	}{
+		{"daily.plist", Schedule{Type: Daily, Hour: 17}, true},
+		{"weekly.plist", Schedule{Type: Weekly, DayOfWeek: time.Wednesday, Hour: 0}, true},
+		{"monthly.plist", Schedule{Type: Monthly, DayOfMonth: 31, Hour: 23}, false},
+		{"custom.plist", Schedule{Type: Custom, Expr: "0 8-18/4 * * 1-5"}, true},
+	}
+	for _, tc := range testcases {
+		job := Job{Version: 1, ID: "6f1c2a4e-8b3d-4e5f-9a0b-1c2d3e4f5a6b", Label: "Tom & Jerry's <files>", Src: "/Users/tom/Tom & Jerry's <files>", Dest: "/Volumes/Backup", BackupLimit: 3, Schedule: tc.schedule}
+		plist, err := s.agentPlist(job, tc.enabled)
+		if err != nil {
+			t.Fatalf(`agentPlist(%+v) returned error %v`, job, err)
+		}
+		golden(t, filepath.Join("launchd", tc.name), plist)
+
+		parsed, enabled, err := parseAgentPlist(strings.NewReader(plist))
+		if err != nil || !reflect.DeepEqual(parsed, job) || enabled != tc.enabled {
+			t.Errorf(`parseAgentPlist(agentPlist(%+v)) = %+v, %v, %v want the same job`, job, parsed, enabled, err)
+		}
+	}
+}
+
+func TestParseStartCalendarInterval(t *testing.T) {
+	testcases := []struct {
+		interval  map[string]interface{}
+		want      Schedule
+		wantError bool
+	}{
+		{map[string]interface{}{"Hour": int64(17), "Minute": int64(0)}, Schedule{Type: Daily, Hour: 17}, false},
+		{map[string]interface{}{"Hour": int64(9)}, Schedule{Type: Daily, Hour: 9}, false},
+		{map[string]interface{}{"Weekday": int64(7), "Hour": int64(9)}, Schedule{Type: Weekly, DayOfWeek: time.Sunday, Hour: 9}, false},
+		{map[string]interface{}{"Day": int64(6), "Hour": int64(9)}, Schedule{Type: Monthly, DayOfMonth: 6, Hour: 9}, false},
+		{map[string]interface{}{"Minute": int64(0)}, Schedule{}, true},
+		{map[string]interface{}{"Hour": int64(9), "Minute": int64(30)}, Schedule{Type: Daily, Hour: 9, Minute: 30}, false},
+		{map[string]interface{}{"Hour": int64(9), "Minute": int64(60)}, Schedule{}, true},
+		{map[string]interface{}{"Hour": int64(24)}, Schedule{}, true},
+		{map[string]interface{}{"Day": int64(32), "Hour": int64(9)}, Schedule{}, true},
+		{map[string]interface{}{"Day": int64(1), "Weekday": int64(1), "Hour": int64(9)}, Schedule{}, true},
+		{map[string]interface{}{"Month": int64(1), "Day": int64(1), "Hour": int64(9)}, Schedule{}, true},
+	}
+	for _, tc := range testcases {
+		result, err := parseStartCalendarInterval(tc.interval)
+		if tc.wantError && err == nil {
+			t.Errorf(`parseStartCalendarInterval(%v) did not return an error`, tc.interval)
+		}
+		if !tc.wantError && (err != nil || result != tc.want) {
+			t.Errorf(`parseStartCalendarInterval(%v) = %+v, %v want match for %+v`, tc.interval, result, err, tc.want)
+		}
+	}
+}
+
+func TestCalendarIntervals(t *testing.T) {
+	testcases := []struct {
+		expr      string
+		want      int
+		wantError bool
+	}{
+		{"30 9 * * *", 1, false},
+		{"*/15 * * * *", 4, false},
+		{"0 9 1,15 * 1", 3, false},
+		{"0 9 1 1-3 *", 3, false},
+		{"0 9 1-31 * 1", 1, false},
+		{"0 9 L * *", 0, true},
+		{"0 9 * * 1#2", 0, true},
+		{"0 9 */2 * 1", 0, true},
+		{"* 9-17 * * 1-5", 45, false},
+		{"1-59 9-17 * * 1-5", 0, true},
+	}
+	for _, tc := range testcases {
+		result, err := calendarIntervals(Schedule{Type: Custom, Expr: tc.expr})
+		if tc.wantError {
+			if _, ok := err.(*ErrUnsupportedSchedule); !ok {
+				t.Errorf(`calendarIntervals(%v) = %v, %v want ErrUnsupportedSchedule`, tc.expr, result, err)
+			}
+			continue
+		}
+		if err != nil || len(result) != tc.want {
+			t.Errorf(`calendarIntervals(%v) = %v, %v want %v intervals`, tc.expr, result, err, tc.want)
+		}
+	}
+
+	zoned := Schedule{Type: Daily, Hour: 9, TimeZone: "Asia/Tokyo"}
+	if result, err := calendarIntervals(zoned); err == nil {
+		t.Errorf(`calendarIntervals(%+v) = %v, want ErrUnsupportedSchedule`, zoned, result)
+	}
+}
+
+func TestLaunchdScheduler(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	var calls []string
+	s := &LaunchdScheduler{
+		AgentDir:  dir,
+		Command:   "/usr/local/bin/gobackup",
+		Launchctl: func(args ...string) error { calls = append(calls, args[0]); return nil },
+	}
+	os.WriteFile(filepath.Join(dir, "com.example.other.plist"), []byte("<plist/>"), 0o644)
+
+	job := NewJob(3, "/Users/tom/Documents", "/Volumes/Backup", false)
+	job.Schedule = Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 9}
+	if task, err := s.Create(job); err != nil || !reflect.DeepEqual(task.Job, job) || !task.Enabled || task.Name != agentLabel(job.ID) {
+		t.Fatalf(`Create(%+v) = %+v, %v want an enabled task of the job`, job, task, err)
+	}
+	if _, err := s.Create(job); err == nil {
+		t.Errorf(`Create(job) did not return an error for a duplicate ID`)
+	}
+
+	if err := s.Disable(job.ID); err != nil {
+		t.Fatalf(`Disable(%v) returned error %v`, job.ID, err)
+	}
+	job.Schedule = Schedule{Type: Daily, Hour: 6}
+	if task, err := s.Update(job); err != nil || task.Enabled || !reflect.DeepEqual(task.Job, job) {
+		t.Errorf(`Update(%+v) = %+v, %v want the changed job still disabled`, job, task, err)
+	}
+	if tasks, err := s.List(); err != nil || len(tasks) != 1 {
+		t.Errorf(`List() = %+v, %v want only the job`, tasks, err)
+	}
+
+	if err := s.Delete(job.ID); err != nil {
+		t.Fatalf(`Delete(%v) returned error %v`, job.ID, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != "com.example.other.plist" {
+		t.Errorf(`Delete(%v) left %v, want only the foreign plist`, job.ID, entries)
+	}
+	if fmt.Sprint(calls) != "[bootout bootstrap bootout bootout]" {
+		t.Errorf(`launchctl calls = %v, want the agent loaded on create and unloaded on disable and delete`, calls)
+	}
+}
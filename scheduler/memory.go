@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryScheduler keeps jobs in memory only. It does not run jobs on its own, Run calls RunFunc if it is set.
+// It is meant for tests and as a fallback on platforms without a scheduler backend.
+type MemoryScheduler struct {
+	// RunFunc is called by Run, a nil RunFunc only records the run
+	RunFunc func(job Job) error
+	// Now returns the current time, time.Now is used when it is nil
+	Now func() time.Time
+
+	mu    sync.Mutex
+	tasks []Task
+}
+
+func NewMemoryScheduler() *MemoryScheduler {
+	return &MemoryScheduler{}
+}
+
+func (s *MemoryScheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *MemoryScheduler) index(id string) int {
+	for i, task := range s.tasks {
+		if task.Job.ID == id || task.Name == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryScheduler) notFound(id string) error {
+	return &ErrTaskNotFound{Inner: fmt.Errorf("no task for job %q", id), Message: "failed to find task"}
+}
+
+func (s *MemoryScheduler) List() ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Task(nil), s.tasks...), nil
+}
+
+func (s *MemoryScheduler) Get(id string) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return Task{}, s.notFound(id)
+	}
+	return s.tasks[i], nil
+}
+
+func (s *MemoryScheduler) Create(job Job) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := job.Schedule.Validate(); err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "invalid schedule"}
+	}
+	if len(job.ID) == 0 || s.index(job.ID) >= 0 {
+		return Task{}, &ErrCreateTaskFailure{Inner: errors.New("missing or duplicate job ID"), Message: "failed to create task"}
+	}
+	task := Task{Job: job, Name: parseTaskPath(job.Label, job.ID), Enabled: true}
+	task.NextRunTime = s.nextRunTime(task)
+	s.tasks = append(s.tasks, task)
+	return task, nil
+}
+
+func (s *MemoryScheduler) Update(job Job) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := job.Schedule.Validate(); err != nil {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "invalid schedule"}
+	}
+	i := s.index(job.ID)
+	if i < 0 {
+		return Task{}, s.notFound(job.ID)
+	}
+	s.tasks[i].Job = job
+	s.tasks[i].NextRunTime = s.nextRunTime(s.tasks[i])
+	return s.tasks[i], nil
+}
+
+func (s *MemoryScheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return s.notFound(id)
+	}
+	s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+	return nil
+}
+
+// Run calls RunFunc synchronously and records the result like a scheduler would
+func (s *MemoryScheduler) Run(id string) error {
+	s.mu.Lock()
+	i := s.index(id)
+	if i < 0 {
+		s.mu.Unlock()
+		return s.notFound(id)
+	}
+	job := s.tasks[i].Job
+	s.mu.Unlock()
+
+	var err error
+	if s.RunFunc != nil {
+		err = s.RunFunc(job)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i = s.index(id); i >= 0 {
+		s.tasks[i].LastRunTime = s.now()
+		s.tasks[i].LastResult = "OK"
+		if err != nil {
+			s.tasks[i].LastResult = err.Error()
+		}
+	}
+	if err != nil {
+		return &ErrRunTaskFailure{Inner: err, Message: "failed to run task"}
+	}
+	return nil
+}
+
+func (s *MemoryScheduler) Enable(id string) error {
+	return s.setEnabled(id, true)
+}
+
+func (s *MemoryScheduler) Disable(id string) error {
+	return s.setEnabled(id, false)
+}
+
+func (s *MemoryScheduler) setEnabled(id string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return s.notFound(id)
+	}
+	s.tasks[i].Enabled = enabled
+	s.tasks[i].NextRunTime = s.nextRunTime(s.tasks[i])
+	return nil
+}
+
+// nextRunTime mirrors the task scheduler, disabled tasks have no next run and paused ones run after the pause
+func (s *MemoryScheduler) nextRunTime(task Task) time.Time {
+	if !task.Enabled {
+		return time.Time{}
+	}
+	after := s.now()
+	if task.Job.PausedUntil.After(after) {
+		after = task.Job.PausedUntil
+	}
+	return nextRunTime(task.Job.Schedule, after)
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryScheduler(t *testing.T) {
+	now := time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC)
+	var ran []string
+	s := NewMemoryScheduler()
+	s.Now = func() time.Time { return now }
+	s.RunFunc = func(job Job) error {
+		ran = append(ran, job.ID)
+		if job.Overwrite {
+			return errors.New("disk full")
+		}
+		return nil
+	}
+
+	job := NewJob(3, `C:\test`, `Z:\backupme`, false)
+	job.Schedule = Schedule{Type: Daily, Hour: 18}
+	task, err := s.Create(job)
+	if err != nil || !task.Enabled || !task.NextRunTime.Equal(time.Date(2022, 4, 2, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf(`Create(job) = %+v, %v want an enabled task running at 18:00`, task, err)
+	}
+	if _, err := s.Create(job); err == nil {
+		t.Errorf(`Create(job) did not return an error for a duplicate ID`)
+	}
+	invalid := NewJob(0, `C:\test`, `Z:\backupme`, false)
+	invalid.Schedule = Schedule{Type: Monthly, DayOfMonth: 32}
+	if _, err := s.Create(invalid); err == nil {
+		t.Errorf(`Create(invalid) did not return an error for an invalid schedule`)
+	}
+
+	job.Label = "renamed"
+	job.PausedUntil = time.Date(2022, 4, 10, 0, 0, 0, 0, time.UTC)
+	if task, err = s.Update(job); err != nil || task.Job.Label != "renamed" || !task.NextRunTime.Equal(time.Date(2022, 4, 10, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf(`Update(job) = %+v, %v want the renamed job running after the pause`, task, err)
+	}
+	if found, err := Find(s, "renamed"); err != nil || found.Job.ID != job.ID {
+		t.Errorf(`Find(s, "renamed") = %+v, %v want %v`, found, err, job.ID)
+	}
+	if err := ResumeAll(s); err != nil {
+		t.Fatalf(`ResumeAll(s) returned error %v`, err)
+	}
+	if task, _ = s.Get(job.ID); task.Paused(now) {
+		t.Errorf(`ResumeAll(s) left %+v paused`, task)
+	}
+
+	if err := s.Disable(job.ID); err != nil {
+		t.Fatalf(`Disable(%v) returned error %v`, job.ID, err)
+	}
+	if task, _ = s.Get(job.ID); task.Enabled || !task.NextRunTime.IsZero() {
+		t.Errorf(`Disable(%v) = %+v, want a disabled task without next run`, job.ID, task)
+	}
+
+	if err := s.Run(job.ID); err != nil || len(ran) != 1 {
+		t.Errorf(`Run(%v) = %v, ran %v want a single run`, job.ID, err, ran)
+	}
+	if task, _ = s.Get(job.ID); !task.LastRunTime.Equal(now) || task.LastResult != "OK" {
+		t.Errorf(`Run(%v) = %+v, want the run recorded`, job.ID, task)
+	}
+	job.Overwrite = true
+	s.Update(job)
+	var runErr *ErrRunTaskFailure
+	if err := s.Run(job.ID); !errors.As(err, &runErr) {
+		t.Errorf(`Run(%v) = %v, want ErrRunTaskFailure`, job.ID, err)
+	}
+
+	if err := s.Delete(job.ID); err != nil {
+		t.Fatalf(`Delete(%v) returned error %v`, job.ID, err)
+	}
+	var notFound *ErrTaskNotFound
+	if _, err := s.Get(job.ID); !errors.As(err, &notFound) {
+		t.Errorf(`Get(%v) = %v after Delete, want ErrTaskNotFound`, job.ID, err)
+	}
+	if tasks, _ := s.List(); len(tasks) != 0 {
+		t.Errorf(`List() = %v after Delete, want none`, tasks)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// driveRootConnected reports whether the drive a path is on is mounted, before the path itself exists. The nearest folder
+// of the path that exists has to be the mount point of another file system than its parent, the mount point of a drive
+// that is not mounted is a folder on the disk of its parent.
+func driveRootConnected(path string) bool {
+	dir := filepath.Clean(path)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			parent, err := os.Stat(filepath.Dir(dir))
+			return err == nil && dir != filepath.Dir(dir) && device(info) != device(parent)
+		}
+		if dir == filepath.Dir(dir) {
+			return false
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func device(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev)
+	}
+	return 0
+}
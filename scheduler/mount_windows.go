@@ -0,0 +1,17 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// driveRootConnected reports whether the drive a path is on is connected, before the path itself exists. The root of a
+// drive letter or a share only exists while it is connected.
+func driveRootConnected(path string) bool {
+	volume := filepath.VolumeName(path)
+	if len(volume) == 0 {
+		return false
+	}
+	_, err := os.Stat(volume + `\`)
+	return err == nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !linux && !darwin
+
+package scheduler
+
+// New returns the scheduler of the platform, jobs are added to the crontab of the user
+func New() (Scheduler, error) {
+	return &CronScheduler{}, nil
+}
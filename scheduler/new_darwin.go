@@ -0,0 +1,6 @@
+package scheduler
+
+// New returns the scheduler of the platform, jobs become LaunchAgents of the user
+func New() (Scheduler, error) {
+	return &LaunchdScheduler{}, nil
+}
@@ -0,0 +1,12 @@
+package scheduler
+
+import "os"
+
+// New returns the scheduler of the platform, systemd timers when systemd is running and the crontab otherwise
+func New() (Scheduler, error) {
+	// The same check as sd_booted
+	if _, err := os.Stat("/run/systemd/system"); err == nil {
+		return &SystemdScheduler{}, nil
+	}
+	return &CronScheduler{}, nil
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package scheduler
+
+import "os/exec"
+
+// systemNotify shows a desktop notification through notify-send, the history is the only record where it is not installed
+func systemNotify(title, message string) {
+	path, err := exec.LookPath("notify-send")
+	if err != nil {
+		return
+	}
+	exec.Command(path, "--app-name="+appTitle, title, message).Run()
+}
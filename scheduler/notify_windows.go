@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// toastScript shows a toast with the title and message of the environment, so they never end up in the script itself
+const toastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null;
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02);
+$toastXml = [xml] $template.GetXml();
+$toastXml.GetElementsByTagName('text')[0].AppendChild($toastXml.CreateTextNode($env:GOBACKUP_TOAST_TITLE)) > $null;
+$toastXml.GetElementsByTagName('text')[1].AppendChild($toastXml.CreateTextNode($env:GOBACKUP_TOAST_MESSAGE)) > $null;
+$actionsElement = $toastXml.CreateElement('actions'); $actionElement = $toastXml.CreateElement('action');
+$actionElement.SetAttribute('content', 'Dismiss'); $actionElement.SetAttribute('arguments', 'dismiss');
+$actionElement.SetAttribute('activationType', 'system');
+$actionsElement.AppendChild($actionElement) > $null;
+$toastXml.DocumentElement.AppendChild($actionsElement) > $null;
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument;
+$xml.LoadXml($toastXml.OuterXml);
+$toast = [Windows.UI.Notifications.ToastNotification]::new($xml);
+$toast.Tag = $env:GOBACKUP_TOAST_APP;
+$toast.Group = $env:GOBACKUP_TOAST_APP;
+$toast.ExpirationTime = [DateTimeOffset]::Now.AddMinutes([int] $env:GOBACKUP_TOAST_EXPIRATION);
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($env:GOBACKUP_TOAST_APP).Show($toast);
+`
+
+// systemNotify shows a toast through a hidden powershell, the history is the only record where it fails
+func systemNotify(title, message string) {
+	cmd := exec.Command("Powershell", "-NoProfile", "-NonInteractive", "-EncodedCommand", encodeCommand(toastScript))
+	cmd.Env = append(os.Environ(),
+		"GOBACKUP_TOAST_TITLE="+time.Now().Format("15:04")+": "+title,
+		"GOBACKUP_TOAST_MESSAGE="+message,
+		"GOBACKUP_TOAST_APP="+appTitle,
+		fmt.Sprintf("GOBACKUP_TOAST_EXPIRATION=%v", toastExpirationTimeInMinutes),
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	cmd.Run()
+}
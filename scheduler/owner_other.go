@@ -0,0 +1,23 @@
+//go:build !windows
+
+package scheduler
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// copyOwner gives the copy at path the owner and group of the original, which only root is allowed to.
+// The copy keeps the user that runs the backup as its owner otherwise.
+func copyOwner(path string, info fs.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := os.Lchown(path, int(stat.Uid), int(stat.Gid)); err != nil && !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	return nil
+}
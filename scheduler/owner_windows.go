@@ -0,0 +1,9 @@
+package scheduler
+
+import "io/fs"
+
+// copyOwner leaves the owner and the ACL of the copy at path to the destination, whose folder they are inherited from.
+// xcopy /o /x copied them in older versions, which needs the backup to run elevated.
+func copyOwner(path string, info fs.FileInfo) error {
+	return nil
+}
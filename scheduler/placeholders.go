@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// KnownFolders are the names of the folders a path can start with in braces, such as {Documents}\Taxes
+var KnownFolders = []string{"Home", "Desktop", "Documents", "Downloads", "Music", "Pictures", "Videos"}
+
+// placeholderPattern matches escaped percent signs %%, environment variables such as %USERPROFILE%, known folders such
+// as {Documents} and volumes such as {Label:BACKUP}. Other words in braces are part of the path, e.g. D:\{Archive}.
+var placeholderPattern = regexp.MustCompile(`%%|%([A-Za-z_][A-Za-z0-9_()]*)%|\{(?i:(` + strings.Join(KnownFolders, "|") +
+	`)|(` + VolumeLabel + `|` + VolumeUUID + `):([^{}:]+))\}`)
+
+// HasPlaceholders reports whether a path contains placeholders that ExpandPath replaces
+func HasPlaceholders(path string) bool {
+	return placeholderPattern.MatchString(path)
+}
+
+// ExpandPath replaces the environment variables, known folders and volumes of a path by their value on this machine and
+// user. Jobs keep their paths with placeholders, which are only expanded when they run, so the same job works for other
+// users and machines. A variable that is not set is an error rather than part of the path, %% is a percent sign of the
+// path. A volume that is not connected is an ErrVolumeNotFound.
+func ExpandPath(path string) (string, error) {
+	var err error
+	expanded := placeholderPattern.ReplaceAllStringFunc(path, func(placeholder string) string {
+		m := placeholderPattern.FindStringSubmatch(placeholder)
+		switch {
+		case placeholder == "%%":
+			return "%"
+		case len(m[1]) > 0:
+			v, ok := os.LookupEnv(m[1])
+			if !ok && err == nil {
+				err = fmt.Errorf("environment variable %v is not set", m[1])
+			}
+			return v
+		case len(m[3]) > 0:
+			return expandVolume(m[3], m[4], &err)
+		}
+		for _, name := range KnownFolders {
+			if !strings.EqualFold(m[2], name) {
+				continue
+			}
+			dir, folderErr := knownFolder(name)
+			if folderErr != nil && err == nil {
+				err = fmt.Errorf("folder {%v}: %w", name, folderErr)
+			}
+			return dir
+		}
+		return placeholder
+	})
+	if err != nil {
+		return "", fmt.Errorf("ExpandPath: %w", err)
+	}
+	if expanded == path {
+		return path, nil
+	}
+	if len(expanded) == 0 {
+		return "", fmt.Errorf("ExpandPath: %w", errors.New("the path is empty"))
+	}
+	// The values use the separators of this machine, the rest of the path might not
+	return filepath.Clean(expanded), nil
+}
+
+// expandVolume returns the root folder of a volume placeholder, the first error is kept in err
+func expandVolume(kind, id string, err *error) string {
+	if strings.EqualFold(kind, VolumeLabel) {
+		kind = VolumeLabel
+	} else {
+		kind = VolumeUUID
+	}
+	root, volumeErr := findVolume(kind, id)
+	if volumeErr != nil && *err == nil {
+		*err = volumeErr
+	}
+	return root
+}
+
+// expandReason returns why a path could not be expanded, without the name of the function that wrapped the error
+func expandReason(err error) string {
+	if inner := errors.Unwrap(err); inner != nil {
+		return inner.Error()
+	}
+	return err.Error()
+}
+
+// expandJob returns the job with the placeholders of its src and dest expanded
+func expandJob(job Job) (Job, error) {
+	src, err := ExpandPath(job.Src)
+	if err != nil {
+		return job, err
+	}
+	dest, err := ExpandPath(job.Dest)
+	if err != nil {
+		return job, err
+	}
+	job.Src, job.Dest = src, dest
+	return job, nil
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	home, err := knownFolder("Home")
+	if err != nil {
+		t.Skip("no home folder: ", err)
+	}
+	t.Setenv("GOBACKUP_TEST_DRIVE", filepath.Join(home, "drive"))
+
+	testcases := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{filepath.Join("{Home}", "Documents"), filepath.Join(home, "Documents"), ""},
+		{"{home}/Documents", filepath.Join(home, "Documents"), ""},
+		{filepath.Join("%GOBACKUP_TEST_DRIVE%", "Backup"), filepath.Join(home, "drive", "Backup"), ""},
+		// Paths without placeholders are kept as they are
+		{`C:\Users\Test\100% done`, `C:\Users\Test\100% done`, ""},
+		{"/backup/{1234-5678}", "/backup/{1234-5678}", ""},
+		{`D:\Projects\{Archive}`, `D:\Projects\{Archive}`, ""},
+		{"{Document}/Taxes", "{Document}/Taxes", ""},
+		// %% is a percent sign, e.g. for a folder that looks like a variable
+		{`\\nas\100%%Done%%`, filepath.Clean(`\\nas\100%Done%`), ""},
+		{"%GOBACKUP_TEST_UNSET%/Backup", "", "GOBACKUP_TEST_UNSET is not set"},
+	}
+	for _, tc := range testcases {
+		result, err := ExpandPath(tc.path)
+		if result != tc.want || (err == nil) != (len(tc.wantErr) == 0) || err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf(`ExpandPath(%v) = %v, %v want %v, %v`, tc.path, result, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestExpandReason(t *testing.T) {
+	inner := errors.New("environment variable X is not set")
+	testcases := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("ExpandPath: %w", inner), "environment variable X is not set"},
+		// An error that wraps nothing is its own reason
+		{inner, "environment variable X is not set"},
+	}
+	for _, tc := range testcases {
+		if result := expandReason(tc.err); result != tc.want {
+			t.Errorf(`expandReason(%v) = %q, want %q`, tc.err, result, tc.want)
+		}
+	}
+}
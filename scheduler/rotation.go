@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DriveStatus is a drive of a rotation with the time of its last successful backup, which is zero when it never got one
+type DriveStatus struct {
+	Dest        string    `json:"dest"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	// Overdue is set when the drive has not been backed up to for longer than the alert period of the job
+	Overdue bool `json:"overdue"`
+}
+
+// Dests returns the destinations of a job, its dest followed by the other drives of its rotation
+func (j Job) Dests() []string {
+	return append([]string{j.Dest}, j.Rotation...)
+}
+
+// resolveJob returns the job with its placeholders expanded. The dest of a rotation is the first drive that is
+// connected, which is returned as configured as disk. Without any of them connected the error is an ErrVolumeNotFound,
+// unless a drive could not be resolved for another reason.
+func resolveJob(job Job) (Job, string, error) {
+	if len(job.Rotation) == 0 {
+		expanded, err := expandJob(job)
+		return expanded, "", err
+	}
+	src, err := ExpandPath(job.Src)
+	if err != nil {
+		return job, "", err
+	}
+	disk, dest, err := connectedDrive(job.Dests())
+	if err != nil {
+		return job, "", err
+	}
+	job.Src, job.Dest = src, dest
+	return job, disk, nil
+}
+
+// connectedDrive returns the first of the dests whose drive is connected, as configured and expanded
+func connectedDrive(dests []string) (string, string, error) {
+	var firstErr error
+	for _, dest := range dests {
+		expanded, err := ExpandPath(dest)
+		var volumeErr *ErrVolumeNotFound
+		switch {
+		case errors.As(err, &volumeErr):
+		case err != nil:
+			if firstErr == nil {
+				firstErr = err
+			}
+		case driveConnected(dest, expanded):
+			return dest, expanded, nil
+		}
+	}
+	if firstErr != nil {
+		return "", "", firstErr
+	}
+	return "", "", &ErrVolumeNotFound{Inner: fmt.Errorf("connectedDrive: %w", errors.New("no drive of the rotation is connected")), Message: "drives of the rotation are not connected"}
+}
+
+// driveConnected reports whether the drive of a dest is connected. A dest that does not exist yet, e.g. on a freshly
+// formatted drive, is created on a drive that is connected. Its parent folder is not enough, as the mount point of a drive
+// that is not mounted or its parent folder may well exist, so the drive itself has to be there. On a volume placeholder,
+// which is only expanded when the volume is mounted, the folder the dest is created in is enough.
+func driveConnected(dest, expanded string) bool {
+	if _, err := os.Stat(expanded); err == nil {
+		return true
+	}
+	if !onVolume(dest) {
+		return driveRootConnected(expanded)
+	}
+	_, err := os.Stat(filepath.Dir(expanded))
+	return err == nil
+}
+
+// RotationStatus returns the drives of a job with a rotation and their last successful backup in the history, which is
+// newest first. A drive that never got a backup is overdue once the first run of the job is older than the alert period.
+func RotationStatus(job Job, history []HistoryEntry, now time.Time) []DriveStatus {
+	if len(job.Rotation) == 0 {
+		return nil
+	}
+	var firstRun time.Time
+	for _, entry := range history {
+		if entry.JobID == job.ID {
+			firstRun = entry.Time
+		}
+	}
+	var drives []DriveStatus
+	for _, dest := range job.Dests() {
+		drive := DriveStatus{Dest: dest}
+		for _, entry := range history {
+			// Runs of the backup script and from before the job had a rotation only record the dest they went to
+			disk := entry.Disk
+			if len(disk) == 0 {
+				disk = entry.Dest
+			}
+			if entry.JobID == job.ID && entry.Success() && strings.EqualFold(disk, dest) {
+				drive.LastSuccess = entry.Time
+				break
+			}
+		}
+		since := drive.LastSuccess
+		if since.IsZero() {
+			since = firstRun
+		}
+		period := time.Duration(job.AlertAfterDays) * 24 * time.Hour
+		drive.Overdue = job.AlertAfterDays > 0 && !since.IsZero() && now.Sub(since) > period
+		drives = append(drives, drive)
+	}
+	return drives
+}
+
+// alertOverdueDrives notifies about the drives of a rotation that have not been backed up to for longer than the alert
+// period of the job
+func alertOverdueDrives(job Job, now time.Time) {
+	if len(job.Rotation) == 0 || job.AlertAfterDays == 0 || job.Notify == NotifyNever {
+		return
+	}
+	history, err := ReadHistory()
+	if err != nil {
+		return
+	}
+	var overdue []string
+	for _, drive := range RotationStatus(job, history, now) {
+		if drive.Overdue {
+			overdue = append(overdue, drive.Dest)
+		}
+	}
+	if len(overdue) > 0 {
+		notify("Your backup drive is overdue", fmt.Sprintf("Your folder %v has not been backed up to %v for more than %v days. Connect the drive for one of the next backups.", job.Src, strings.Join(overdue, " and "), job.AlertAfterDays))
+	}
+}
@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRotationStatus(t *testing.T) {
+	job := NewJob(0, `C:\Documents`, `{Label:DISK1}\GoBackup`, false)
+	job.Rotation = []string{`{Label:DISK2}\GoBackup`, `{Label:DISK3}\GoBackup`}
+	job.AlertAfterDays = 14
+	now := time.Date(2022, 4, 30, 17, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time {
+		return time.Date(2022, 4, d, 17, 0, 0, 0, time.UTC)
+	}
+	// Newest first, as ReadHistory returns them
+	history := []HistoryEntry{
+		{Time: day(29), JobID: job.ID, Dest: `E:\GoBackup`, Disk: `{Label:DISK1}\GoBackup`},
+		{Time: day(28), JobID: job.ID, Dest: `F:\GoBackup`, Disk: `{label:disk2}\GoBackup`, ExitCode: exitCodeWriteFailure},
+		{Time: day(27), JobID: "other", Dest: `F:\GoBackup`, Disk: `{Label:DISK2}\GoBackup`},
+		{Time: day(14), JobID: job.ID, Dest: `F:\GoBackup`, Disk: `{Label:DISK2}\GoBackup`},
+		{Time: day(10), JobID: job.ID, Dest: `{Label:DISK1}\GoBackup`},
+		{Time: day(1), JobID: job.ID, Dest: `{Label:DISK1}\GoBackup`, ExitCode: exitCodeVolumeMissing},
+	}
+	testcases := []struct {
+		dest        string
+		lastSuccess time.Time
+		overdue     bool
+	}{
+		{`{Label:DISK1}\GoBackup`, day(29), false},
+		// The failed run does not count, the last success is more than 14 days ago
+		{`{Label:DISK2}\GoBackup`, day(14), true},
+		// Never backed up to since the first run of the job
+		{`{Label:DISK3}\GoBackup`, time.Time{}, true},
+	}
+	drives := RotationStatus(job, history, now)
+	if len(drives) != len(testcases) {
+		t.Fatalf(`RotationStatus(...) = %+v, want %v drives`, drives, len(testcases))
+	}
+	for i, tc := range testcases {
+		if drives[i].Dest != tc.dest || !drives[i].LastSuccess.Equal(tc.lastSuccess) || drives[i].Overdue != tc.overdue {
+			t.Errorf(`RotationStatus(...)[%v] = %+v, want %v, %v, %v`, i, drives[i], tc.dest, tc.lastSuccess, tc.overdue)
+		}
+	}
+
+	if drives := RotationStatus(job, nil, now); drives[2].Overdue {
+		t.Errorf(`RotationStatus(job, nil, now)[2] = %+v, want a new job not to be overdue`, drives[2])
+	}
+	job.AlertAfterDays = 0
+	if drives := RotationStatus(job, history, now); drives[1].Overdue || drives[2].Overdue {
+		t.Errorf(`RotationStatus(...) = %+v, want no drive overdue without an alert period`, drives)
+	}
+	job.Rotation = nil
+	if drives := RotationStatus(job, history, now); drives != nil {
+		t.Errorf(`RotationStatus(...) = %+v, want nil without a rotation`, drives)
+	}
+}
+
+func TestRunJobRotation(t *testing.T) {
+	stubHistory(t)
+	src := filepath.Join(t.TempDir(), "Documents")
+	os.MkdirAll(src, 0o755)
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644)
+	disk1, disk2 := t.TempDir(), t.TempDir()
+
+	job := NewJob(1, src, filepath.Join("{Label:DISK1}", "GoBackup"), false)
+	job.Rotation = []string{filepath.Join("{Label:DISK2}", "GoBackup"), filepath.Join(disk2, "missing", "GoBackup")}
+	start := time.Date(2022, 4, 2, 17, 0, 0, 0, time.Local)
+
+	stubVolumes(t, nil)
+	if err := RunJob(job, start); !errors.As(err, new(*ErrVolumeNotFound)) {
+		t.Errorf(`RunJob(job, ...) = %v, want ErrVolumeNotFound without a connected drive`, err)
+	}
+	// The first connected drive gets the backup, the backup limit applies to each drive on its own
+	stubVolumes(t, map[string]string{"DISK1": disk1, "DISK2": disk2})
+	for i := 1; i <= 2; i++ {
+		if err := RunJob(job, start.AddDate(0, 0, i)); err != nil {
+			t.Fatalf(`RunJob(job, %v) returned error %v`, start.AddDate(0, 0, i), err)
+		}
+	}
+	stubVolumes(t, map[string]string{"DISK2": disk2})
+	if err := RunJob(job, start.AddDate(0, 0, 3)); err != nil {
+		t.Fatalf(`RunJob(job, %v) returned error %v`, start.AddDate(0, 0, 3), err)
+	}
+	if snapshots, err := listSnapshots(filepath.Join(disk1, "GoBackup"), "Documents"); err != nil || len(snapshots) != 1 || snapshots[0] != "Documents-20220404_170000" {
+		t.Errorf(`RunJob(...) snapshots on the first drive = %v, %v want the newest`, snapshots, err)
+	}
+	if snapshots, err := listSnapshots(filepath.Join(disk2, "GoBackup"), "Documents"); err != nil || len(snapshots) != 1 || snapshots[0] != "Documents-20220405_170000" {
+		t.Errorf(`RunJob(...) snapshots on the second drive = %v, %v want the newest`, snapshots, err)
+	}
+
+	history, err := ReadHistory()
+	if err != nil || len(history) != 4 {
+		t.Fatalf(`ReadHistory() = %v, %v want 4 entries`, history, err)
+	}
+	if history[0].Disk != job.Rotation[0] || history[0].Dest != filepath.Join(disk2, "GoBackup") || !history[0].Success() {
+		t.Errorf(`ReadHistory()[0] = %+v, want the run to the second drive`, history[0])
+	}
+	if history[3].Disk != "" || !history[3].Skipped() {
+		t.Errorf(`ReadHistory()[3] = %+v, want the skipped run`, history[3])
+	}
+	drives := RotationStatus(job, history, start.AddDate(0, 0, 3))
+	if len(drives) != 3 || !drives[0].LastSuccess.Equal(start.AddDate(0, 0, 2)) || !drives[1].LastSuccess.Equal(start.AddDate(0, 0, 3)) || !drives[2].LastSuccess.IsZero() {
+		t.Errorf(`RotationStatus(...) = %+v, want the last run of each drive`, drives)
+	}
+}
+
+func TestDriveConnected(t *testing.T) {
+	media := t.TempDir()
+	disk1 := filepath.Join(media, "DISK1")
+	os.MkdirAll(filepath.Join(disk1, "GoBackup"), 0o755)
+	stubVolumes(t, map[string]string{"DISK1": disk1})
+	testcases := []struct {
+		dest string
+		want bool
+	}{
+		{filepath.Join(disk1, "GoBackup"), true},
+		// The mount point of a drive that is not mounted, the backup would go to the disk of its parent.
+		// Windows has no mount points, the folder is created on the drive of media, which is connected.
+		{filepath.Join(media, "DISK2"), runtime.GOOS == "windows"},
+		{filepath.Join(media, "DISK2", "GoBackup"), runtime.GOOS == "windows"},
+		// A mounted volume gets the dest folder created
+		{filepath.Join("{Label:DISK1}", "New"), true},
+		{filepath.Join("{Label:DISK1}", "New", "GoBackup"), false},
+	}
+	for _, tc := range testcases {
+		expanded, err := ExpandPath(tc.dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := driveConnected(tc.dest, expanded); result != tc.want {
+			t.Errorf(`driveConnected(%v, %v) = %v, want %v`, tc.dest, expanded, result, tc.want)
+		}
+	}
+	// A new drive without the dest folder gets its first backup, the root of the drive is there
+	var newDrive string
+	switch runtime.GOOS {
+	case "windows":
+		newDrive = filepath.Join(filepath.VolumeName(media)+`\`, "GoBackup-missing", "GoBackup")
+	case "linux":
+		newDrive = filepath.Join("/proc", "gobackup-missing", "GoBackup")
+	}
+	if len(newDrive) > 0 && !driveConnected(newDrive, newDrive) {
+		t.Errorf(`driveConnected(%v, %v) = false, want true`, newDrive, newDrive)
+	}
+}
@@ -3,23 +3,22 @@ package scheduler
 import (
 	"errors"
 	"fmt"
-	"os"
+	"hash/fnv"
+	"math/bits"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/rickb777/date/period"
-
-	"github.com/capnspacehook/taskmaster"
 )
 
 type TriggerType uint8
 
 const (
-	daily TriggerType = iota
-	weekly
-	monthly
+	Daily TriggerType = iota
+	Weekly
+	Monthly
+	// Custom runs on a cron expression
+	Custom
 )
 const fPath = "\\GoBackup"
 
@@ -29,177 +28,564 @@ const (
 	toastExpirationTimeInMinutes uint8 = 5
 )
 
-func parseTaskPath(src, dest string) string {
-	m := regexp.MustCompile(`[:<>\\/?*|"]`)
-	replaceWith := "_"
-	src = m.ReplaceAllString(src, replaceWith)
-	dest = m.ReplaceAllString(dest, replaceWith)
-	return src + ` ` + dest
+var triggerTypeNames = []string{"daily", "weekly", "monthly", "custom"}
+
+func (t TriggerType) String() string {
+	if int(t) < len(triggerTypeNames) {
+		return triggerTypeNames[t]
+	}
+	return "unknown"
 }
 
-func getValidTime(dHour uint8) (time.Time, error) {
-	if dHour > 23 {
-		return time.Now(), fmt.Errorf("getValidTime: %w", errors.New("invalid hour"))
+func (t TriggerType) MarshalText() ([]byte, error) {
+	if int(t) >= len(triggerTypeNames) {
+		return nil, fmt.Errorf("MarshalText: %w", fmt.Errorf("invalid trigger type %d", t))
 	}
-	t := time.Now()
-	if t.Hour() < int(dHour) {
-		return time.Date(t.Year(), t.Month(), t.Day(), int(dHour), 0, 0, t.Nanosecond(), t.Location()), nil
+	return []byte(t.String()), nil
+}
+
+func (t *TriggerType) UnmarshalText(text []byte) error {
+	for i, name := range triggerTypeNames {
+		if strings.EqualFold(string(text), name) {
+			*t = TriggerType(i)
+			return nil
+		}
 	}
-	// Return time for the next day if already in the past
-	return time.Date(t.Year(), t.Month(), t.Day(), int(dHour), 0, 0, t.Nanosecond(), t.Location()).Add(24 * time.Hour), nil
+	return fmt.Errorf("UnmarshalText: %w", fmt.Errorf("invalid trigger type %q", text))
 }
 
-func createTrigger(tType TriggerType, dMonth, dWeek, dHour uint8) (taskmaster.Trigger, error) {
-	// RepetitionDuration set to 365 days as a workaround to incorrect parsing of period in go-ole
-	// https://github.com/capnspacehook/taskmaster/issues/15
-	startDate, err := getValidTime(dHour)
-	if err != nil {
-		return nil, fmt.Errorf("createTrigger: %w", err)
-	}
-	if dMonth > 30 || dWeek > 6 {
-		return nil, fmt.Errorf("createTrigger: %w", errors.New("invalid day of month/week"))
-	}
-	if tType == daily {
-		return taskmaster.DailyTrigger{
-			TaskTrigger: taskmaster.TaskTrigger{
-				Enabled:       true,
-				StartBoundary: startDate,
-				RepetitionPattern: taskmaster.RepetitionPattern{
-					RepetitionDuration: period.NewYMD(0, 0, 365),
-					RepetitionInterval: period.NewHMS(24, 0, 0),
-				},
-			},
-			DayInterval: taskmaster.EveryDay,
-		}, nil
-	} else if tType == weekly {
-		return taskmaster.WeeklyTrigger{
-			TaskTrigger: taskmaster.TaskTrigger{
-				Enabled:       true,
-				StartBoundary: startDate,
-				RepetitionPattern: taskmaster.RepetitionPattern{
-					RepetitionDuration: period.NewYMD(0, 0, 365),
-				},
-			},
-			WeekInterval: taskmaster.EveryWeek,
-			DaysOfWeek:   taskmaster.DayOfWeek(1 << dWeek),
-		}, nil
-	} else {
-		return taskmaster.MonthlyTrigger{
-			TaskTrigger: taskmaster.TaskTrigger{
-				Enabled:       true,
-				StartBoundary: startDate,
-				RepetitionPattern: taskmaster.RepetitionPattern{
-					RepetitionDuration: period.NewYMD(0, 0, 365),
-				},
-			},
-			DaysOfMonth:  taskmaster.DayOfMonth(1 << dMonth),
-			MonthsOfYear: taskmaster.AllMonths,
-		}, nil
-	}
-}
-
-func createAction(src, dest string, backupLimit uint8, overwrite bool) (taskmaster.ExecAction, error) {
-	r := regexp.MustCompile(`[^\\]+$`)
-	folder := r.FindString(src)
-
-	// pwsPath := `\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`
-	systemDrive := os.Getenv("SYSTEMDRIVE")
-	pwScript := createPwScript(src, dest, folder, appTitle, backupLimit, toastExpirationTimeInMinutes, overwrite)
-	if len(systemDrive) == 0 {
-		return taskmaster.ExecAction{}, fmt.Errorf("createAction: failed to retrieve systemdrive: %w", errors.New("SYSTEMDRIVE not found"))
-	}
-
-	return taskmaster.ExecAction{
-		Path: `Powershell`,
-		Args: pwScript,
-	}, nil
-}
-
-func GetAllScheduledTasks() (taskmaster.RegisteredTaskCollection, error) {
-	conn, err := taskmaster.Connect()
+// maxJitter bounds the random delay of a run in minutes
+const maxJitter = 12 * 60
+
+const (
+	// LastDayOfMonth is the bit of the last day of the month in Schedule.DaysOfMonth
+	LastDayOfMonth uint32 = 1 << 31
+	// LastWeekOfMonth is the bit of the last week of the month in Schedule.WeeksOfMonth
+	LastWeekOfMonth uint8 = 1 << 4
+)
+
+// Schedule defines when a job runs. DayOfWeek and Weekdays are only used by weekly and monthly, DayOfMonth
+// and DaysOfMonth only by monthly and Expr only by custom schedules, which hold their times of day in the expression.
+type Schedule struct {
+	Type       TriggerType  `json:"type"`
+	DayOfWeek  time.Weekday `json:"dayOfWeek"`
+	DayOfMonth uint8        `json:"dayOfMonth"` // 1-31, months without the day are skipped
+	// Weekdays holds several weekdays, bit 0 is sunday. DayOfWeek is used when it is empty.
+	Weekdays uint8 `json:"weekdays,omitempty"`
+	// DaysOfMonth holds several days of the month, bit 0 is the 1st and LastDayOfMonth the last day. DayOfMonth is used when it is empty.
+	DaysOfMonth uint32 `json:"daysOfMonth,omitempty"`
+	// WeeksOfMonth runs a monthly schedule on its weekdays in the given weeks instead of on days of the month,
+	// bit 0 is the first week and LastWeekOfMonth the last one, e.g. the first monday of the month
+	WeeksOfMonth uint8 `json:"weeksOfMonth,omitempty"`
+	Hour         uint8 `json:"hour"`
+	Minute       uint8 `json:"minute,omitempty"`
+	// RepeatEvery repeats the run every given number of minutes up to RepeatUntil, in minutes after midnight
+	RepeatEvery uint16 `json:"repeatEvery,omitempty"`
+	RepeatUntil uint16 `json:"repeatUntil,omitempty"`
+	// Jitter delays every run by up to the given number of minutes, so jobs starting at the same time do not collide
+	Jitter uint16 `json:"jitter,omitempty"`
+	Expr   string `json:"expr,omitempty"` // a cron expression, see ParseCronExpr
+	// TimeZone is the IANA name of the time zone the schedule runs in, such as Europe/Berlin, the local one when empty
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+func (s Schedule) Validate() error {
+	if int(s.Type) >= len(triggerTypeNames) {
+		return fmt.Errorf("Validate: %w", errors.New("invalid trigger type"))
+	}
+	if s.Hour > 23 {
+		return fmt.Errorf("Validate: %w", errors.New("invalid hour"))
+	}
+	if s.Minute > 59 {
+		return fmt.Errorf("Validate: %w", errors.New("invalid minute"))
+	}
+	if s.RepeatEvery > 0 && (s.Type == Custom || int(s.RepeatUntil) < s.start() || s.RepeatUntil >= 24*60) {
+		return fmt.Errorf("Validate: %w", errors.New("invalid repetition"))
+	}
+	if s.Jitter > maxJitter {
+		return fmt.Errorf("Validate: %w", errors.New("invalid random delay"))
+	}
+	if s.DayOfWeek < time.Sunday || s.DayOfWeek > time.Saturday || s.DayOfMonth > 31 || s.Weekdays >= 1<<7 || s.WeeksOfMonth >= 1<<5 {
+		return fmt.Errorf("Validate: %w", errors.New("invalid day of month/week"))
+	}
+	if s.Type == Monthly && s.WeeksOfMonth == 0 && s.DaysOfMonth == 0 && s.DayOfMonth < 1 {
+		return fmt.Errorf("Validate: %w", errors.New("invalid day of month/week"))
+	}
+	if s.Type == Monthly && s.WeeksOfMonth != 0 && s.DaysOfMonth != 0 {
+		return fmt.Errorf("Validate: %w", errors.New("a monthly schedule runs either on days or on weekdays of the month"))
+	}
+	if _, err := s.Location(); err != nil {
+		return fmt.Errorf("Validate: %w", err)
+	}
+	if s.Type == Custom {
+		e, err := ParseCronExpr(s.Expr)
+		if err != nil {
+			return fmt.Errorf("Validate: %w", err)
+		}
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("Validate: %w", err)
+		}
+	}
+	return nil
+}
+
+// Location returns the time zone the schedule runs in
+func (s Schedule) Location() (*time.Location, error) {
+	if s.TimeZone == "" {
+		return time.Local, nil
+	}
+	// LoadLocation returns UTC for an empty name
+	loc, err := time.LoadLocation(s.TimeZone)
 	if err != nil {
-		return taskmaster.RegisteredTaskCollection{}, &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+		return nil, errors.New("invalid time zone")
+	}
+	return loc, nil
+}
+
+// localOnly reports a schedule in another time zone, which schedulers running on the local time cannot run
+func localOnly(s Schedule, scheduler string) error {
+	if s.TimeZone == "" {
+		return nil
 	}
-	defer conn.Disconnect()
+	return &ErrUnsupportedSchedule{Inner: fmt.Errorf("%v runs on the local time, not in %v", scheduler, s.TimeZone), Message: "use the daemon scheduler for this schedule"}
+}
 
-	tFolder, err := conn.GetTaskFolder(fPath)
-	// We only want to ignore this error when initially launching the app, when the folder does not exist yet
-	// Stopgap measure, TODO: extend taskmaster with better error handling, or implement taskmaster.taskFolderExist
-	if err != nil && !strings.Contains(err.Error(), "error getting folder") {
-		return taskmaster.RegisteredTaskCollection{}, &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find task folder"}
+// SupportsTimeZones reports whether the scheduler can run a schedule in another time zone, the task scheduler, cron
+// and launchd only know the local time
+func SupportsTimeZones(s Scheduler) bool {
+	switch s.(type) {
+	case *DaemonScheduler, *SystemdScheduler, *MemoryScheduler:
+		return true
 	}
+	return false
+}
 
-	return tFolder.RegisteredTasks, nil
+// start returns the time of day of the first run in minutes after midnight
+func (s Schedule) start() int {
+	return int(s.Hour)*60 + int(s.Minute)
 }
 
-func CreateScheduledTask(tType TriggerType, dMonth, dWeek, dHour, backupLimit uint8, src, dest string, overwrite bool) (taskmaster.RegisteredTask, error) {
-	conn, err := taskmaster.Connect()
-	if err != nil {
-		return taskmaster.RegisteredTask{}, err
+// weekdaySet returns the weekdays of a weekly schedule or the weeks of a monthly one, bit 0 is sunday
+func (s Schedule) weekdaySet() uint8 {
+	if s.Weekdays != 0 {
+		return s.Weekdays
 	}
-	defer conn.Disconnect()
+	return 1 << s.DayOfWeek
+}
 
-	def := conn.NewTaskDefinition()
+// daySet returns the days of a monthly schedule, bit 0 is the 1st
+func (s Schedule) daySet() uint32 {
+	if s.DaysOfMonth != 0 {
+		return s.DaysOfMonth
+	}
+	return 1 << (s.DayOfMonth - 1)
+}
 
-	trigger, err := createTrigger(tType, dMonth, dWeek, dHour)
-	if err != nil {
-		return taskmaster.RegisteredTask{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to create trigger"}
+// weekday returns the first weekday of the schedule
+func (s Schedule) weekday() time.Weekday {
+	return time.Weekday(bits.TrailingZeros8(s.weekdaySet()))
+}
+
+// dayOfMonth returns the first day of the month of the schedule
+func (s Schedule) dayOfMonth() int {
+	return bits.TrailingZeros32(s.daySet()) + 1
+}
+
+// basic reports whether the schedule runs once a day on every day, a single weekday or a single day of the month
+func (s Schedule) basic() bool {
+	switch s.Type {
+	case Weekly:
+		if bits.OnesCount8(s.weekdaySet()) != 1 {
+			return false
+		}
+	case Monthly:
+		if s.WeeksOfMonth != 0 || s.daySet()&LastDayOfMonth != 0 || bits.OnesCount32(s.daySet()) != 1 {
+			return false
+		}
+	case Custom:
+		return false
+	}
+	return !s.Repeats()
+}
+
+// cronDays returns the day of the month, month and day of the week fields of the cron expressions of a schedule
+func (s Schedule) cronDays() string {
+	switch s.Type {
+	case Weekly:
+		return "* * " + cronList(uint64(s.weekdaySet()), 0, 6)
+	case Monthly:
+		if s.WeeksOfMonth != 0 {
+			var weekdays []string
+			for d := 0; d < 7; d++ {
+				if s.weekdaySet()&(1<<d) == 0 {
+					continue
+				}
+				for n := 0; n < 4; n++ {
+					if s.WeeksOfMonth&(1<<n) != 0 {
+						weekdays = append(weekdays, fmt.Sprintf("%d#%d", d, n+1))
+					}
+				}
+				if s.WeeksOfMonth&LastWeekOfMonth != 0 {
+					weekdays = append(weekdays, fmt.Sprintf("%dL", d))
+				}
+			}
+			return "* * " + strings.Join(weekdays, ",")
+		}
+		var days []string
+		if set := uint64(s.daySet()&^LastDayOfMonth) << 1; set != 0 {
+			days = append(days, cronList(set, 1, 31))
+		}
+		if s.daySet()&LastDayOfMonth != 0 {
+			days = append(days, "L")
+		}
+		return strings.Join(days, ",") + " * *"
+	}
+	return "* * *"
+}
+
+// Repeats reports whether the schedule runs more than once on the days it runs
+func (s Schedule) Repeats() bool {
+	return s.RepeatEvery > 0 && int(s.RepeatUntil) >= s.start()+int(s.RepeatEvery)
+}
+
+// times returns the times of day of the runs in minutes after midnight
+func (s Schedule) times() []int {
+	times := []int{s.start()}
+	if s.RepeatEvery > 0 {
+		for t := s.start() + int(s.RepeatEvery); t <= int(s.RepeatUntil); t += int(s.RepeatEvery) {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// CronExprs returns the cron expressions the schedule runs on. Times of day that do not fit into one
+// expression, such as every 45 minutes, get an expression for every minute past the hour they run at.
+func (s Schedule) CronExprs() ([]CronExpr, error) {
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("CronExprs: %w", err)
+	}
+	if s.Type == Custom {
+		e, err := ParseCronExpr(s.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("CronExprs: %w", err)
+		}
+		return []CronExpr{e}, nil
+	}
+	days := s.cronDays()
+	var hours [60]uint64
+	for _, t := range s.times() {
+		hours[t%60] |= 1 << (t / 60)
+	}
+	var exprs []CronExpr
+	for minute, set := range hours {
+		if set == 0 {
+			continue
+		}
+		e, err := ParseCronExpr(fmt.Sprintf("%d %v %v", minute, cronList(set, 0, 23), days))
+		if err != nil {
+			return nil, fmt.Errorf("CronExprs: %w", err)
+		}
+		exprs = append(exprs, e)
+	}
+	return exprs, nil
+}
+
+// cronList returns the values of a set as a cron field, runs of consecutive values become ranges
+func cronList(set uint64, min, max int) string {
+	var items []string
+	for v := min; v <= max; v++ {
+		if set&(1<<v) == 0 {
+			continue
+		}
+		last := v
+		for last < max && set&(1<<(last+1)) != 0 {
+			last++
+		}
+		if last > v {
+			items = append(items, fmt.Sprintf("%d-%d", v, last))
+		} else {
+			items = append(items, strconv.Itoa(v))
+		}
+		v = last
 	}
-	def.AddTrigger(trigger)
+	return strings.Join(items, ",")
+}
 
-	action, err := createAction(src, dest, backupLimit, overwrite)
+// cronExprsString joins cron expressions with a semicolon
+func cronExprsString(exprs []CronExpr) string {
+	var fields []string
+	for _, e := range exprs {
+		fields = append(fields, e.String())
+	}
+	return strings.Join(fields, "; ")
+}
+
+// NextRuns returns up to n runs of the schedule after the given time, none if the schedule is invalid
+func (s Schedule) NextRuns(after time.Time, n int) []time.Time {
+	var runs []time.Time
+	for t := nextRunTime(s, after); !t.IsZero() && len(runs) < n; t = nextRunTime(s, t) {
+		runs = append(runs, t)
+	}
+	return runs
+}
+
+// sameSchedule reports whether two schedules run at the same times, e.g. a daily schedule and its cron expression
+func sameSchedule(a, b Schedule) bool {
+	exprsA, errA := a.CronExprs()
+	exprsB, errB := b.CronExprs()
+	return errA == nil && errB == nil && cronExprsString(exprsA) == cronExprsString(exprsB) && a.TimeZone == b.TimeZone
+}
+
+// Task is a job as registered with a scheduler, together with the state the scheduler keeps for it
+type Task struct {
+	Job         Job
+	Name        string
+	Enabled     bool
+	NextRunTime time.Time
+	LastRunTime time.Time
+	MissedRuns  uint
+	LastResult  string
+	// Expires is when the scheduler stops running the job unless it is renewed, zero if the schedule never expires
+	Expires time.Time
+	// Err is set when the job of a registered task could not be read, only Name and Delete can be relied on then
+	Err error
+}
+
+// Paused reports whether the job is paused at the given time
+func (t Task) Paused(now time.Time) bool {
+	return t.Job.PausedUntil.After(now)
+}
+
+// RenewWithin is how long before it expires a task is renewed
+const RenewWithin = 30 * 24 * time.Hour
+
+// Expiring reports whether the scheduler stops running the job within RenewWithin of the given time
+func (t Task) Expiring(now time.Time) bool {
+	return !t.Expires.IsZero() && t.Expires.Sub(now) < RenewWithin
+}
+
+// Renew registers the job of an expiring task again, which starts its schedule over
+func Renew(s Scheduler, task Task, now time.Time) (Task, error) {
+	if task.Err != nil || !task.Expiring(now) {
+		return task, nil
+	}
+	return s.Update(task.Job)
+}
+
+// RenewAll renews every expiring task, so backups keep running as long as the app or any backup runs now and then.
+// Unreadable tasks are skipped, the first error is returned once all other tasks are renewed.
+func RenewAll(s Scheduler, now time.Time) error {
+	tasks, err := s.List()
 	if err != nil {
-		return taskmaster.RegisteredTask{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to create action"}
-	}
-	def.AddAction(action)
-
-	def.Principal.RunLevel = taskmaster.TASK_RUNLEVEL_HIGHEST
-	// S4U is a necessary workaround to suppress powershell from flashing up when executing
-	def.Principal.LogonType = taskmaster.TASK_LOGON_S4U
-	def.Settings.AllowDemandStart = true
-	def.Settings.AllowHardTerminate = false
-	def.Settings.DontStartOnBatteries = false
-	def.Settings.Enabled = true
-	// Src and Dest together make a backup task unique
-	def.Settings.MultipleInstances = taskmaster.TASK_INSTANCES_IGNORE_NEW
-	def.Settings.StopIfGoingOnBatteries = false
-	def.Settings.WakeToRun = false
-
-	// TODO: Create a type for this
-	limit := strconv.Itoa(int(backupLimit))
-	if overwrite {
-		limit = "-"
-	}
-	ov := "No"
-	if overwrite {
-		ov = "Yes"
-	}
-	def.RegistrationInfo.Documentation = src + `|` + dest + `|` + limit + `|` + ov
-
-	createdTask, _, err := conn.CreateTask(fPath+"\\"+parseTaskPath(src, dest), def, true)
+		return err
+	}
+	var first error
+	for _, task := range tasks {
+		if _, err := Renew(s, task, now); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// migrator is a scheduler that still finds tasks of older versions of the app, which it can rewrite
+type migrator interface {
+	migrate() error
+}
+
+// Migrate rewrites the tasks older versions of the app registered, for the schedulers that have them. It is a step of its
+// own at startup, List and Get only read the tasks and keep the ID of an old task the same until it is rewritten.
+func Migrate(s Scheduler) error {
+	if m, ok := s.(migrator); ok {
+		return m.migrate()
+	}
+	return nil
+}
+
+// Scheduler registers jobs with a backend that runs them on their schedule.
+// Jobs are addressed by their ID.
+type Scheduler interface {
+	List() ([]Task, error)
+	Get(id string) (Task, error)
+	Create(job Job) (Task, error)
+	// Update replaces the job with the same ID, the scheduler keeps its run state
+	Update(job Job) (Task, error)
+	Delete(id string) error
+	// Run starts the job now, independent of its schedule
+	Run(id string) error
+	Enable(id string) error
+	Disable(id string) error
+}
+
+// backends are the schedulers that can be chosen by name instead of the one of the platform
+var backends = map[string]func() Scheduler{
+	"daemon":  func() Scheduler { return &DaemonScheduler{} },
+	"cron":    func() Scheduler { return &CronScheduler{} },
+	"systemd": func() Scheduler { return &SystemdScheduler{} },
+	"launchd": func() Scheduler { return &LaunchdScheduler{} },
+}
+
+// Open returns the scheduler with the given name, an empty name returns the scheduler of the platform
+func Open(name string) (Scheduler, error) {
+	if len(name) == 0 {
+		return New()
+	}
+	backend, ok := backends[strings.ToLower(name)]
+	if !ok {
+		return nil, &ErrUnknownScheduler{Inner: fmt.Errorf("Open: %w", fmt.Errorf("unknown scheduler %q", name)), Message: fmt.Sprintf("%q is not one of daemon, cron, systemd and launchd", name)}
+	}
+	return backend(), nil
+}
+
+// Find returns the task with the given job ID, task name or, if it is unique, label
+func Find(s Scheduler, ref string) (Task, error) {
+	tasks, err := s.List()
 	if err != nil {
-		return taskmaster.RegisteredTask{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to create task"}
+		return Task{}, err
+	}
+	var byLabel []Task
+	for _, task := range tasks {
+		if task.Job.ID == ref || task.Name == ref {
+			return task, nil
+		}
+		if task.Err == nil && task.Job.Label == ref {
+			byLabel = append(byLabel, task)
+		}
+	}
+	if len(byLabel) == 1 {
+		return byLabel[0], nil
+	}
+	if len(byLabel) > 1 {
+		return Task{}, &ErrTaskNotFound{Inner: fmt.Errorf("%v jobs are labeled %q", len(byLabel), ref), Message: "label is not unique, use the job ID"}
 	}
-	return createdTask, nil
+	return Task{}, &ErrTaskNotFound{Inner: fmt.Errorf("no job %q", ref), Message: "failed to find job"}
 }
 
-func DeleteScheduledTask(tName string, deleteFolder bool) error {
-	conn, err := taskmaster.Connect()
+// FindDuplicates returns the tasks that already back up the src of the job to its dest on the same schedule
+func FindDuplicates(tasks []Task, job Job) []Task {
+	var duplicates []Task
+	for _, task := range tasks {
+		if task.Err != nil || task.Job.ID == job.ID || !task.Job.SameTarget(job) {
+			continue
+		}
+		if !sameSchedule(task.Job.Schedule, job.Schedule) {
+			continue
+		}
+		duplicates = append(duplicates, task)
+	}
+	return duplicates
+}
+
+// PauseAll pauses every job until the given time. Schedulers skip the runs of paused jobs and resume them on their own.
+func PauseAll(s Scheduler, until time.Time) error {
+	return updateAll(s, func(job *Job) bool {
+		job.PausedUntil = until
+		return true
+	})
+}
+
+// ResumeAll ends a pause before it runs out
+func ResumeAll(s Scheduler) error {
+	return updateAll(s, func(job *Job) bool {
+		if job.PausedUntil.IsZero() {
+			return false
+		}
+		job.PausedUntil = time.Time{}
+		return true
+	})
+}
+
+func updateAll(s Scheduler, update func(job *Job) bool) error {
+	tasks, err := s.List()
 	if err != nil {
-		return &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+		return err
 	}
-	defer conn.Disconnect()
+	for _, task := range tasks {
+		if task.Err != nil {
+			return &ErrParseTaskFailure{Inner: task.Err, Message: "failed to read task " + task.Name}
+		}
+		if !update(&task.Job) {
+			continue
+		}
+		if _, err := s.Update(task.Job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTaskPath builds the task name from the label and the ID of a job, the ID alone keeps the name unique
+func parseTaskPath(label, id string) string {
+	m := regexp.MustCompile(`[:<>\\/?*|"]`)
+	replaceWith := "_"
+	label = m.ReplaceAllString(label, replaceWith)
+	id = m.ReplaceAllString(id, replaceWith)
+	return label + ` ` + id
+}
 
-	err = conn.DeleteTask(fPath + "\\" + tName)
+func getValidTime(dHour, dMinute uint8) (time.Time, error) {
+	if dHour > 23 {
+		return time.Now(), fmt.Errorf("getValidTime: %w", errors.New("invalid hour"))
+	}
+	if dMinute > 59 {
+		return time.Now(), fmt.Errorf("getValidTime: %w", errors.New("invalid minute"))
+	}
+	return startBoundary(time.Now(), int(dHour), int(dMinute)), nil
+}
+
+// startBoundary returns the first time after the given one at which the clock shows the time of day, in its location.
+// Triggers keep the time of day of their start, so unlike a run it skips days on which daylight saving time skips the time.
+func startBoundary(after time.Time, hour, minute int) time.Time {
+	e, err := ParseCronExpr(fmt.Sprintf("%d %d * * *", minute, hour))
 	if err != nil {
-		return &ErrDeleteTaskFailure{Inner: err, Message: "Failed to delete task"}
+		return time.Time{}
+	}
+	t := e.Next(after)
+	for t.Hour() != hour || t.Minute() != minute {
+		t = e.Next(t)
 	}
+	return t
+}
 
-	if deleteFolder {
-		success, err := conn.DeleteFolder(fPath, false)
-		if err != nil || success != true {
-			return &ErrDeleteTaskFolderFailure{Inner: err, Message: "Failed to delete task folder"}
+// nextRunTime returns the first run of the schedule after the given time, or the zero time if it never runs.
+// The runs follow the clock of the time zone of the schedule, or of the given time if it has none,
+// and are returned in the location of the given time.
+func nextRunTime(s Schedule, after time.Time) time.Time {
+	exprs, err := s.CronExprs()
+	if err != nil {
+		return time.Time{}
+	}
+	loc := after.Location()
+	if s.TimeZone != "" {
+		if loc, err = s.Location(); err != nil {
+			return time.Time{}
 		}
 	}
-	return nil
+	var next time.Time
+	for _, e := range exprs {
+		if t := e.Next(after.In(loc)); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	if next.IsZero() {
+		return next
+	}
+	return next.In(after.Location())
+}
+
+// JitterDelay returns the random delay of the run of a job at the given time.
+// It is derived from the job ID and the time, so every run of a job gets the same delay wherever it is computed.
+func JitterDelay(job Job, t time.Time) time.Duration {
+	if job.Schedule.Jitter == 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v %v", job.ID, t.Truncate(time.Minute).Unix())
+	return time.Duration(h.Sum64()%(uint64(job.Schedule.Jitter)*60)) * time.Second
+}
+
+func folderName(src string) string {
+	r := regexp.MustCompile(`[^\\/]+$`)
+	return r.FindString(strings.TrimRight(src, `\/`))
 }
@@ -2,14 +2,14 @@ package scheduler
 
 import (
 	"errors"
+	"flag"
 	"fmt"
-	"os"
+	"reflect"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
-
-	"github.com/capnspacehook/taskmaster"
-	"github.com/rickb777/date/period"
 )
 
 func TestParseTaskPathValid(t *testing.T) {
@@ -82,14 +82,14 @@ func TestGetValidTime(t *testing.T) {
 		uint8(125),
 	}
 	for _, dHour := range testcases {
-		result, err := getValidTime(dHour)
+		result, err := getValidTime(dHour, 0)
 		if dHour > 23 && err != nil {
 			return
 		}
 		if dHour > 23 && err == nil {
 			t.Errorf(`getValidTime(dHour) = %v, invalid hour error not returned`, dHour)
 		}
-		if result.Hour() != int(dHour) {
+		if result.Hour() != int(dHour) || result.Minute() != 0 {
 			t.Errorf(`getValidTime(dHour) = %v, want Hour match for %v`, dHour, result.Hour())
 		}
 		if int(dHour) < tNow.Hour() && result.Day() == tNow.Day() {
@@ -99,27 +99,61 @@ func TestGetValidTime(t *testing.T) {
 			t.Errorf(`getValidTime(dHour) = %v, want Day match for %v`, tNow.Day(), result.Day())
 		}
 	}
+	if _, err := getValidTime(12, 60); err == nil {
+		t.Errorf(`getValidTime(12, 60), invalid minute error not returned`)
+	}
+	if result, _ := getValidTime(12, 30); result.Second() != 0 || result.Nanosecond() != 0 {
+		t.Errorf(`getValidTime(12, 30) = %v, want a whole minute`, result)
+	}
+}
+
+func TestStartBoundary(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf(`LoadLocation(Europe/Berlin) returned error %v`, err)
+	}
+	testcases := []struct {
+		after        time.Time
+		hour, minute int
+		want         time.Time
+	}{
+		{time.Date(2022, 4, 2, 17, 0, 0, 0, berlin), 18, 15, time.Date(2022, 4, 2, 18, 15, 0, 0, berlin)},
+		{time.Date(2022, 4, 2, 17, 0, 0, 0, berlin), 17, 0, time.Date(2022, 4, 3, 17, 0, 0, 0, berlin)},
+		// A day is 23 hours long when daylight saving time starts
+		{time.Date(2022, 3, 26, 9, 30, 0, 0, berlin), 9, 0, time.Date(2022, 3, 27, 9, 0, 0, 0, berlin)},
+		// The clock skips 02:30 on the 27th, a trigger starting at 03:00 would run at 03:00 every day
+		{time.Date(2022, 3, 26, 12, 0, 0, 0, berlin), 2, 30, time.Date(2022, 3, 28, 2, 30, 0, 0, berlin)},
+		{time.Date(2022, 10, 29, 12, 0, 0, 0, berlin), 2, 30, time.Date(2022, 10, 30, 0, 30, 0, 0, time.UTC)},
+	}
+	for _, tc := range testcases {
+		result := startBoundary(tc.after, tc.hour, tc.minute)
+		if !result.Equal(tc.want) || result.Hour() != tc.hour || result.Minute() != tc.minute {
+			t.Errorf(`startBoundary(%v, %v, %v) = %v, want %v`, tc.after, tc.hour, tc.minute, result, tc.want)
+		}
+	}
 }
 
 func FuzzGetValidTime(f *testing.F) {
 	tNow := time.Now()
 	testcases := []uint8{0, 1, 6, 12, 17, 23, 25}
 	for _, tc := range testcases {
-		f.Add(tc)
+		f.Add(tc, uint8(0))
+		f.Add(tc, uint8(45))
 	}
+	f.Add(uint8(12), uint8(60))
 
-	f.Fuzz(func(t *testing.T, dHour uint8) {
-		result, err := getValidTime(dHour)
+	f.Fuzz(func(t *testing.T, dHour, dMinute uint8) {
+		result, err := getValidTime(dHour, dMinute)
 
-		t.Logf("Input: dHour=%v \n result Hour=%v\n result Day=%v\n error=%v", dHour, result.Hour(), result.Day(), err)
-		if dHour > 23 && err != nil {
+		t.Logf("Input: dHour=%v dMinute=%v\n result Hour=%v\n result Day=%v\n error=%v", dHour, dMinute, result.Hour(), result.Day(), err)
+		if (dHour > 23 || dMinute > 59) && err != nil {
 			return
 		}
-		if dHour > 23 && err == nil {
-			t.Errorf(`getValidTime(dHour) = %v, invalid hour error not returned`, dHour)
+		if (dHour > 23 || dMinute > 59) && err == nil {
+			t.Errorf(`getValidTime(dHour, dMinute) = %v, %v, invalid time error not returned`, dHour, dMinute)
 		}
-		if result.Hour() != int(dHour) {
-			t.Errorf(`getValidTime(dHour) = %v, want Hour match for %v`, dHour, result.Hour())
+		if result.Hour() != int(dHour) || result.Minute() != int(dMinute) {
+			t.Errorf(`getValidTime(dHour, dMinute) = %v, %v, want Hour and Minute match for %v`, dHour, dMinute, result)
 		}
 		if int(dHour) < tNow.Hour() && result.Day() == tNow.Day() {
 			t.Errorf(`getValidTime(dHour) = %v, want Day match for %v`, tNow.Day(), result.Day())
@@ -130,92 +164,346 @@ func FuzzGetValidTime(f *testing.F) {
 	})
 }
 
-func TestCreateTrigger(t *testing.T) {
-	startDate, _ := getValidTime(12)
+func TestParseHistory(t *testing.T) {
+	history := "\xef\xbb\xbf" + `{"time":"2022-04-02T12:00:00.0000000+02:00","src":"C:\\test","dest":"Z:\\backupme","exitCode":0,"message":"ok"}` + "\r\n" +
+		"\r\n" +
+		`{"time":"2022-04-02T18:00:00.0000000+02:00","src":"C:\\te` + "\r\n" +
+		`{"time":"2022-04-03T12:00:00.0000000+02:00","src":"C:\\test","dest":"Z:\\backupme","exitCode":4,"message":null}` + "\r\n"
+
+	entries, err := parseHistory(strings.NewReader(history))
+	if err != nil {
+		t.Fatalf(`parseHistory(...) returned error %v`, err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf(`parseHistory(...) = %v entries, want 2`, len(entries))
+	}
+	if entries[0].Time.Day() != 3 || entries[0].Success() || entries[0].Message != "" {
+		t.Errorf(`parseHistory(...)[0] = %+v, want the failed run of the 3rd first`, entries[0])
+	}
+	if entries[1].Src != `C:\test` || entries[1].Dest != `Z:\backupme` || !entries[1].Success() {
+		t.Errorf(`parseHistory(...)[1] = %+v, want the successful run of the 2nd`, entries[1])
+	}
+
+	if entries, err := parseHistory(strings.NewReader("src|dest\n")); err != nil || len(entries) != 0 {
+		t.Errorf(`parseHistory(...) = %v, %v want the invalid line to be skipped`, entries, err)
+	}
+}
+
+func TestEncodeCommand(t *testing.T) {
+	// Reference values generated with [Convert]::ToBase64String([Text.Encoding]::Unicode.GetBytes($script))
 	testcases := []struct {
-		tType                TriggerType
-		dMonth, dWeek, dHour uint8
-		wantTrigger          taskmaster.Trigger
-		wantError            error
+		script, want string
+	}{
+		{`Write-Host 'Hi'`, "VwByAGkAdABlAC0ASABvAHMAdAAgACcASABpACcA"},
+		{"試験", "ZooTmg=="},
+	}
+	for _, tc := range testcases {
+		if result := encodeCommand(tc.script); result != tc.want {
+			t.Errorf(`encodeCommand(%v) = %v, want %v`, tc.script, result, tc.want)
+		}
+	}
+}
+
+func TestParseJob(t *testing.T) {
+	testcases := []struct {
+		doc        string
+		wantJob    Job
+		wantLegacy bool
+		wantError  bool
 	}{
 		{
-			TriggerType(0), 0, 0, 12, taskmaster.DailyTrigger{
-				TaskTrigger: taskmaster.TaskTrigger{
-					Enabled:       true,
-					StartBoundary: startDate,
-					RepetitionPattern: taskmaster.RepetitionPattern{
-						RepetitionDuration: period.NewYMD(0, 0, 365),
-						RepetitionInterval: period.NewHMS(24, 0, 0),
-					},
-				},
-				DayInterval: taskmaster.EveryDay,
-			}, nil,
-		},
-		{
-			TriggerType(1), 0, 4, 0, taskmaster.WeeklyTrigger{
-				TaskTrigger: taskmaster.TaskTrigger{
-					Enabled:       true,
-					StartBoundary: startDate,
-					RepetitionPattern: taskmaster.RepetitionPattern{
-						RepetitionDuration: period.NewYMD(0, 0, 365),
-					},
-				},
-				WeekInterval: taskmaster.EveryWeek,
-				DaysOfWeek:   taskmaster.Wednesday,
-			}, nil,
-		},
-		{
-			TriggerType(2), 6, 0, 0, taskmaster.MonthlyTrigger{
-				TaskTrigger: taskmaster.TaskTrigger{
-					Enabled:       true,
-					StartBoundary: startDate,
-					RepetitionPattern: taskmaster.RepetitionPattern{
-						RepetitionDuration: period.NewYMD(0, 0, 365),
-					},
-				},
-				DaysOfMonth:  taskmaster.Six,
-				MonthsOfYear: taskmaster.AllMonths,
-			}, nil,
-		},
-		{
-			TriggerType(2), 31, 0, 0, nil, fmt.Errorf("createTrigger: %w", errors.New("invalid day of month/week")),
-		},
-		{
-			TriggerType(2), 30, 7, 0, nil, fmt.Errorf("createTrigger: %w", errors.New("invalid day of month/week")),
-		},
-		{
-			TriggerType(1), 12, 4, 25, nil, fmt.Errorf("createTrigger: %w", fmt.Errorf("getValidTime: %w", errors.New("invalid hour"))),
+			`{"version":1,"id":"a1","label":"a|b","src":"C:\\a|b","dest":"Z:\\backupme","backupLimit":3,"overwrite":false}`,
+			Job{Version: 1, ID: "a1", Label: "a|b", Src: `C:\a|b`, Dest: `Z:\backupme`, BackupLimit: 3}, false, false,
 		},
+		{`{"version":1,"src":"C:\\test","dest":"Z:\\backupme","backupLimit":3,"overwrite":false}`, Job{Version: 1, Label: "test", Src: `C:\test`, Dest: `Z:\backupme`, BackupLimit: 3}, true, false},
+		{`C:\test|Z:\backupme|3|No`, Job{Version: 1, Label: "test", Src: `C:\test`, Dest: `Z:\backupme`, BackupLimit: 3}, true, false},
+		{`C:\test|Z:\backupme|-|Yes`, Job{Version: 1, Label: "test", Src: `C:\test`, Dest: `Z:\backupme`, Overwrite: true}, true, false},
+		{`{"version":2,"id":"a1","src":"C:\\test","dest":"Z:\\backupme"}`, Job{}, false, true},
+		{`{"version":1,"id":"a1","src":"C:\\test"}`, Job{}, false, true},
+		{`{"version":1,`, Job{}, false, true},
+		{`C:\test|Z:\backupme`, Job{}, true, true},
 	}
 	for _, tc := range testcases {
-		result, err := createTrigger(tc.tType, tc.dMonth, tc.dWeek, tc.dHour)
+		result, legacy, err := ParseJob(tc.doc)
+
+		if tc.wantError && err == nil {
+			t.Errorf(`ParseJob(%v) did not return an error`, tc.doc)
+		}
+		if tc.wantLegacy && len(result.ID) == 0 && err == nil {
+			t.Errorf(`ParseJob(%v) did not assign an ID`, tc.doc)
+		}
+		if len(tc.wantJob.ID) == 0 {
+			tc.wantJob.ID = result.ID
+		}
+		if !tc.wantError && (err != nil || !reflect.DeepEqual(result, tc.wantJob) || legacy != tc.wantLegacy) {
+			t.Errorf(`ParseJob(%v) = %+v, %v, %v want match for %+v, %v`, tc.doc, result, legacy, err, tc.wantJob, tc.wantLegacy)
+		}
+	}
+
+	// A migrated legacy job must read back the same
+	job, _, _ := ParseJob(`C:\test|Z:\backupme|5|No|2022-05-01T00:00:00Z`)
+	doc, err := job.encode()
+	if err != nil {
+		t.Fatalf(`job.encode() returned error %v`, err)
+	}
+	if migrated, legacy, err := ParseJob(doc); err != nil || legacy || !reflect.DeepEqual(migrated, job) {
+		t.Errorf(`ParseJob(job.encode()) = %+v, %v, %v want match for %+v`, migrated, legacy, err, job)
+	}
+}
+
+func TestNewJobID(t *testing.T) {
+	m := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id := newJobID()
+		if !m.MatchString(id) {
+			t.Errorf(`newJobID() = %v, want a version 4 UUID`, id)
+		}
+		if seen[id] {
+			t.Errorf(`newJobID() = %v, returned twice`, id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestLegacyJobID(t *testing.T) {
+	m := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	id := legacyJobID(`\GoBackup\C:-Users-Test-Documents`)
+	if !m.MatchString(id) {
+		t.Errorf(`legacyJobID(...) = %v, want a version 5 UUID`, id)
+	}
+	// The ID of an old task has to stay the same while its metadata is not rewritten
+	if again := legacyJobID(`\GoBackup\C:-Users-Test-Documents`); again != id {
+		t.Errorf(`legacyJobID(...) = %v, then %v want the same ID`, id, again)
+	}
+	if other := legacyJobID(`\GoBackup\C:-Users-Test-Pictures`); other == id {
+		t.Errorf(`legacyJobID(...) = %v for two tasks, want different IDs`, id)
+	}
+}
 
-		if result != tc.wantTrigger || err != tc.wantError {
-			t.Errorf(`createTrigger(tc.tType, tc.dMonth, tc.dWeek, tc.dHour) = %v, %v, %v, %v want match for value: %v, error: %v`, tc.tType, tc.dMonth, tc.dWeek, tc.dHour, tc.wantTrigger, tc.wantError)
+func TestFindDuplicates(t *testing.T) {
+	newTask := func(name, id string, schedule Schedule) Task {
+		job := NewJob(0, `C:\a_b`, `Z:\backupme`, false)
+		job.ID, job.Schedule = id, schedule
+		return Task{Job: job, Name: name}
+	}
+	tasks := []Task{
+		newTask("daily", "a1", Schedule{Type: Daily, Hour: 17}),
+		newTask("weekly", "a2", Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 17}),
+		{Name: "broken", Err: errors.New("broken")},
+	}
+	testcases := []struct {
+		schedule  Schedule
+		src, dest string
+		want      []string
+	}{
+		{Schedule{Type: Daily, Hour: 17}, `C:\a_b`, `Z:\backupme`, []string{"daily"}},
+		{Schedule{Type: Daily, Hour: 17}, `c:\A_B`, `z:\backupme`, []string{"daily"}},
+		{Schedule{Type: Daily, Hour: 17}, `C:\a\b`, `Z:\backupme`, nil},
+		{Schedule{Type: Daily, Hour: 18}, `C:\a_b`, `Z:\backupme`, nil},
+		{Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 17}, `C:\a_b`, `Z:\backupme`, []string{"weekly"}},
+		{Schedule{Type: Weekly, DayOfWeek: time.Tuesday, Hour: 17}, `C:\a_b`, `Z:\backupme`, nil},
+		{Schedule{Type: Monthly, DayOfMonth: 1, Hour: 17}, `C:\a_b`, `Z:\backupme`, nil},
+	}
+	for _, tc := range testcases {
+		job := NewJob(0, tc.src, tc.dest, false)
+		job.Schedule = tc.schedule
+		var result []string
+		for _, task := range FindDuplicates(tasks, job) {
+			result = append(result, task.Name)
 		}
+		if fmt.Sprint(result) != fmt.Sprint(tc.want) {
+			t.Errorf(`FindDuplicates(tasks, %+v) = %v, want %v`, job, result, tc.want)
+		}
+	}
+
+	// A job is not a duplicate of itself when it is edited
+	if result := FindDuplicates(tasks, tasks[0].Job); len(result) != 0 {
+		t.Errorf(`FindDuplicates(tasks, tasks[0].Job) = %v, want none`, result)
+	}
+}
+
+// expiringScheduler expires its tasks at given times like the task scheduler, an update renews them
+type expiringScheduler struct {
+	*MemoryScheduler
+	expires map[string]time.Time
+	renewed map[string]bool
+}
+
+func (s *expiringScheduler) List() ([]Task, error) {
+	tasks, err := s.MemoryScheduler.List()
+	for i := range tasks {
+		tasks[i].Expires = s.expires[tasks[i].Job.ID]
 	}
+	return tasks, err
+}
+
+func (s *expiringScheduler) Update(job Job) (Task, error) {
+	s.renewed[job.ID] = true
+	s.expires[job.ID] = s.expires[job.ID].AddDate(1, 0, 0)
+	return s.MemoryScheduler.Update(job)
 }
 
-func TestCreateAction(t *testing.T) {
+func TestRenewAll(t *testing.T) {
+	now := time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC)
+	s := &expiringScheduler{MemoryScheduler: NewMemoryScheduler(), expires: map[string]time.Time{}, renewed: map[string]bool{}}
 	testcases := []struct {
-		src, dest   string
-		backupLimit uint8
-		overwrite   bool
-		wantAction  taskmaster.ExecAction
-		wantError   error
+		expires     time.Time
+		wantRenewed bool
 	}{
-		{`C:\test`, `Z:\backupme`, 0, false, taskmaster.ExecAction{}, fmt.Errorf("createAction: failed to retrieve systemdrive: %w", errors.New("SYSTEMDRIVE not found"))},
+		{time.Time{}, false},
+		{now.AddDate(0, 3, 0), false},
+		{now.AddDate(0, 0, 29), true},
+		{now.AddDate(0, 0, -1), true},
+	}
+	for i, tc := range testcases {
+		job := NewJob(3, fmt.Sprintf(`C:\test%v`, i), `Z:\backupme`, false)
+		job.ID = strconv.Itoa(i)
+		if _, err := s.Create(job); err != nil {
+			t.Fatalf(`Create(%+v) returned error %v`, job, err)
+		}
+		s.expires[job.ID] = tc.expires
+	}
+	if err := RenewAll(s, now); err != nil {
+		t.Fatalf(`RenewAll(s, %v) returned error %v`, now, err)
 	}
+	for i, tc := range testcases {
+		if s.renewed[strconv.Itoa(i)] != tc.wantRenewed {
+			t.Errorf(`RenewAll(s, %v) renewed %v, want the job expiring %v renewed: %v`, now, s.renewed, tc.expires, tc.wantRenewed)
+		}
+	}
+	tasks, _ := s.List()
+	for _, task := range tasks {
+		if task.Expiring(now) {
+			t.Errorf(`RenewAll(s, %v) left %+v expiring`, now, task)
+		}
+	}
+}
 
-	oldSysDrive := os.Getenv("SYSTEMDRIVE")
-	os.Setenv("SYSTEMDRIVE", "")
+func TestNextRunTime(t *testing.T) {
+	after := time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC) // a Saturday
+	testcases := []struct {
+		schedule Schedule
+		want     time.Time
+	}{
+		{Schedule{Type: Daily, Hour: 18}, time.Date(2022, 4, 2, 18, 0, 0, 0, time.UTC)},
+		{Schedule{Type: Daily, Hour: 17}, time.Date(2022, 4, 3, 17, 0, 0, 0, time.UTC)},
+		{Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 9}, time.Date(2022, 4, 4, 9, 0, 0, 0, time.UTC)},
+		{Schedule{Type: Monthly, DayOfMonth: 2, Hour: 9}, time.Date(2022, 5, 2, 9, 0, 0, 0, time.UTC)},
+		{Schedule{Type: Monthly, DayOfMonth: 31, Hour: 9}, time.Date(2022, 5, 31, 9, 0, 0, 0, time.UTC)},
+	}
 	for _, tc := range testcases {
-		result, err := createAction(tc.src, tc.dest, tc.backupLimit, tc.overwrite)
+		if result := nextRunTime(tc.schedule, after); !result.Equal(tc.want) {
+			t.Errorf(`nextRunTime(%+v, %v) = %v, want %v`, tc.schedule, after, result, tc.want)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if s, err := Open("Daemon"); err != nil || reflect.TypeOf(s) != reflect.TypeOf(&DaemonScheduler{}) {
+		t.Errorf(`Open("Daemon") = %v, %v want the daemon scheduler`, s, err)
+	}
+	var unknownErr *ErrUnknownScheduler
+	if s, err := Open("at"); !errors.As(err, &unknownErr) || len(unknownErr.Message) == 0 {
+		t.Errorf(`Open("at") = %v, %v want ErrUnknownScheduler`, s, err)
+	}
+}
+
+// splitCommand splits a generated command line into its arguments, quotes group an argument and are removed
+func splitCommand(line string) []string {
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	inArg := false
+	for _, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+			inArg = false
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// TestRunCommands parses the commands the backends start a job with as the run command does, a scheduled run has to
+// find the job in the scheduler that created it whatever scheduler its environment chooses
+func TestRunCommands(t *testing.T) {
+	command := "/opt/Go Backup/gobackup"
+	job := NewJob(3, "/home/user/Documents", "/mnt/backup", false)
+	job.Schedule = Schedule{Type: Daily, Hour: 9, Jitter: 5}
+
+	entries, err := (&CronScheduler{Command: command}).cronEntries(job, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := (&SystemdScheduler{Command: command}).serviceUnit(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var execStart string
+	for _, line := range strings.Split(service, "\n") {
+		if strings.HasPrefix(line, "ExecStart=") {
+			execStart = strings.TrimPrefix(line, "ExecStart=")
+		}
+	}
+	plist, err := (&LaunchdScheduler{Command: command}).agentPlist(job, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parsePlist(strings.NewReader(plist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var programArgs []string
+	for _, arg := range parsed.(map[string]interface{})["ProgramArguments"].([]interface{}) {
+		programArgs = append(programArgs, arg.(string))
+	}
+	action, err := BackupTaskAction(command, job)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		if result != tc.wantAction && err != tc.wantError {
-			os.Setenv("SYSTEMDRIVE", oldSysDrive)
-			t.Errorf(`createAction(...) = %v, want match for %v; err: %v, want match for %v`, result, tc.wantAction, err, tc.wantError)
+	testcases := []struct {
+		scheduler string
+		args      []string
+	}{
+		{"cron", splitCommand(entries[0])[5:]},
+		{"systemd", splitCommand(execStart)},
+		{"launchd", programArgs},
+		{"taskscheduler", append([]string{action.Command}, splitCommand(action.Arguments)...)},
+	}
+	for _, tc := range testcases {
+		if len(tc.args) < 2 || tc.args[0] != command || tc.args[1] != "run" {
+			t.Errorf(`%v command = %q want %v run`, tc.scheduler, tc.args, command)
+			continue
+		}
+		fs := flag.NewFlagSet("run", flag.ContinueOnError)
+		name := fs.String("scheduler", "", "")
+		id := fs.String("job", "", "")
+		fs.Bool("scheduled", false, "")
+		fs.Bool("jitter", false, "")
+		if err := fs.Parse(tc.args[2:]); err != nil || fs.NArg() != 0 || *name != tc.scheduler || *id != job.ID {
+			t.Errorf(`%v command = %q want run -scheduler %v -job %v, parse error %v`, tc.scheduler, tc.args, tc.scheduler, job.ID, err)
+		}
+	}
+	for _, name := range []string{"cron", "systemd", "launchd"} {
+		if _, err := Open(name); err != nil {
+			t.Errorf(`Open(%q) returned error %v for the scheduler of a generated command`, name, err)
 		}
 	}
-	os.Setenv("SYSTEMDRIVE", oldSysDrive)
 }
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// shScript backs up a folder the same way as RunJob, every value is written through sh, so no path can end its literal.
+// Without a log path the script logs to backup.log in the config folder of the user it runs as.
+var shScript = template.Must(template.New("shscript").Funcs(template.FuncMap{"sh": shellQuote}).Parse(`#!/bin/sh
+src={{sh .Src}}
+dest={{sh .Dest}}
+folder={{sh .Folder}}
+app_title={{sh .AppTitle}}
+log_path={{if .LogPath}}{{sh .LogPath}}{{else}}"${XDG_CONFIG_HOME:-$HOME/.config}/$app_title/backup.log"{{end}}
+backup_limit={{.BackupLimit}}
+overwrite={{.Overwrite}}
+
+dest_path="$dest/$folder"
+content_success="Your folder $src has been backed up to $dest. "
+content_failure="Your folder $src has not been backed up to $dest. "
+
+report() {
+	if command -v notify-send >/dev/null 2>&1 && notify-send --app-name="$app_title" "$1" "$2"; then
+		return
+	fi
+	mkdir -p "$(dirname "$log_path")" && printf '%s %s: %s\n' "$(date '+%Y-%m-%dT%H:%M:%S%z')" "$1" "$2" >>"$log_path"
+}
+
+succeed() {
+	report 'Your backup was successful' "$content_success$1"
+	exit 0
+}
+
+fail() {
+	report 'Your backup has failed' "$content_failure$2"
+	exit "$1"
+}
+
+if [ ! -d "$src" ]; then
+	fail 4 'The folder does not exist anymore.'
+fi
+if [ -z "$(cd -- "$src" && find . ! -type d | head -n 1)" ]; then
+	fail 1 'No files were found to copy.'
+fi
+if ! mkdir -p -- "$dest_path" || ! cp -R -p -- "$src/." "$dest_path"; then
+	fail 5 'A disk write error occurred.'
+fi
+if $overwrite; then
+	succeed 'There were no errors.'
+fi
+if ! mv -- "$dest_path" "$dest_path-$(date '+%Y%m%d_%H%M%S')"; then
+	succeed 'However, the backup folder could not be renamed.'
+fi
+
+removed=0
+if [ "$backup_limit" -gt 0 ]; then
+	snapshots=0
+	for snapshot in "$dest_path"-20[0-9][0-9][0-9][0-9][0-9][0-9]_[0-9][0-9][0-9][0-9][0-9][0-9]; do
+		if [ -d "$snapshot" ]; then
+			snapshots=$((snapshots + 1))
+		fi
+	done
+	# The glob sorts the snapshots oldest first
+	for snapshot in "$dest_path"-20[0-9][0-9][0-9][0-9][0-9][0-9]_[0-9][0-9][0-9][0-9][0-9][0-9]; do
+		if [ $((snapshots - removed)) -le "$backup_limit" ]; then
+			break
+		fi
+		if [ ! -d "$snapshot" ]; then
+			continue
+		fi
+		if ! rm -rf -- "$snapshot"; then
+			succeed "However, $removed out of $((snapshots - backup_limit)) old backups have been removed."
+		fi
+		removed=$((removed + 1))
+	done
+fi
+succeed "$removed old backup(s) have been removed. There were no errors."
+`))
+
+// shScriptData holds the values of shScript
+type shScriptData struct {
+	Src, Dest, Folder, AppTitle, LogPath string
+	BackupLimit                          uint8
+	Overwrite                            bool
+}
+
+// ShellScript returns a standalone sh script that backs up a job once, for machines that cannot run GoBackup itself.
+// It reports through notify-send where it is installed and logs to the config folder otherwise.
+// Placeholders in the paths are expanded for the current user when the script is written. A job with a rotation cannot
+// be written, as the script would only ever back up to one of its drives.
+func ShellScript(job Job) (string, error) {
+	if len(job.Rotation) > 0 {
+		return "", fmt.Errorf("ShellScript: %w", errors.New("a backup with a rotation of drives cannot be written as a script"))
+	}
+	job, err := expandJob(job)
+	if err != nil {
+		return "", fmt.Errorf("ShellScript: %w", err)
+	}
+	return createShScript(job.Src, job.Dest, folderName(job.Src), appTitle, "", job.BackupLimit, job.Overwrite)
+}
+
+func createShScript(src, dest, folder, appTitle, logPath string, backupLimit uint8, overwrite bool) (string, error) {
+	if backupLimit > 10 {
+		backupLimit = 0
+	}
+	var b strings.Builder
+	err := shScript.Execute(&b, shScriptData{
+		Src:         src,
+		Dest:        dest,
+		Folder:      folder,
+		AppTitle:    appTitle,
+		LogPath:     logPath,
+		BackupLimit: backupLimit,
+		Overwrite:   overwrite,
+	})
+	return b.String(), err
+}
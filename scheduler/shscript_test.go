@@ -0,0 +1,249 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// shLiterals reads the quoted words of a sh script as sh does and returns the script with an empty literal in place
+// of every quoted word, together with the values of the words. A quoted word is a run of single-quoted strings and
+// backslash escapes, such as the words of shellQuote.
+func shLiterals(script string) (string, []string, error) {
+	var skeleton strings.Builder
+	var literals []string
+	for i := 0; i < len(script); {
+		if script[i] != '\'' && script[i] != '\\' {
+			skeleton.WriteByte(script[i])
+			i++
+			continue
+		}
+		var literal strings.Builder
+		for i < len(script) && (script[i] == '\'' || script[i] == '\\') {
+			if script[i] == '\\' {
+				if i+1 == len(script) {
+					return "", nil, errors.New("escape at the end of the script")
+				}
+				literal.WriteByte(script[i+1])
+				i += 2
+				continue
+			}
+			end := strings.IndexByte(script[i+1:], '\'')
+			if end < 0 {
+				return "", nil, errors.New("unterminated literal")
+			}
+			literal.WriteString(script[i+1 : i+1+end])
+			i += end + 2
+		}
+		skeleton.WriteString("''")
+		literals = append(literals, literal.String())
+	}
+	return skeleton.String(), literals, nil
+}
+
+func FuzzCreateShScript(f *testing.F) {
+	testcases := []struct {
+		src, dest, logPath string
+	}{
+		{`/home/user/Documents`, `/media/backup/Everything/試験/Test Spaces in path`, `/home/user/.config/GoBackup/backup.log`},
+		{`/home/user/O'Brien's files`, `/media/it's "quoted"`, `/tmp/log\`},
+		{`/tmp/'; rm -rf ~; '`, `/media/$(reboot)`, "/tmp/`reboot`\n%"},
+	}
+	for _, tc := range testcases {
+		f.Add(tc.src, tc.dest, tc.logPath)
+	}
+
+	marker := "\x01"
+	wantSkeleton, wantLiterals, err := shLiterals(mustCreateShScript(f, marker+"src", marker+"dest", marker+"folder", marker+"log"))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, src, dest, logPath string) {
+		// An empty log path is replaced by the default one, a path can never contain a null character
+		for _, s := range []string{src, dest, logPath} {
+			if len(s) == 0 || strings.ContainsRune(s, 0) {
+				return
+			}
+		}
+		folder := folderName(src)
+		values := map[string]string{marker + "src": src, marker + "dest": dest, marker + "folder": folder, marker + "log": logPath}
+
+		skeleton, literals, err := shLiterals(mustCreateShScript(t, src, dest, folder, logPath))
+		t.Logf("Input: src=%v\n dest=%v\n logPath=%v", src, dest, logPath)
+		if err != nil {
+			t.Fatalf(`createShScript(...) does not parse: %v`, err)
+		}
+		if skeleton != wantSkeleton || len(literals) != len(wantLiterals) {
+			t.Fatalf(`createShScript(...) changed the code outside of its literals`)
+		}
+		for i, literal := range wantLiterals {
+			want, ok := values[literal]
+			if !ok {
+				want = literal
+			}
+			if literals[i] != want {
+				t.Errorf(`createShScript(...) literal %v = %v, want %v`, i, literals[i], want)
+			}
+		}
+	})
+}
+
+func mustCreateShScript(t testing.TB, src, dest, folder, logPath string) string {
+	script, err := createShScript(src, dest, folder, appTitle, logPath, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestCreateShScript(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+	testcases := []struct {
+		name           string
+		folder         string
+		files          []string
+		snapshots      []string
+		backupLimit    uint8
+		overwrite      bool
+		notifySendOk   bool
+		wantExitCode   int
+		wantSnapshots  int
+		wantRemaining  []string
+		wantMessage    string
+		wantNotifySend bool
+	}{
+		{
+			name: "prune", folder: `O'Brien's $(touch pwned) files`, files: []string{"a.txt", "sub/b.txt"},
+			snapshots:   []string{"20200101_000000", "20210101_000000", "20220101_000000"},
+			backupLimit: 2, notifySendOk: true,
+			wantSnapshots: 2, wantRemaining: []string{"20220101_000000"},
+			wantMessage: "2 old backup(s) have been removed. There were no errors.", wantNotifySend: true,
+		},
+		{
+			name: "keep all", folder: "docs", files: []string{"a.txt"},
+			snapshots:     []string{"20200101_000000"},
+			wantSnapshots: 2, wantRemaining: []string{"20200101_000000"},
+			wantMessage: "0 old backup(s) have been removed. There were no errors.",
+		},
+		{
+			name: "overwrite", folder: "docs", files: []string{"a.txt"}, overwrite: true, notifySendOk: true,
+			wantMessage: "There were no errors.", wantNotifySend: true,
+		},
+		{
+			name: "no files", folder: "empty", wantExitCode: 1,
+			wantMessage: "No files were found to copy.",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "src", tc.folder)
+			dest := filepath.Join(dir, "dest")
+			logPath := filepath.Join(dir, "log", "backup.log")
+			for _, d := range []string{src, dest, filepath.Join(dir, "bin")} {
+				if err := os.MkdirAll(d, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range tc.files {
+				writeFile(t, filepath.Join(src, name), name)
+			}
+			for _, snapshot := range tc.snapshots {
+				writeFile(t, filepath.Join(dest, tc.folder+"-"+snapshot, "old.txt"), "old")
+			}
+			// A notify-send that records its arguments, or fails so the script falls back to the log
+			notifyLog := filepath.Join(dir, "notify-send.log")
+			notifyExit := "1"
+			if tc.notifySendOk {
+				notifyExit = "0"
+			}
+			writeFile(t, filepath.Join(dir, "bin", "notify-send"), "#!/bin/sh\nprintf '%s\\n' \"$@\" >>"+shellQuote(notifyLog)+"\nexit "+notifyExit+"\n")
+			if err := os.Chmod(filepath.Join(dir, "bin", "notify-send"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			script, err := createShScript(src, dest, tc.folder, appTitle, logPath, tc.backupLimit, tc.overwrite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command(sh, "-c", script)
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "PATH="+filepath.Join(dir, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+			out, err := cmd.CombinedOutput()
+			exitCode := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				exitCode = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if exitCode != tc.wantExitCode {
+				t.Errorf(`exit code = %v, want %v; output: %s`, exitCode, tc.wantExitCode, out)
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+				t.Errorf(`the folder name was run as a command`)
+			}
+			snapshots, err := listSnapshots(dest, tc.folder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(snapshots) != tc.wantSnapshots {
+				t.Errorf(`snapshots = %v, want %v`, snapshots, tc.wantSnapshots)
+			}
+			for _, snapshot := range tc.wantRemaining {
+				if _, err := os.Stat(filepath.Join(dest, tc.folder+"-"+snapshot)); err != nil {
+					t.Errorf(`snapshot %v was removed`, snapshot)
+				}
+			}
+			copied := filepath.Join(dest, tc.folder)
+			if !tc.overwrite && len(snapshots) > 0 {
+				copied = filepath.Join(dest, snapshots[len(snapshots)-1])
+			}
+			for _, name := range tc.files {
+				if b, err := os.ReadFile(filepath.Join(copied, name)); err != nil || string(b) != name {
+					t.Errorf(`%v was not copied to %v`, name, copied)
+				}
+			}
+
+			report := notifyLog
+			if !tc.wantNotifySend {
+				report = logPath
+			}
+			b, err := os.ReadFile(report)
+			if err != nil {
+				t.Fatalf(`nothing was reported to %v: %v`, report, err)
+			}
+			if !strings.Contains(string(b), tc.wantMessage) {
+				t.Errorf(`reported %q, want %q`, b, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShellScriptRotation(t *testing.T) {
+	job := NewJob(0, "/home/user/Documents", "/mnt/disk1/GoBackup", false)
+	if _, err := ShellScript(job); err != nil {
+		t.Errorf(`ShellScript(job) returned error %v`, err)
+	}
+	job.Rotation = []string{"/mnt/disk2/GoBackup"}
+	if script, err := ShellScript(job); err == nil {
+		t.Errorf(`ShellScript(job) = %v, want an error for a job with a rotation`, script)
+	}
+}
@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/capnspacehook/taskmaster"
+)
+
+// setEnabled enables or disables a task without touching its schedule
+func setEnabled(id string, enabled bool) error {
+	conn, err := taskmaster.Connect()
+	if err != nil {
+		return &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+	}
+	defer conn.Disconnect()
+
+	task, err := findTask(&conn, id)
+	if err != nil {
+		return err
+	}
+
+	def := task.Definition
+	def.Settings.Enabled = enabled
+	if _, err := conn.UpdateTask(task.Path, def); err != nil {
+		return &ErrUpdateTaskFailure{Inner: err, Message: "failed to update task"}
+	}
+	return nil
+}
+
+// pauseDefinition moves the start of the triggers past the given time.
+// The task scheduler resumes the task on its own once that time has passed.
+func pauseDefinition(def *taskmaster.Definition, until time.Time) error {
+	for i, tr := range def.Triggers {
+		start := tr.GetStartBoundary()
+		resume := time.Date(until.Year(), until.Month(), until.Day(), start.Hour(), start.Minute(), 0, 0, until.Location())
+		if resume.Before(until) {
+			resume = resume.AddDate(0, 0, 1)
+		}
+		def.Triggers[i] = withStartBoundary(tr, resume)
+	}
+	return setJobPause(def, until)
+}
+
+// setJobPause stores the end of a pause in the job metadata, a zero time removes it
+func setJobPause(def *taskmaster.Definition, until time.Time) error {
+	job, _, err := ParseJob(def.RegistrationInfo.Documentation)
+	if err != nil {
+		return fmt.Errorf("setJobPause: %w", err)
+	}
+	job.PausedUntil = until
+	doc, err := job.encode()
+	if err != nil {
+		return fmt.Errorf("setJobPause: %w", err)
+	}
+	def.RegistrationInfo.Documentation = doc
+	return nil
+}
+
+func withStartBoundary(tr taskmaster.Trigger, start time.Time) taskmaster.Trigger {
+	switch t := tr.(type) {
+	case taskmaster.DailyTrigger:
+		t.StartBoundary = start
+		return t
+	case taskmaster.WeeklyTrigger:
+		t.StartBoundary = start
+		return t
+	case taskmaster.MonthlyTrigger:
+		t.StartBoundary = start
+		return t
+	case taskmaster.MonthlyDOWTrigger:
+		t.StartBoundary = start
+		return t
+	}
+	return tr
+}
@@ -0,0 +1,590 @@
+package scheduler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	unitPrefix = "gobackup-"
+	// jobKey holds the job in the service unit, systemd ignores keys starting with X-
+	jobKey      = "X-GoBackup-Job"
+	timersWants = "timers.target.wants"
+)
+
+// SystemdScheduler registers every job as a user-level service and timer unit in UnitDir.
+// Enabling a job links its timer into timers.target.wants, as systemctl enable does.
+type SystemdScheduler struct {
+	// UnitDir is the directory of the units, the systemd user unit directory in the config dir when it is empty
+	UnitDir string
+	// Command is the GoBackup executable the service runs, the running executable when it is empty
+	Command string
+	// Systemctl runs systemctl --user with the given arguments after the units changed, nil runs the command
+	Systemctl func(args ...string) error
+	// Now returns the current time, time.Now is used when it is nil
+	Now func() time.Time
+}
+
+func (s *SystemdScheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *SystemdScheduler) unitDir() (string, error) {
+	if len(s.UnitDir) > 0 {
+		return s.UnitDir, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unitDir: %w", err)
+	}
+	return filepath.Join(configDir, "systemd", "user"), nil
+}
+
+func (s *SystemdScheduler) systemctl(args ...string) error {
+	if s.Systemctl != nil {
+		return s.Systemctl(args...)
+	}
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (s *SystemdScheduler) command() (string, error) {
+	if len(s.Command) > 0 {
+		return s.Command, nil
+	}
+	return os.Executable()
+}
+
+func unitName(id, suffix string) string {
+	return unitPrefix + id + suffix
+}
+
+// onCalendar returns the OnCalendar expression of a schedule
+func onCalendar(schedule Schedule) string {
+	t := fmt.Sprintf("%02d:%02d:00", schedule.Hour, schedule.Minute)
+	switch schedule.Type {
+	case Weekly:
+		return schedule.weekday().String()[:3] + " *-*-* " + t
+	case Monthly:
+		return fmt.Sprintf("*-*-%02d %v", schedule.dayOfMonth(), t)
+	}
+	return "*-*-* " + t
+}
+
+// parseOnCalendar reads an OnCalendar expression as written by onCalendar
+func parseOnCalendar(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	s := Schedule{Type: Daily}
+	// A time zone follows the time
+	if n := len(fields); n > 2 && !strings.Contains(fields[n-1], ":") {
+		s.TimeZone, fields = fields[n-1], fields[:n-1]
+	}
+	if len(fields) == 3 {
+		s.Type = Weekly
+		day, ok := parseWeekday(fields[0])
+		if !ok {
+			return Schedule{}, fmt.Errorf("parseOnCalendar: %w", fmt.Errorf("invalid weekday %q", fields[0]))
+		}
+		s.DayOfWeek = day
+		fields = fields[1:]
+	}
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], "*-*-") || !strings.HasSuffix(fields[1], ":00") {
+		return Schedule{}, fmt.Errorf("parseOnCalendar: %w", errors.New("unsupported calendar expression"))
+	}
+	if day := strings.TrimPrefix(fields[0], "*-*-"); day != "*" {
+		if s.Type == Weekly {
+			return Schedule{}, fmt.Errorf("parseOnCalendar: %w", errors.New("unsupported calendar expression"))
+		}
+		dayOfMonth, err := strconv.ParseUint(day, 10, 8)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("parseOnCalendar: %w", err)
+		}
+		s.Type, s.DayOfMonth = Monthly, uint8(dayOfMonth)
+	}
+	hour, minute, _ := strings.Cut(strings.TrimSuffix(fields[1], ":00"), ":")
+	h, err := strconv.ParseUint(hour, 10, 8)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("parseOnCalendar: %w", err)
+	}
+	m, err := strconv.ParseUint(minute, 10, 8)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("parseOnCalendar: %w", err)
+	}
+	s.Hour, s.Minute = uint8(h), uint8(m)
+	if err := s.Validate(); err != nil {
+		return Schedule{}, fmt.Errorf("parseOnCalendar: %w", err)
+	}
+	return s, nil
+}
+
+// calendarSpecs returns the OnCalendar expressions of a schedule, a timer runs on every one of them
+func calendarSpecs(schedule Schedule) ([]string, error) {
+	if err := schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("calendarSpecs: %w", err)
+	}
+	var specs []string
+	if schedule.basic() {
+		specs = []string{onCalendar(schedule)}
+	} else {
+		exprs, err := schedule.CronExprs()
+		if err != nil {
+			return nil, fmt.Errorf("calendarSpecs: %w", err)
+		}
+		for _, e := range exprs {
+			exprSpecs, err := cronCalendarSpecs(e)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, exprSpecs...)
+		}
+	}
+	// systemd runs an expression ending in a time zone on the clock of that zone
+	if schedule.TimeZone != "" {
+		for i := range specs {
+			specs[i] += " " + schedule.TimeZone
+		}
+	}
+	return specs, nil
+}
+
+// cronCalendarSpecs returns the OnCalendar expressions of a cron expression. The days of the month and
+// the weekdays, which cron combines with OR, get one each.
+func cronCalendarSpecs(e CronExpr) ([]string, error) {
+	t := calendarList(uint64(e.hours), 0, 23) + ":" + calendarList(e.minutes, 0, 59) + ":00"
+	months := calendarList(uint64(e.months), 1, 12)
+
+	var daySpecs []string
+	if e.days != 0 {
+		daySpecs = append(daySpecs, "*-"+months+"-"+calendarList(uint64(e.days), 1, 31))
+	}
+	if e.lastDay {
+		daySpecs = append(daySpecs, "*-"+months+"~01")
+	}
+	// Weekdays that run on the same occurrences share an expression
+	var weekdaySpecs []string
+	var groups []uint8
+	names := map[uint8][]string{}
+	for d, occurrences := range e.weeks {
+		if occurrences == 0 {
+			continue
+		}
+		if _, ok := names[occurrences]; !ok {
+			groups = append(groups, occurrences)
+		}
+		names[occurrences] = append(names[occurrences], time.Weekday(d).String()[:3])
+	}
+	for _, occurrences := range groups {
+		weekdays := strings.Join(names[occurrences], ",")
+		var ranges []string
+		for n := 0; n < 5; n++ {
+			if occurrences&(1<<n) == 0 {
+				continue
+			}
+			last := 7*n + 7
+			if last > 31 {
+				last = 31
+			}
+			ranges = append(ranges, fmt.Sprintf("%02d..%02d", 7*n+1, last))
+		}
+		if occurrences&allOccurrences == allOccurrences {
+			ranges = []string{"*"}
+		}
+		if len(ranges) > 0 {
+			weekdaySpecs = append(weekdaySpecs, weekdays+" *-"+months+"-"+strings.Join(ranges, ","))
+		}
+		if occurrences&lastOccurrence != 0 {
+			// The last seven days of the month hold the last occurrence of every weekday
+			weekdaySpecs = append(weekdaySpecs, weekdays+" *-"+months+"~07/1")
+		}
+	}
+
+	var specs []string
+	allDays := e.days == 1<<32-2 && !e.lastDay
+	allWeekdays := len(groups) == 1 && groups[0] == allOccurrences && len(names[allOccurrences]) == 7
+	switch {
+	case !e.domStar && !e.dowStar:
+		specs = append(daySpecs, weekdaySpecs...)
+	case allWeekdays:
+		specs = daySpecs
+	case allDays:
+		specs = weekdaySpecs
+	case len(groups) == 1 && groups[0] == allOccurrences && !e.lastDay:
+		// Both restricted and combined with AND, e.g. every other day but only on weekdays
+		specs = []string{strings.Join(names[allOccurrences], ",") + " *-" + months + "-" + calendarList(uint64(e.days), 1, 31)}
+	default:
+		return nil, &ErrUnsupportedSchedule{Inner: fmt.Errorf("systemd cannot combine the days in %q", e), Message: "use the daemon scheduler for this schedule"}
+	}
+	for i := range specs {
+		specs[i] += " " + t
+	}
+	return specs, nil
+}
+
+// calendarList returns the values of a set as a list for a calendar expression, * for all of them
+func calendarList(set uint64, min, max int) string {
+	var values []string
+	for v := min; v <= max; v++ {
+		if set&(1<<v) != 0 {
+			values = append(values, fmt.Sprintf("%02d", v))
+		}
+	}
+	if len(values) == max-min+1 {
+		return "*"
+	}
+	return strings.Join(values, ",")
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String()[:3], name) || strings.EqualFold(d.String(), name) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// systemdQuote quotes an argument of ExecStart, % starts a specifier and $ a variable in systemd
+func systemdQuote(arg string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$")
+	return `"` + r.Replace(arg) + `"`
+}
+
+// unitEscape keeps values on a single line, systemd units have no multi-line values
+func unitEscape(value string) string {
+	return strings.NewReplacer("\n", " ", "%", "%%").Replace(value)
+}
+
+func (s *SystemdScheduler) serviceUnit(job Job) (string, error) {
+	command, err := s.command()
+	if err != nil {
+		return "", fmt.Errorf("serviceUnit: %w", err)
+	}
+	doc, err := job.encode()
+	if err != nil {
+		return "", fmt.Errorf("serviceUnit: %w", err)
+	}
+	return fmt.Sprintf(`[Unit]
+Description=GoBackup %v
+%v=%v
+
+[Service]
+Type=oneshot
+# -scheduled skips the run while the job is paused
+ExecStart=%v run -scheduler systemd -job %v -scheduled
+`, unitEscape(job.Label), jobKey, doc, systemdQuote(command), systemdQuote(job.ID)), nil
+}
+
+func timerUnit(job Job) (string, error) {
+	specs, err := calendarSpecs(job.Schedule)
+	if err != nil {
+		return "", err
+	}
+	var delay string
+	if job.Schedule.Jitter > 0 {
+		delay = fmt.Sprintf("RandomizedDelaySec=%dmin\n", job.Schedule.Jitter)
+	}
+	// Persistent=true runs a missed backup as soon as the machine is up again
+	return fmt.Sprintf(`[Unit]
+Description=Schedule of GoBackup %v
+
+[Timer]
+OnCalendar=%v
+%vPersistent=true
+
+[Install]
+WantedBy=timers.target
+`, unitEscape(job.Label), strings.Join(specs, "\nOnCalendar="), delay), nil
+}
+
+// readUnit returns the values of a unit file by key, sections are not told apart.
+// The values of a key that is repeated, such as OnCalendar, are joined by newlines.
+func readUnit(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := map[string]string{}
+	sc := bufio.NewScanner(f)
+	// The job metadata can exceed the default line limit
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' || line[0] == '[' {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if previous, ok := values[key]; ok {
+				value = previous + "\n" + value
+			}
+			values[key] = value
+		}
+	}
+	return values, sc.Err()
+}
+
+// parseUnits reads the job of the units with the given ID back
+func (s *SystemdScheduler) parseUnits(dir, id string) Task {
+	task := Task{Name: unitName(id, "")}
+	service, err := readUnit(filepath.Join(dir, unitName(id, ".service")))
+	if err != nil {
+		task.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to read service unit"}
+		return task
+	}
+	job, _, err := ParseJob(service[jobKey])
+	if err != nil {
+		task.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to parse job metadata"}
+		return task
+	}
+	timer, err := readUnit(filepath.Join(dir, unitName(id, ".timer")))
+	if err != nil {
+		task.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to read timer unit"}
+		return task
+	}
+	// A custom schedule is kept as long as the timer still runs on it
+	if specs, err := calendarSpecs(job.Schedule); err != nil || strings.Join(specs, "\n") != timer["OnCalendar"] {
+		job.Schedule, err = parseOnCalendar(timer["OnCalendar"])
+		if err != nil {
+			task.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to parse timer unit"}
+			return task
+		}
+	}
+	job.ID = id
+	task.Job = job
+	_, err = os.Lstat(filepath.Join(dir, timersWants, unitName(id, ".timer")))
+	task.Enabled = err == nil
+	if task.Enabled {
+		after := s.now()
+		if job.PausedUntil.After(after) {
+			after = job.PausedUntil
+		}
+		task.NextRunTime = nextRunTime(job.Schedule, after)
+	}
+	return task
+}
+
+func (s *SystemdScheduler) List() ([]Task, error) {
+	dir, err := s.unitDir()
+	if err != nil {
+		return nil, &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find unit directory"}
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &ErrRetrieveTasksFailure{Inner: err, Message: "failed to read unit directory"}
+	}
+	var history map[string]HistoryEntry
+	if h, err := ReadHistory(); err == nil {
+		history = lastRuns(h)
+	}
+	var tasks []Task
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, unitPrefix) || !strings.HasSuffix(name, ".service") {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(name, unitPrefix), ".service")
+		task := s.parseUnits(dir, id)
+		if run, ok := history[id]; ok {
+			task.LastRunTime = run.Time
+			task.LastResult = run.Message
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+func (s *SystemdScheduler) Get(id string) (Task, error) {
+	tasks, err := s.List()
+	if err != nil {
+		return Task{}, err
+	}
+	for _, task := range tasks {
+		if task.Job.ID == id || task.Name == id {
+			return task, nil
+		}
+	}
+	return Task{}, &ErrTaskNotFound{Inner: fmt.Errorf("no units for job %q", id), Message: "failed to find task"}
+}
+
+// writeUnits writes both units of a job, the timer last so it never points at a missing service
+func (s *SystemdScheduler) writeUnits(dir string, job Job) error {
+	timer, err := timerUnit(job)
+	if err != nil {
+		return err
+	}
+	service, err := s.serviceUnit(job)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, unitName(job.ID, ".service")), []byte(service), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, unitName(job.ID, ".timer")), []byte(timer), 0o644)
+}
+
+// validUnitID reports whether an ID can be part of a unit name
+func validUnitID(id string) bool {
+	return len(id) > 0 && !strings.ContainsAny(id, `/\ @`) && !strings.HasPrefix(id, ".")
+}
+
+func (s *SystemdScheduler) Create(job Job) (Task, error) {
+	dir, err := s.unitDir()
+	if err != nil {
+		return Task{}, &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find unit directory"}
+	}
+	if !validUnitID(job.ID) {
+		return Task{}, &ErrCreateTaskFailure{Inner: fmt.Errorf("invalid job ID %q", job.ID), Message: "failed to create units"}
+	}
+	if _, err := os.Stat(filepath.Join(dir, unitName(job.ID, ".service"))); err == nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: errors.New("a job with the same ID already exists"), Message: "failed to create units"}
+	}
+	var unsupported *ErrUnsupportedSchedule
+	if err := s.writeUnits(dir, job); errors.As(err, &unsupported) {
+		return Task{}, unsupported
+	} else if err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to create units"}
+	}
+	if err := s.setEnabled(dir, job.ID, true); err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to enable timer"}
+	}
+	return s.Get(job.ID)
+}
+
+// Update rewrites the units of the job, the timer keeps its enabled state
+func (s *SystemdScheduler) Update(job Job) (Task, error) {
+	task, err := s.Get(job.ID)
+	if err != nil {
+		return Task{}, err
+	}
+	dir, err := s.unitDir()
+	if err != nil {
+		return Task{}, &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find unit directory"}
+	}
+	var unsupported *ErrUnsupportedSchedule
+	if err := s.writeUnits(dir, job); errors.As(err, &unsupported) {
+		return Task{}, unsupported
+	} else if err != nil {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "failed to update units"}
+	}
+	// The changed timer only takes effect once systemd reloads and restarts it
+	if task.Enabled {
+		err = s.reload(unitName(job.ID, ".timer"), "restart")
+	} else {
+		err = s.systemctl("daemon-reload")
+	}
+	if err != nil {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "failed to reload units"}
+	}
+	return s.Get(job.ID)
+}
+
+func (s *SystemdScheduler) reload(timer, action string) error {
+	if err := s.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return s.systemctl(action, timer)
+}
+
+func (s *SystemdScheduler) Delete(id string) error {
+	task, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	dir, err := s.unitDir()
+	if err != nil {
+		return &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find unit directory"}
+	}
+	// Unreadable units are found by their name, which is the prefix and the ID
+	id = strings.TrimPrefix(task.Name, unitPrefix)
+	if task.Enabled {
+		if err := s.setEnabled(dir, id, false); err != nil {
+			return &ErrDeleteTaskFailure{Inner: err, Message: "failed to stop timer"}
+		}
+	}
+	for _, suffix := range []string{".timer", ".service"} {
+		if err := os.Remove(filepath.Join(dir, unitName(id, suffix))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return &ErrDeleteTaskFailure{Inner: err, Message: "failed to delete unit"}
+		}
+	}
+	if err := s.systemctl("daemon-reload"); err != nil {
+		return &ErrDeleteTaskFailure{Inner: err, Message: "failed to reload units"}
+	}
+	return nil
+}
+
+// Run backs up the job right away through the engine and waits for it to finish
+func (s *SystemdScheduler) Run(id string) error {
+	task, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &ErrRunTaskFailure{Inner: task.Err, Message: "failed to read task"}
+	}
+	return RunJob(task.Job, s.now())
+}
+
+func (s *SystemdScheduler) Enable(id string) error {
+	return s.setJobEnabled(id, true)
+}
+
+func (s *SystemdScheduler) Disable(id string) error {
+	return s.setJobEnabled(id, false)
+}
+
+func (s *SystemdScheduler) setJobEnabled(id string, enabled bool) error {
+	task, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if task.Err != nil {
+		return &ErrParseTaskFailure{Inner: task.Err, Message: "failed to read task " + task.Name}
+	}
+	dir, err := s.unitDir()
+	if err != nil {
+		return &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find unit directory"}
+	}
+	if err := s.setEnabled(dir, task.Job.ID, enabled); err != nil {
+		return &ErrUpdateTaskFailure{Inner: err, Message: "failed to enable or disable timer"}
+	}
+	return nil
+}
+
+// setEnabled links or unlinks the timer and starts or stops it
+func (s *SystemdScheduler) setEnabled(dir, id string, enabled bool) error {
+	timer := unitName(id, ".timer")
+	link := filepath.Join(dir, timersWants, timer)
+	if !enabled {
+		if err := os.Remove(link); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return s.reload(timer, "stop")
+	}
+	if err := os.MkdirAll(filepath.Dir(link), 0o755); err != nil {
+		return err
+	}
+	if err := os.Symlink(filepath.Join("..", timer), link); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return s.reload(timer, "start")
+}
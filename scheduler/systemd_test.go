@@ -0,0 +1,216 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnCalendar(t *testing.T) {
+	testcases := []struct {
+		schedule Schedule
+		want     string
+	}{
+		{Schedule{Type: Daily, Hour: 17}, "*-*-* 17:00:00"},
+		{Schedule{Type: Weekly, DayOfWeek: time.Wednesday, Hour: 0}, "Wed *-*-* 00:00:00"},
+		{Schedule{Type: Monthly, DayOfMonth: 6, Hour: 9}, "*-*-06 09:00:00"},
+		{Schedule{Type: Daily, Hour: 17, Minute: 30}, "*-*-* 17:30:00"},
+	}
+	for _, tc := range testcases {
+		result := onCalendar(tc.schedule)
+		if result != tc.want {
+			t.Errorf(`onCalendar(%+v) = %v, want %v`, tc.schedule, result, tc.want)
+		}
+		if parsed, err := parseOnCalendar(result); err != nil || parsed != tc.schedule {
+			t.Errorf(`parseOnCalendar(%v) = %+v, %v want %+v`, result, parsed, err, tc.schedule)
+		}
+	}
+
+	zoned := Schedule{Type: Daily, Hour: 9, TimeZone: "Europe/Berlin"}
+	if parsed, err := parseOnCalendar("*-*-* 09:00:00 Europe/Berlin"); err != nil || parsed != zoned {
+		t.Errorf(`parseOnCalendar(*-*-* 09:00:00 Europe/Berlin) = %+v, %v want %+v`, parsed, err, zoned)
+	}
+
+	for _, expr := range []string{"", "hourly", "*-*-* 09:00:00 Mars/Olympus_Mons", "*-*-* 17:60:00", "*-*-* 17:30:15", "Wed *-*-06 09:00:00", "Foo *-*-* 09:00:00", "*-*-32 09:00:00", "*-*-* 24:00:00"} {
+		if _, err := parseOnCalendar(expr); err == nil {
+			t.Errorf(`parseOnCalendar(%v) did not return an error`, expr)
+		}
+	}
+}
+
+func TestCalendarSpecs(t *testing.T) {
+	testcases := []struct {
+		expr      string
+		want      []string
+		wantError bool
+	}{
+		{"*/30 9-17 * * 1-5", []string{"Mon,Tue,Wed,Thu,Fri *-*-* 09,10,11,12,13,14,15,16,17:00,30:00"}, false},
+		{"0 8-18/4 * * *", []string{"*-*-* 08,12,16:00:00"}, false},
+		{"0 9 * 3,6,9,12 5L", []string{"Fri *-03,06,09,12~07/1 09:00:00"}, false},
+		{"0 9 L * *", []string{"*-*~01 09:00:00"}, false},
+		{"0 9 1,L * *", []string{"*-*-01 09:00:00", "*-*~01 09:00:00"}, false},
+		{"0 9 * * 1#1,2#1,0#3", []string{"Sun *-*-15..21 09:00:00", "Mon,Tue *-*-01..07 09:00:00"}, false},
+		{"0 9 15 * 1", []string{"*-*-15 09:00:00", "Mon *-*-* 09:00:00"}, false},
+		{"0 9 */2 * 6,0", []string{"Sun,Sat *-*-01,03,05,07,09,11,13,15,17,19,21,23,25,27,29,31 09:00:00"}, false},
+		{"0 9 */2 * 5L", nil, true},
+	}
+	for _, tc := range testcases {
+		result, err := calendarSpecs(Schedule{Type: Custom, Expr: tc.expr})
+		if tc.wantError {
+			if _, ok := err.(*ErrUnsupportedSchedule); !ok {
+				t.Errorf(`calendarSpecs(%v) = %v, %v want ErrUnsupportedSchedule`, tc.expr, result, err)
+			}
+			continue
+		}
+		if err != nil || fmt.Sprint(result) != fmt.Sprint(tc.want) {
+			t.Errorf(`calendarSpecs(%v) = %q, %v want %q`, tc.expr, result, err, tc.want)
+		}
+	}
+
+	zoned := Schedule{Type: Daily, Hour: 9, Minute: 30, RepeatEvery: 60, RepeatUntil: 11*60 + 30, TimeZone: "Asia/Tokyo"}
+	want := []string{"*-*-* 09,10,11:30:00 Asia/Tokyo"}
+	if result, err := calendarSpecs(zoned); err != nil || fmt.Sprint(result) != fmt.Sprint(want) {
+		t.Errorf(`calendarSpecs(%+v) = %q, %v want %q`, zoned, result, err, want)
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	testcases := []struct {
+		arg, want string
+	}{
+		{`/usr/bin/gobackup`, `"/usr/bin/gobackup"`},
+		{`/home/o"brien/gobackup`, `"/home/o\"brien/gobackup"`},
+		{`/opt/100%/$HOME\gobackup`, `"/opt/100%%/$$HOME\\gobackup"`},
+	}
+	for _, tc := range testcases {
+		if result := systemdQuote(tc.arg); result != tc.want {
+			t.Errorf(`systemdQuote(%v) = %v, want %v`, tc.arg, result, tc.want)
+		}
+	}
+}
+
+func TestSystemdScheduler(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := filepath.Join(t.TempDir(), "user")
+	var calls []string
+	now := time.Date(2022, 4, 2, 17, 0, 0, 0, time.Local)
+	s := &SystemdScheduler{
+		UnitDir:   dir,
+		Command:   "/usr/bin/gobackup",
+		Systemctl: func(args ...string) error { calls = append(calls, strings.Join(args, " ")); return nil },
+		Now:       func() time.Time { return now },
+	}
+
+	job := NewJob(3, `/home/user/Documents`, `/mnt/backup`, false)
+	job.Label = "Documents 100%"
+	job.Schedule = Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 9}
+	task, err := s.Create(job)
+	if err != nil || !reflect.DeepEqual(task.Job, job) || !task.Enabled || !task.NextRunTime.Equal(time.Date(2022, 4, 4, 9, 0, 0, 0, time.Local)) {
+		t.Fatalf(`Create(%+v) = %+v, %v want an enabled task of the job running on monday`, job, task, err)
+	}
+	if _, err := s.Create(job); err == nil {
+		t.Errorf(`Create(job) did not return an error for a duplicate ID`)
+	}
+
+	doc, _ := job.encode()
+	wantService := fmt.Sprintf(`[Unit]
+Description=GoBackup Documents 100%%%%
+X-GoBackup-Job=%v
+
+[Service]
+Type=oneshot
+# -scheduled skips the run while the job is paused
+ExecStart="/usr/bin/gobackup" run -scheduler systemd -job "%v" -scheduled
+`, doc, job.ID)
+	wantTimer := `[Unit]
+Description=Schedule of GoBackup Documents 100%%
+
+[Timer]
+OnCalendar=Mon *-*-* 09:00:00
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+	timerName := "gobackup-" + job.ID + ".timer"
+	if b, err := os.ReadFile(filepath.Join(dir, "gobackup-"+job.ID+".service")); err != nil || string(b) != wantService {
+		t.Errorf(`Create(job) service unit = %v, %v want %v`, string(b), err, wantService)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, timerName)); err != nil || string(b) != wantTimer {
+		t.Errorf(`Create(job) timer unit = %v, %v want %v`, string(b), err, wantTimer)
+	}
+	if link, err := os.Readlink(filepath.Join(dir, "timers.target.wants", timerName)); err != nil || link != filepath.Join("..", timerName) {
+		t.Errorf(`Create(job) timers.target.wants link = %v, %v want ../%v`, link, err, timerName)
+	}
+	if fmt.Sprint(calls) != fmt.Sprint([]string{"daemon-reload", "start " + timerName}) {
+		t.Errorf(`Create(job) systemctl calls = %v, want a reload and start`, calls)
+	}
+
+	if err := s.Disable(job.ID); err != nil {
+		t.Fatalf(`Disable(%v) returned error %v`, job.ID, err)
+	}
+	job.Schedule = Schedule{Type: Monthly, DayOfMonth: 31, Hour: 23}
+	if task, err = s.Update(job); err != nil || task.Enabled || !reflect.DeepEqual(task.Job, job) {
+		t.Errorf(`Update(%+v) = %+v, %v want the changed job still disabled`, job, task, err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, timerName)); !strings.Contains(string(b), "\nOnCalendar=*-*-31 23:00:00\n") {
+		t.Errorf(`Update(job) timer unit = %v, want the monthly calendar`, string(b))
+	}
+	if err := s.Enable(job.ID); err != nil {
+		t.Fatalf(`Enable(%v) returned error %v`, job.ID, err)
+	}
+	if tasks, err := s.List(); err != nil || len(tasks) != 1 || !tasks[0].Enabled || !reflect.DeepEqual(tasks[0].Job, job) {
+		t.Errorf(`List() = %+v, %v want the enabled job`, tasks, err)
+	}
+	// A custom schedule with several OnCalendar lines reads back as the same expression
+	job.Schedule = Schedule{Type: Custom, Expr: "0 9 1,L * *"}
+	if task, err = s.Update(job); err != nil || !reflect.DeepEqual(task.Job, job) || !task.NextRunTime.Equal(time.Date(2022, 4, 30, 9, 0, 0, 0, time.Local)) {
+		t.Errorf(`Update(%+v) = %+v, %v want the custom schedule running on the last day of april`, job, task, err)
+	}
+	// A repetition that does not fit one calendar expression and a random delay
+	job.Schedule = Schedule{Type: Daily, Hour: 9, Minute: 15, RepeatEvery: 45, RepeatUntil: 11 * 60, Jitter: 5}
+	if task, err = s.Update(job); err != nil || !reflect.DeepEqual(task.Job, job) || !task.NextRunTime.Equal(time.Date(2022, 4, 3, 9, 15, 0, 0, time.Local)) {
+		t.Errorf(`Update(%+v) = %+v, %v want the repeated schedule running on sunday morning`, job, task, err)
+	}
+	wantSpecs := "OnCalendar=*-*-* 10:00:00\nOnCalendar=*-*-* 09:15:00\nOnCalendar=*-*-* 10:45:00\nRandomizedDelaySec=5min\n"
+	if b, _ := os.ReadFile(filepath.Join(dir, timerName)); !strings.Contains(string(b), wantSpecs) {
+		t.Errorf(`Update(%+v) timer = %v, want %v`, job, string(b), wantSpecs)
+	}
+
+	calls = nil
+	if err := s.Delete(job.ID); err != nil {
+		t.Fatalf(`Delete(%v) returned error %v`, job.ID, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf(`Delete(%v) left %v in the unit directory, want only timers.target.wants`, job.ID, entries)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "timers.target.wants")); len(entries) != 0 {
+		t.Errorf(`Delete(%v) left %v in timers.target.wants`, job.ID, entries)
+	}
+	if fmt.Sprint(calls) != fmt.Sprint([]string{"daemon-reload", "stop " + timerName, "daemon-reload"}) {
+		t.Errorf(`Delete(job) systemctl calls = %v, want the timer stopped`, calls)
+	}
+}
+
+func TestSystemdSchedulerForeignUnits(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "backup.service"), []byte("[Service]\nExecStart=/bin/true\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "gobackup-a1.service"), []byte("[Service]\nExecStart=/bin/true\n"), 0o644)
+	s := &SystemdScheduler{UnitDir: dir, Systemctl: func(args ...string) error { return nil }}
+
+	tasks, err := s.List()
+	if err != nil || len(tasks) != 1 || tasks[0].Err == nil || tasks[0].Name != "gobackup-a1" {
+		t.Fatalf(`List() = %+v, %v want a single unreadable task`, tasks, err)
+	}
+	if err := s.Delete(tasks[0].Name); err != nil {
+		t.Fatalf(`Delete(%v) returned error %v`, tasks[0].Name, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backup.service")); err != nil {
+		t.Errorf(`Delete(%v) removed a unit that is not ours: %v`, tasks[0].Name, err)
+	}
+}
@@ -0,0 +1,680 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"time"
+
+	"github.com/rickb777/date/period"
+
+	"github.com/capnspacehook/taskmaster"
+)
+
+// TaskScheduler registers jobs as tasks in the \GoBackup folder of the windows task scheduler
+type TaskScheduler struct {
+	// Command is the GoBackup executable the task runs, the running executable when it is empty
+	Command string
+}
+
+// New returns the scheduler of the platform
+func New() (Scheduler, error) {
+	return &TaskScheduler{}, nil
+}
+
+func init() {
+	backends["taskscheduler"] = func() Scheduler { return &TaskScheduler{} }
+}
+
+// createTrigger creates the trigger of a daily, weekly or monthly schedule. dMonth holds the days of the month with bit 0
+// as the 1st and bit 31 as the last day, dWeek the weekdays with bit 0 as sunday. A monthly trigger with weeks, bit 0 as
+// the first and bit 4 as the last week of the month, runs on the weekdays in those weeks instead of on the days of the month.
+func createTrigger(tType TriggerType, dMonth uint32, dWeek, weeks, dHour, dMinute uint8) (taskmaster.Trigger, error) {
+	// RepetitionDuration set to 365 days as a workaround to incorrect parsing of period in go-ole
+	// https://github.com/capnspacehook/taskmaster/issues/15
+	// The trigger expires after it, see expiry and Renew
+	startDate, err := getValidTime(dHour, dMinute)
+	if err != nil {
+		return nil, fmt.Errorf("createTrigger: %w", err)
+	}
+	if dWeek >= 1<<7 || weeks >= 1<<5 || (tType == Weekly && dWeek == 0) || (tType == Monthly && weeks == 0 && dMonth == 0) || (tType == Monthly && weeks != 0 && dWeek == 0) {
+		return nil, fmt.Errorf("createTrigger: %w", errors.New("invalid day of month/week"))
+	}
+	if tType == Daily {
+		return taskmaster.DailyTrigger{
+			TaskTrigger: taskmaster.TaskTrigger{
+				Enabled:       true,
+				StartBoundary: startDate,
+				RepetitionPattern: taskmaster.RepetitionPattern{
+					RepetitionDuration: period.NewYMD(0, 0, 365),
+					RepetitionInterval: period.NewHMS(24, 0, 0),
+				},
+			},
+			DayInterval: taskmaster.EveryDay,
+		}, nil
+	} else if tType == Weekly {
+		return taskmaster.WeeklyTrigger{
+			TaskTrigger: taskmaster.TaskTrigger{
+				Enabled:       true,
+				StartBoundary: startDate,
+				RepetitionPattern: taskmaster.RepetitionPattern{
+					RepetitionDuration: period.NewYMD(0, 0, 365),
+				},
+			},
+			WeekInterval: taskmaster.EveryWeek,
+			DaysOfWeek:   taskmaster.DayOfWeek(dWeek),
+		}, nil
+	} else if weeks != 0 {
+		return taskmaster.MonthlyDOWTrigger{
+			TaskTrigger: taskmaster.TaskTrigger{
+				Enabled:       true,
+				StartBoundary: startDate,
+				RepetitionPattern: taskmaster.RepetitionPattern{
+					RepetitionDuration: period.NewYMD(0, 0, 365),
+				},
+			},
+			DaysOfWeek:           taskmaster.DayOfWeek(dWeek),
+			MonthsOfYear:         taskmaster.AllMonths,
+			RunOnLastWeekOfMonth: weeks&uint8(taskmaster.LastWeek) != 0,
+			WeeksOfMonth:         taskmaster.Week(weeks),
+		}, nil
+	} else {
+		monthly := taskmaster.MonthlyTrigger{
+			TaskTrigger: taskmaster.TaskTrigger{
+				Enabled:       true,
+				StartBoundary: startDate,
+				RepetitionPattern: taskmaster.RepetitionPattern{
+					RepetitionDuration: period.NewYMD(0, 0, 365),
+				},
+			},
+			DaysOfMonth:  taskmaster.DayOfMonth(dMonth &^ LastDayOfMonth),
+			MonthsOfYear: taskmaster.AllMonths,
+		}
+		if dMonth&LastDayOfMonth != 0 {
+			// LastDayOfMonth does not pass the validation of taskmaster, the 31st and the last day of the month are the same days
+			monthly.DaysOfMonth |= taskmaster.ThirtyOne
+			monthly.RunOnLastWeekOfMonth = true
+		}
+		return monthly, nil
+	}
+}
+
+// createScheduleTrigger converts a schedule into the arguments of createTrigger
+func createScheduleTrigger(s Schedule) (taskmaster.Trigger, error) {
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("createScheduleTrigger: %w", err)
+	}
+	var dMonth uint32
+	if s.Type == Monthly && s.WeeksOfMonth == 0 {
+		dMonth = s.daySet()
+	}
+	return createTrigger(s.Type, dMonth, s.weekdaySet(), s.WeeksOfMonth, s.Hour, s.Minute)
+}
+
+// createScheduleTriggers returns the triggers of a schedule, the task scheduler runs the task on every one of them
+func createScheduleTriggers(s Schedule) ([]taskmaster.Trigger, error) {
+	if err := localOnly(s, "the task scheduler"); err != nil {
+		return nil, err
+	}
+	var triggers []taskmaster.Trigger
+	if s.Type != Custom {
+		trigger, err := createScheduleTrigger(s)
+		if err != nil {
+			return nil, err
+		}
+		if s.Repeats() {
+			times := s.times()
+			trigger = withRepetition(trigger, repetitionPattern(times[len(times)-1]-times[0], int(s.RepeatEvery)))
+		}
+		triggers = []taskmaster.Trigger{trigger}
+	} else {
+		e, err := ParseCronExpr(s.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("createScheduleTriggers: %w", err)
+		}
+		if triggers, err = createExprTriggers(e, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if s.Jitter > 0 {
+		for i, trigger := range triggers {
+			triggers[i] = withRandomDelay(trigger, period.NewHMS(int(s.Jitter)/60, int(s.Jitter)%60, 0))
+		}
+	}
+	return triggers, nil
+}
+
+// repetitionPattern repeats a trigger every interval minutes for span minutes
+func repetitionPattern(span, interval int) taskmaster.RepetitionPattern {
+	// Half an interval past the last run includes it, whether or not the end of the duration counts
+	duration := span*60 + interval*30
+	return taskmaster.RepetitionPattern{
+		RepetitionDuration: period.NewHMS(duration/3600, duration/60%60, duration%60),
+		RepetitionInterval: period.NewHMS(interval/60, interval%60, 0),
+	}
+}
+
+func withRepetition(tr taskmaster.Trigger, pattern taskmaster.RepetitionPattern) taskmaster.Trigger {
+	switch t := tr.(type) {
+	case taskmaster.DailyTrigger:
+		t.RepetitionPattern = pattern
+		return t
+	case taskmaster.WeeklyTrigger:
+		t.RepetitionPattern = pattern
+		return t
+	case taskmaster.MonthlyTrigger:
+		t.RepetitionPattern = pattern
+		return t
+	case taskmaster.MonthlyDOWTrigger:
+		t.RepetitionPattern = pattern
+		return t
+	}
+	return tr
+}
+
+func withRandomDelay(tr taskmaster.Trigger, delay period.Period) taskmaster.Trigger {
+	switch t := tr.(type) {
+	case taskmaster.DailyTrigger:
+		t.RandomDelay = delay
+		return t
+	case taskmaster.WeeklyTrigger:
+		t.RandomDelay = delay
+		return t
+	case taskmaster.MonthlyTrigger:
+		t.RandomDelay = delay
+		return t
+	case taskmaster.MonthlyDOWTrigger:
+		t.RandomDelay = delay
+		return t
+	}
+	return tr
+}
+
+// createExprTriggers maps a cron expression onto triggers starting after now.
+// Several times a day become a repetition of the first one, so they need to be evenly spaced.
+func createExprTriggers(e CronExpr, now time.Time) ([]taskmaster.Trigger, error) {
+	unsupported := func(reason string) error {
+		return &ErrUnsupportedSchedule{Inner: fmt.Errorf("%v in %q", reason, e), Message: "use the daemon scheduler for this schedule"}
+	}
+	var times []int
+	for h := 0; h < 24; h++ {
+		for m := 0; m < 60; m++ {
+			if e.hours&(1<<h) != 0 && e.minutes&(1<<m) != 0 {
+				times = append(times, h*60+m)
+			}
+		}
+	}
+	start := startBoundary(now, times[0]/60, times[0]%60)
+	trigger := taskmaster.TaskTrigger{
+		Enabled:       true,
+		StartBoundary: start,
+		RepetitionPattern: taskmaster.RepetitionPattern{
+			RepetitionDuration: period.NewYMD(0, 0, 365),
+		},
+	}
+	if len(times) > 1 {
+		interval := times[1] - times[0]
+		for i := 2; i < len(times); i++ {
+			if times[i]-times[i-1] != interval {
+				return nil, unsupported("the task scheduler needs evenly spaced times of day")
+			}
+		}
+		trigger.RepetitionPattern = repetitionPattern(times[len(times)-1]-times[0], interval)
+	}
+
+	months := taskmaster.Month(e.months >> 1)
+	allDays := e.days == 1<<32-2 && !e.lastDay
+	allWeekdays := true
+	for _, occurrences := range e.weeks {
+		allWeekdays = allWeekdays && occurrences&allOccurrences == allOccurrences
+	}
+	everyDay := (allDays && allWeekdays) || (!e.domStar && !e.dowStar && (allDays || allWeekdays))
+	if !everyDay && (e.domStar || e.dowStar) && !allDays && !allWeekdays {
+		return nil, unsupported("the task scheduler cannot combine days of the month and weekdays")
+	}
+
+	var triggers []taskmaster.Trigger
+	switch {
+	case everyDay && months == taskmaster.AllMonths:
+		if len(times) == 1 {
+			trigger.RepetitionPattern.RepetitionInterval = period.NewHMS(24, 0, 0)
+		}
+		return []taskmaster.Trigger{taskmaster.DailyTrigger{TaskTrigger: trigger, DayInterval: taskmaster.EveryDay}}, nil
+	case everyDay:
+		return []taskmaster.Trigger{taskmaster.MonthlyTrigger{TaskTrigger: trigger, DaysOfMonth: taskmaster.AllDaysOfMonth, MonthsOfYear: months}}, nil
+	}
+	if !allDays {
+		monthly := taskmaster.MonthlyTrigger{TaskTrigger: trigger, DaysOfMonth: taskmaster.DayOfMonth(e.days >> 1), MonthsOfYear: months}
+		if e.lastDay {
+			// LastDayOfMonth does not pass the validation of taskmaster, the 31st and the last day of the month are the same days
+			monthly.DaysOfMonth |= taskmaster.ThirtyOne
+			monthly.RunOnLastWeekOfMonth = true
+		}
+		triggers = append(triggers, monthly)
+	}
+	if !allWeekdays {
+		// Weekdays that run on the same occurrences share a trigger
+		var groups []uint8
+		days := map[uint8]taskmaster.DayOfWeek{}
+		for d, occurrences := range e.weeks {
+			if occurrences == 0 {
+				continue
+			}
+			if _, ok := days[occurrences]; !ok {
+				groups = append(groups, occurrences)
+			}
+			days[occurrences] |= 1 << d
+		}
+		for _, occurrences := range groups {
+			if occurrences&allOccurrences == allOccurrences && months == taskmaster.AllMonths {
+				triggers = append(triggers, taskmaster.WeeklyTrigger{TaskTrigger: trigger, DaysOfWeek: days[occurrences], WeekInterval: taskmaster.EveryWeek})
+				continue
+			}
+			// The fifth occurrence is always the last one, but not every month has one
+			weeks := taskmaster.Week(occurrences & 0xf)
+			if occurrences&allOccurrences == allOccurrences {
+				weeks = taskmaster.AllWeeks
+			} else if occurrences&(1<<4) != 0 {
+				return nil, unsupported("the task scheduler has no fifth week")
+			}
+			last := occurrences&lastOccurrence != 0 || weeks == taskmaster.AllWeeks
+			if last {
+				weeks |= taskmaster.LastWeek
+			}
+			triggers = append(triggers, taskmaster.MonthlyDOWTrigger{
+				TaskTrigger:          trigger,
+				DaysOfWeek:           days[occurrences],
+				MonthsOfYear:         months,
+				RunOnLastWeekOfMonth: last,
+				WeeksOfMonth:         weeks,
+			})
+		}
+	}
+	return triggers, nil
+}
+
+func parseSchedule(trigger taskmaster.Trigger) (Schedule, error) {
+	start := trigger.GetStartBoundary()
+	s := Schedule{Hour: uint8(start.Hour()), Minute: uint8(start.Minute())}
+	switch tr := trigger.(type) {
+	case taskmaster.DailyTrigger:
+		s.Type = Daily
+	case taskmaster.WeeklyTrigger:
+		s.Type = Weekly
+		s.DayOfWeek = time.Weekday(bits.TrailingZeros16(uint16(tr.DaysOfWeek)))
+		if bits.OnesCount16(uint16(tr.DaysOfWeek)) > 1 {
+			s.Weekdays = uint8(tr.DaysOfWeek)
+		}
+	case taskmaster.MonthlyTrigger:
+		s.Type = Monthly
+		s.DayOfMonth = uint8(bits.TrailingZeros32(uint32(tr.DaysOfMonth))) + 1
+		days := uint32(tr.DaysOfMonth)
+		if tr.RunOnLastWeekOfMonth {
+			// The 31st stands in for the last day of the month, see createTrigger
+			days = days&^uint32(taskmaster.ThirtyOne) | LastDayOfMonth
+		}
+		if bits.OnesCount32(days) > 1 || days&LastDayOfMonth != 0 {
+			s.DaysOfMonth = days
+		}
+	case taskmaster.MonthlyDOWTrigger:
+		s.Type = Monthly
+		s.DayOfWeek = time.Weekday(bits.TrailingZeros16(uint16(tr.DaysOfWeek)))
+		s.Weekdays = uint8(tr.DaysOfWeek)
+		s.WeeksOfMonth = uint8(tr.WeeksOfMonth)
+	default:
+		return s, fmt.Errorf("parseSchedule: %w", errors.New("unsupported trigger"))
+	}
+	return s, nil
+}
+
+func createAction(command string, job Job) (taskmaster.ExecAction, error) {
+	action, err := BackupTaskAction(command, job)
+	if err != nil {
+		return taskmaster.ExecAction{}, fmt.Errorf("createAction: %w", err)
+	}
+	return taskmaster.ExecAction{
+		Path: action.Command,
+		Args: action.Arguments,
+	}, nil
+}
+
+// expiry returns when the first of the triggers stops, the repetition of a day or more that works around
+// the parsing of periods ends after a year. Repetitions within a day restart every day and never expire.
+func expiry(triggers []taskmaster.Trigger) time.Time {
+	var first time.Time
+	for _, tr := range triggers {
+		d := tr.GetRepetitionDuration()
+		if d.Years() == 0 && d.Months() == 0 && d.Days() == 0 {
+			continue
+		}
+		end := tr.GetStartBoundary().AddDate(d.Years(), d.Months(), d.Days())
+		if first.IsZero() || end.Before(first) {
+			first = end
+		}
+	}
+	return first
+}
+
+// parseTask reads the job of a registered task, the schedule is taken from the trigger as it is what the task scheduler runs
+func parseTask(task taskmaster.RegisteredTask) Task {
+	t := Task{
+		Name:        task.Name,
+		Enabled:     task.Enabled,
+		NextRunTime: task.NextRunTime,
+		LastRunTime: task.LastRunTime,
+		MissedRuns:  task.MissedRuns,
+		LastResult:  task.LastTaskResult.String(),
+		Expires:     expiry(task.Definition.Triggers),
+	}
+	job, _, err := parseTaskJob(task)
+	if err != nil {
+		t.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to parse job metadata"}
+		return t
+	}
+	if len(task.Definition.Triggers) == 0 {
+		t.Err = &ErrParseTaskFailure{Inner: errors.New("task has no trigger"), Message: "failed to parse task"}
+		return t
+	}
+	// A custom schedule can need several triggers, they are not read back
+	if job.Schedule.Type != Custom {
+		schedule, err := parseSchedule(task.Definition.Triggers[0])
+		if err != nil {
+			t.Err = &ErrParseTaskFailure{Inner: err, Message: "failed to parse trigger"}
+			return t
+		}
+		// The repetition and the random delay are periods the task scheduler may have normalized, the job keeps its own
+		schedule.RepeatEvery, schedule.RepeatUntil, schedule.Jitter = job.Schedule.RepeatEvery, job.Schedule.RepeatUntil, job.Schedule.Jitter
+		// The days of the job are kept as they are written as long as the trigger still runs on them
+		if !sameSchedule(schedule, job.Schedule) {
+			job.Schedule = schedule
+		}
+	}
+	t.Job = job
+	return t
+}
+
+func (s *TaskScheduler) List() ([]Task, error) {
+	conn, err := taskmaster.Connect()
+	if err != nil {
+		return nil, &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+	}
+	defer conn.Disconnect()
+
+	tFolder, err := conn.GetTaskFolder(fPath)
+	// We only want to ignore this error when initially launching the app, when the folder does not exist yet
+	// Stopgap measure, TODO: extend taskmaster with better error handling, or implement taskmaster.taskFolderExist
+	if err != nil && !strings.Contains(err.Error(), "error getting folder") {
+		return nil, &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find task folder"}
+	}
+
+	var tasks []Task
+	for _, task := range tFolder.RegisteredTasks {
+		tasks = append(tasks, parseTask(task))
+	}
+	return tasks, nil
+}
+
+// parseTaskJob reads the job metadata of a task, a job of an older version gets the ID derived from the task path
+func parseTaskJob(task taskmaster.RegisteredTask) (Job, bool, error) {
+	job, legacy, err := ParseJob(task.Definition.RegistrationInfo.Documentation)
+	if err == nil && legacy {
+		job.ID = legacyJobID(task.Path)
+	}
+	return job, legacy, err
+}
+
+// migrate registers the rebuilt definition of every task created with an older version of the app, see Migrate.
+// A failed migration is not fatal, the old task is still readable and the migration is retried on the next start.
+// The first error is returned once all other tasks are migrated.
+func (s *TaskScheduler) migrate() error {
+	conn, err := taskmaster.Connect()
+	if err != nil {
+		return &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+	}
+	defer conn.Disconnect()
+
+	tFolder, err := conn.GetTaskFolder(fPath)
+	// There is nothing to migrate before the first task is created, see List
+	if err != nil && strings.Contains(err.Error(), "error getting folder") {
+		return nil
+	}
+	if err != nil {
+		return &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find task folder"}
+	}
+	var first error
+	for _, task := range tFolder.RegisteredTasks {
+		def, ok := migrateDefinition(&conn, task, s.Command)
+		if !ok {
+			continue
+		}
+		if _, err := conn.UpdateTask(task.Path, def); err != nil && first == nil {
+			first = &ErrUpdateTaskFailure{Inner: err, Message: "failed to migrate task " + task.Name}
+		}
+	}
+	return first
+}
+
+// migrateDefinition returns the definition a new task of the job of an older task would get, so the migrated task
+// has the current metadata, action, trigger and settings. ok is false when the task is up to date or unreadable.
+func migrateDefinition(conn *taskmaster.TaskService, task taskmaster.RegisteredTask, command string) (taskmaster.Definition, bool) {
+	job, legacy, err := parseTaskJob(task)
+	if err != nil || (!legacy && currentAction(task.Definition, job, command)) {
+		return taskmaster.Definition{}, false
+	}
+	// The legacy metadata has no schedule, parseTask takes it from the trigger
+	t := parseTask(task)
+	if t.Err != nil {
+		return taskmaster.Definition{}, false
+	}
+	def, err := newBackupDefinition(conn, t.Job, command)
+	if err != nil {
+		return taskmaster.Definition{}, false
+	}
+	// Migrating a task must not enable it
+	def.Settings.Enabled = task.Definition.Settings.Enabled
+	return def, true
+}
+
+// currentAction reports whether the task runs the job through GoBackup. Older versions ran a PowerShell script with
+// the paths of the job written into it, or a run command that did not name the task scheduler.
+func currentAction(def taskmaster.Definition, job Job, command string) bool {
+	action, err := createAction(command, job)
+	if err != nil {
+		// Without the executable the task cannot be rebuilt either
+		return true
+	}
+	if len(def.Actions) != 1 {
+		return false
+	}
+	execAction, ok := def.Actions[0].(taskmaster.ExecAction)
+	return ok && execAction.Args == action.Args
+}
+
+// findTask returns the registered task of a job ID or task name
+func findTask(conn *taskmaster.TaskService, id string) (taskmaster.RegisteredTask, error) {
+	tFolder, err := conn.GetTaskFolder(fPath)
+	// The folder does not exist before the first task is created, see List
+	if err != nil && strings.Contains(err.Error(), "error getting folder") {
+		return taskmaster.RegisteredTask{}, &ErrTaskNotFound{Inner: fmt.Errorf("no task for job %q", id), Message: "failed to find task"}
+	}
+	if err != nil {
+		return taskmaster.RegisteredTask{}, &ErrRetrieveTaskFolderFailure{Inner: err, Message: "failed to find task folder"}
+	}
+	for _, task := range tFolder.RegisteredTasks {
+		job, _, err := parseTaskJob(task)
+		if (err == nil && job.ID == id) || task.Name == id {
+			return task, nil
+		}
+	}
+	return taskmaster.RegisteredTask{}, &ErrTaskNotFound{Inner: fmt.Errorf("no task for job %q", id), Message: "failed to find task"}
+}
+
+func (s *TaskScheduler) Get(id string) (Task, error) {
+	conn, err := taskmaster.Connect()
+	if err != nil {
+		return Task{}, &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+	}
+	defer conn.Disconnect()
+
+	task, err := findTask(&conn, id)
+	if err != nil {
+		return Task{}, err
+	}
+	return parseTask(task), nil
+}
+
+func newBackupDefinition(conn *taskmaster.TaskService, job Job, command string) (taskmaster.Definition, error) {
+	def := conn.NewTaskDefinition()
+
+	triggers, err := createScheduleTriggers(job.Schedule)
+	if err != nil {
+		return taskmaster.Definition{}, fmt.Errorf("newBackupDefinition: failed to create trigger: %w", err)
+	}
+	for _, trigger := range triggers {
+		def.AddTrigger(trigger)
+	}
+
+	action, err := createAction(command, job)
+	if err != nil {
+		return taskmaster.Definition{}, fmt.Errorf("newBackupDefinition: failed to create action: %w", err)
+	}
+	def.AddAction(action)
+
+	def.Principal.RunLevel = taskmaster.TASK_RUNLEVEL_HIGHEST
+	// S4U is a necessary workaround to suppress a console from flashing up when executing
+	def.Principal.LogonType = taskmaster.TASK_LOGON_S4U
+	def.Settings.AllowDemandStart = true
+	def.Settings.AllowHardTerminate = false
+	def.Settings.DontStartOnBatteries = false
+	def.Settings.Enabled = true
+	// A second run of the same job while the first one is still copying would write into the same folder
+	def.Settings.MultipleInstances = taskmaster.TASK_INSTANCES_IGNORE_NEW
+	def.Settings.StopIfGoingOnBatteries = false
+	def.Settings.WakeToRun = false
+
+	doc, err := job.encode()
+	if err != nil {
+		return taskmaster.Definition{}, fmt.Errorf("newBackupDefinition: failed to encode job: %w", err)
+	}
+	def.RegistrationInfo.Documentation = doc
+
+	if job.PausedUntil.After(time.Now()) {
+		if err := pauseDefinition(&def, job.PausedUntil); err != nil {
+			return taskmaster.Definition{}, fmt.Errorf("newBackupDefinition: failed to pause: %w", err)
+		}
+	}
+	return def, nil
+}
+
+// Create registers a new task for the job, it never replaces an existing task.
+// Use FindDuplicates beforehand to warn about jobs that already back up the same folder on the same schedule.
+func (s *TaskScheduler) Create(job Job) (Task, error) {
+	conn, err := taskmaster.Connect()
+	if err != nil {
+		return Task{}, &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+	}
+	defer conn.Disconnect()
+
+	def, err := newBackupDefinition(&conn, job, s.Command)
+	var unsupported *ErrUnsupportedSchedule
+	if errors.As(err, &unsupported) {
+		return Task{}, unsupported
+	}
+	if err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to create task definition"}
+	}
+
+	createdTask, _, err := conn.CreateTask(fPath+"\\"+parseTaskPath(job.Label, job.ID), def, false)
+	if err != nil {
+		return Task{}, &ErrCreateTaskFailure{Inner: err, Message: "failed to create task"}
+	}
+	return parseTask(createdTask), nil
+}
+
+// Update replaces the definition of an existing task in a single registration.
+// The task keeps its name, so the run history in the task scheduler is preserved.
+func (s *TaskScheduler) Update(job Job) (Task, error) {
+	conn, err := taskmaster.Connect()
+	if err != nil {
+		return Task{}, &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+	}
+	defer conn.Disconnect()
+
+	task, err := findTask(&conn, job.ID)
+	if err != nil {
+		return Task{}, err
+	}
+
+	def, err := newBackupDefinition(&conn, job, s.Command)
+	var unsupported *ErrUnsupportedSchedule
+	if errors.As(err, &unsupported) {
+		return Task{}, unsupported
+	}
+	if err != nil {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "failed to create task definition"}
+	}
+	// Editing a task must not silently enable it
+	def.Settings.Enabled = task.Definition.Settings.Enabled
+
+	updatedTask, err := conn.UpdateTask(task.Path, def)
+	if err != nil {
+		return Task{}, &ErrUpdateTaskFailure{Inner: err, Message: "failed to update task"}
+	}
+	return parseTask(updatedTask), nil
+}
+
+// Delete removes the task of the job, and the task folder with the last task
+func (s *TaskScheduler) Delete(id string) error {
+	conn, err := taskmaster.Connect()
+	if err != nil {
+		return &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+	}
+	defer conn.Disconnect()
+
+	task, err := findTask(&conn, id)
+	if err != nil {
+		return err
+	}
+	err = conn.DeleteTask(task.Path)
+	if err != nil {
+		return &ErrDeleteTaskFailure{Inner: err, Message: "Failed to delete task"}
+	}
+
+	tFolder, err := conn.GetTaskFolder(fPath)
+	if err == nil && len(tFolder.RegisteredTasks) == 0 {
+		success, err := conn.DeleteFolder(fPath, false)
+		if err != nil || success != true {
+			return &ErrDeleteTaskFolderFailure{Inner: err, Message: "Failed to delete task folder"}
+		}
+	}
+	return nil
+}
+
+func (s *TaskScheduler) Run(id string) error {
+	conn, err := taskmaster.Connect()
+	if err != nil {
+		return &ErrConnectSchedulerFailure{Inner: err, Message: "failed to connect to task scheduler"}
+	}
+	defer conn.Disconnect()
+
+	task, err := findTask(&conn, id)
+	if err != nil {
+		return err
+	}
+
+	runningTask, err := task.Run()
+	if err != nil {
+		return &ErrRunTaskFailure{Inner: err, Message: "failed to run task"}
+	}
+	runningTask.Release()
+	return nil
+}
+
+func (s *TaskScheduler) Enable(id string) error {
+	return setEnabled(id, true)
+}
+
+func (s *TaskScheduler) Disable(id string) error {
+	return setEnabled(id, false)
+}
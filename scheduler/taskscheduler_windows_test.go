@@ -0,0 +1,416 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/capnspacehook/taskmaster"
+	"github.com/rickb777/date/period"
+)
+
+func TestCreateTrigger(t *testing.T) {
+	startDate, _ := getValidTime(12, 0)
+	testcases := []struct {
+		tType               TriggerType
+		dMonth              uint32
+		dWeek, weeks, dHour uint8
+		wantTrigger         taskmaster.Trigger
+		wantError           error
+	}{
+		{
+			Daily, 0, 0, 0, 12, taskmaster.DailyTrigger{
+				TaskTrigger: taskmaster.TaskTrigger{
+					Enabled:       true,
+					StartBoundary: startDate,
+					RepetitionPattern: taskmaster.RepetitionPattern{
+						RepetitionDuration: period.NewYMD(0, 0, 365),
+						RepetitionInterval: period.NewHMS(24, 0, 0),
+					},
+				},
+				DayInterval: taskmaster.EveryDay,
+			}, nil,
+		},
+		{
+			Weekly, 0, uint8(taskmaster.Wednesday), 0, 0, taskmaster.WeeklyTrigger{
+				TaskTrigger: taskmaster.TaskTrigger{
+					Enabled:       true,
+					StartBoundary: startDate,
+					RepetitionPattern: taskmaster.RepetitionPattern{
+						RepetitionDuration: period.NewYMD(0, 0, 365),
+					},
+				},
+				WeekInterval: taskmaster.EveryWeek,
+				DaysOfWeek:   taskmaster.Wednesday,
+			}, nil,
+		},
+		{
+			Monthly, uint32(taskmaster.Six), 0, 0, 0, taskmaster.MonthlyTrigger{
+				TaskTrigger: taskmaster.TaskTrigger{
+					Enabled:       true,
+					StartBoundary: startDate,
+					RepetitionPattern: taskmaster.RepetitionPattern{
+						RepetitionDuration: period.NewYMD(0, 0, 365),
+					},
+				},
+				DaysOfMonth:  taskmaster.Six,
+				MonthsOfYear: taskmaster.AllMonths,
+			}, nil,
+		},
+		{
+			Weekly, 0, uint8(taskmaster.Monday | taskmaster.Wednesday | taskmaster.Friday), 0, 0, taskmaster.WeeklyTrigger{
+				TaskTrigger: taskmaster.TaskTrigger{
+					Enabled:       true,
+					StartBoundary: startDate,
+					RepetitionPattern: taskmaster.RepetitionPattern{
+						RepetitionDuration: period.NewYMD(0, 0, 365),
+					},
+				},
+				WeekInterval: taskmaster.EveryWeek,
+				DaysOfWeek:   taskmaster.Monday | taskmaster.Wednesday | taskmaster.Friday,
+			}, nil,
+		},
+		{
+			Monthly, uint32(taskmaster.One|taskmaster.Fifteen) | LastDayOfMonth, 0, 0, 0, taskmaster.MonthlyTrigger{
+				TaskTrigger: taskmaster.TaskTrigger{
+					Enabled:       true,
+					StartBoundary: startDate,
+					RepetitionPattern: taskmaster.RepetitionPattern{
+						RepetitionDuration: period.NewYMD(0, 0, 365),
+					},
+				},
+				DaysOfMonth:          taskmaster.One | taskmaster.Fifteen | taskmaster.ThirtyOne,
+				MonthsOfYear:         taskmaster.AllMonths,
+				RunOnLastWeekOfMonth: true,
+			}, nil,
+		},
+		{
+			Monthly, 0, uint8(taskmaster.Monday), uint8(taskmaster.First), 0, taskmaster.MonthlyDOWTrigger{
+				TaskTrigger: taskmaster.TaskTrigger{
+					Enabled:       true,
+					StartBoundary: startDate,
+					RepetitionPattern: taskmaster.RepetitionPattern{
+						RepetitionDuration: period.NewYMD(0, 0, 365),
+					},
+				},
+				DaysOfWeek:   taskmaster.Monday,
+				MonthsOfYear: taskmaster.AllMonths,
+				WeeksOfMonth: taskmaster.First,
+			}, nil,
+		},
+		{
+			Monthly, 0, 0, 0, 0, nil, fmt.Errorf("createTrigger: %w", errors.New("invalid day of month/week")),
+		},
+		{
+			Monthly, uint32(taskmaster.Six), 1 << 7, 0, 0, nil, fmt.Errorf("createTrigger: %w", errors.New("invalid day of month/week")),
+		},
+		{
+			Monthly, 0, 0, uint8(taskmaster.LastWeek), 0, nil, fmt.Errorf("createTrigger: %w", errors.New("invalid day of month/week")),
+		},
+		{
+			Weekly, 0, 0, 0, 12, nil, fmt.Errorf("createTrigger: %w", errors.New("invalid day of month/week")),
+		},
+		{
+			Weekly, 12, uint8(taskmaster.Wednesday), 0, 25, nil, fmt.Errorf("createTrigger: %w", fmt.Errorf("getValidTime: %w", errors.New("invalid hour"))),
+		},
+	}
+	for _, tc := range testcases {
+		result, err := createTrigger(tc.tType, tc.dMonth, tc.dWeek, tc.weeks, tc.dHour, 0)
+
+		if result != tc.wantTrigger || err != tc.wantError {
+			t.Errorf(`createTrigger(tc.tType, tc.dMonth, tc.dWeek, tc.weeks, tc.dHour) = %v, %v, %v, %v, %v want match for value: %v, error: %v`, tc.tType, tc.dMonth, tc.dWeek, tc.weeks, tc.dHour, tc.wantTrigger, tc.wantError)
+		}
+	}
+}
+
+func TestCreateExprTriggers(t *testing.T) {
+	now := time.Date(2022, 4, 2, 17, 0, 0, 0, time.Local)
+	tomorrow := func(hour, minute int) time.Time { return time.Date(2022, 4, 3, hour, minute, 0, 0, time.Local) }
+	yearly := taskmaster.RepetitionPattern{RepetitionDuration: period.NewYMD(0, 0, 365)}
+	testcases := []struct {
+		expr         string
+		wantTriggers []taskmaster.Trigger
+		wantError    bool
+	}{
+		{"30 9 * * *", []taskmaster.Trigger{taskmaster.DailyTrigger{
+			TaskTrigger: taskmaster.TaskTrigger{Enabled: true, StartBoundary: tomorrow(9, 30), RepetitionPattern: taskmaster.RepetitionPattern{
+				RepetitionDuration: period.NewYMD(0, 0, 365),
+				RepetitionInterval: period.NewHMS(24, 0, 0),
+			}},
+			DayInterval: taskmaster.EveryDay,
+		}}, false},
+		{"0 8-18/4 * * 1-5", []taskmaster.Trigger{taskmaster.WeeklyTrigger{
+			TaskTrigger: taskmaster.TaskTrigger{Enabled: true, StartBoundary: tomorrow(8, 0), RepetitionPattern: taskmaster.RepetitionPattern{
+				RepetitionDuration: period.NewHMS(10, 0, 0),
+				RepetitionInterval: period.NewHMS(4, 0, 0),
+			}},
+			DaysOfWeek:   taskmaster.Monday | taskmaster.Tuesday | taskmaster.Wednesday | taskmaster.Thursday | taskmaster.Friday,
+			WeekInterval: taskmaster.EveryWeek,
+		}}, false},
+		{"0 9 * 3,6,9,12 5L", []taskmaster.Trigger{taskmaster.MonthlyDOWTrigger{
+			TaskTrigger:          taskmaster.TaskTrigger{Enabled: true, StartBoundary: tomorrow(9, 0), RepetitionPattern: yearly},
+			DaysOfWeek:           taskmaster.Friday,
+			MonthsOfYear:         taskmaster.March | taskmaster.June | taskmaster.September | taskmaster.December,
+			RunOnLastWeekOfMonth: true,
+			WeeksOfMonth:         taskmaster.LastWeek,
+		}}, false},
+		{"0 9 1,L * *", []taskmaster.Trigger{taskmaster.MonthlyTrigger{
+			TaskTrigger:          taskmaster.TaskTrigger{Enabled: true, StartBoundary: tomorrow(9, 0), RepetitionPattern: yearly},
+			DaysOfMonth:          taskmaster.One | taskmaster.ThirtyOne,
+			MonthsOfYear:         taskmaster.AllMonths,
+			RunOnLastWeekOfMonth: true,
+		}}, false},
+		{"0 9 15 * 1", []taskmaster.Trigger{
+			taskmaster.MonthlyTrigger{
+				TaskTrigger:  taskmaster.TaskTrigger{Enabled: true, StartBoundary: tomorrow(9, 0), RepetitionPattern: yearly},
+				DaysOfMonth:  taskmaster.Fifteen,
+				MonthsOfYear: taskmaster.AllMonths,
+			},
+			taskmaster.WeeklyTrigger{
+				TaskTrigger:  taskmaster.TaskTrigger{Enabled: true, StartBoundary: tomorrow(9, 0), RepetitionPattern: yearly},
+				DaysOfWeek:   taskmaster.Monday,
+				WeekInterval: taskmaster.EveryWeek,
+			},
+		}, false},
+		{"0 9,17 * * *", nil, false},
+		{"0 9,10,12 * * *", nil, true},
+		{"0 9 * * 1#5", nil, true},
+		{"0 9 */2 * 1", nil, true},
+	}
+	for _, tc := range testcases {
+		e, err := ParseCronExpr(tc.expr)
+		if err != nil {
+			t.Fatalf(`ParseCronExpr(%v) returned error %v`, tc.expr, err)
+		}
+		result, err := createExprTriggers(e, now)
+		if tc.wantError {
+			if _, ok := err.(*ErrUnsupportedSchedule); !ok {
+				t.Errorf(`createExprTriggers(%v) = %v, %v want ErrUnsupportedSchedule`, tc.expr, result, err)
+			}
+			continue
+		}
+		if err != nil || (tc.wantTriggers != nil && fmt.Sprint(result) != fmt.Sprint(tc.wantTriggers)) {
+			t.Errorf(`createExprTriggers(%v) = %v, %v want %v`, tc.expr, result, err, tc.wantTriggers)
+		}
+	}
+}
+
+func TestCreateScheduleTriggers(t *testing.T) {
+	testcases := []struct {
+		schedule                      Schedule
+		wantRepetition                taskmaster.RepetitionPattern
+		wantDelay                     period.Period
+		wantHour, wantMinute, wantLen int
+	}{
+		{Schedule{Type: Daily, Hour: 9, Minute: 15}, taskmaster.RepetitionPattern{RepetitionDuration: period.NewYMD(0, 0, 365), RepetitionInterval: period.NewHMS(24, 0, 0)}, period.Period{}, 9, 15, 1},
+		{
+			Schedule{Type: Weekly, DayOfWeek: time.Monday, Hour: 9, Minute: 15, RepeatEvery: 45, RepeatUntil: 17 * 60, Jitter: 10},
+			taskmaster.RepetitionPattern{RepetitionDuration: period.NewHMS(7, 52, 30), RepetitionInterval: period.NewHMS(0, 45, 0)}, period.NewHMS(0, 10, 0), 9, 15, 1,
+		},
+		{Schedule{Type: Custom, Expr: "0 9 15 * 1", Jitter: 90}, taskmaster.RepetitionPattern{RepetitionDuration: period.NewYMD(0, 0, 365)}, period.NewHMS(1, 30, 0), 9, 0, 2},
+	}
+	for _, tc := range testcases {
+		result, err := createScheduleTriggers(tc.schedule)
+		if err != nil || len(result) != tc.wantLen {
+			t.Errorf(`createScheduleTriggers(%+v) = %v, %v want %v triggers`, tc.schedule, result, err, tc.wantLen)
+			continue
+		}
+		for _, trigger := range result {
+			start := trigger.GetStartBoundary()
+			if trigger.GetRepetitionDuration() != tc.wantRepetition.RepetitionDuration || trigger.GetRepetitionInterval() != tc.wantRepetition.RepetitionInterval || start.Hour() != tc.wantHour || start.Minute() != tc.wantMinute || fmt.Sprint(trigger) != fmt.Sprint(withRandomDelay(trigger, tc.wantDelay)) {
+				t.Errorf(`createScheduleTriggers(%+v) = %v want repetition %v and random delay %v`, tc.schedule, trigger, tc.wantRepetition, tc.wantDelay)
+			}
+		}
+	}
+	zoned := Schedule{Type: Daily, Hour: 9, TimeZone: "Asia/Tokyo"}
+	if result, err := createScheduleTriggers(zoned); err == nil {
+		t.Errorf(`createScheduleTriggers(%+v) = %v, want ErrUnsupportedSchedule`, zoned, result)
+	}
+}
+
+func TestCreateAction(t *testing.T) {
+	job := Job{ID: "6f1c2a4e-8b3d-4e5f-9a0b-1c2d3e4f5a6b", Src: `C:\test`, Dest: `Z:\backupme`}
+	testcases := []struct {
+		command    string
+		wantAction taskmaster.ExecAction
+	}{
+		{`C:\Program Files\GoBackup\GoBackup.exe`, taskmaster.ExecAction{Path: `C:\Program Files\GoBackup\GoBackup.exe`, Args: `run -scheduler taskscheduler -job 6f1c2a4e-8b3d-4e5f-9a0b-1c2d3e4f5a6b`}},
+	}
+
+	for _, tc := range testcases {
+		result, err := createAction(tc.command, job)
+		if result != tc.wantAction || err != nil {
+			t.Errorf(`createAction(%v, ...) = %v, %v, want match for %v, nil`, tc.command, result, err, tc.wantAction)
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := createAction("", job); result.Path != exe || err != nil {
+		t.Errorf(`createAction("", ...) = %v, %v, want the running executable %v`, result, err, exe)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	start := time.Date(2022, 4, 2, 17, 0, 0, 0, time.Local)
+	trigger := func(start time.Time, duration period.Period) taskmaster.Trigger {
+		return taskmaster.DailyTrigger{
+			TaskTrigger: taskmaster.TaskTrigger{StartBoundary: start, RepetitionPattern: taskmaster.RepetitionPattern{RepetitionDuration: duration}},
+			DayInterval: taskmaster.EveryDay,
+		}
+	}
+	testcases := []struct {
+		triggers []taskmaster.Trigger
+		want     time.Time
+	}{
+		{[]taskmaster.Trigger{trigger(start, period.NewYMD(0, 0, 365))}, time.Date(2023, 4, 2, 17, 0, 0, 0, time.Local)},
+		// A repetition within the day restarts every day
+		{[]taskmaster.Trigger{trigger(start, period.NewHMS(7, 52, 30))}, time.Time{}},
+		{[]taskmaster.Trigger{trigger(start, period.Period{})}, time.Time{}},
+		{[]taskmaster.Trigger{trigger(start, period.NewYMD(0, 0, 365)), trigger(start.AddDate(0, -1, 0), period.NewYMD(0, 0, 365))}, time.Date(2023, 3, 2, 17, 0, 0, 0, time.Local)},
+	}
+	for _, tc := range testcases {
+		if result := expiry(tc.triggers); !result.Equal(tc.want) {
+			t.Errorf(`expiry(%v) = %v, want %v`, tc.triggers, result, tc.want)
+		}
+	}
+}
+
+func TestParseTask(t *testing.T) {
+	startDate := time.Date(2022, 4, 2, 17, 0, 0, 0, time.Local)
+	testcases := []struct {
+		doc       string
+		trigger   taskmaster.Trigger
+		wantJob   Job
+		wantError bool
+	}{
+		{
+			`{"version":1,"id":"a1","label":"test","src":"C:\\test","dest":"Z:\\backupme","backupLimit":3,"overwrite":false}`,
+			taskmaster.WeeklyTrigger{TaskTrigger: taskmaster.TaskTrigger{StartBoundary: startDate}, DaysOfWeek: taskmaster.Wednesday},
+			Job{Version: 1, ID: "a1", Label: "test", Src: `C:\test`, Dest: `Z:\backupme`, BackupLimit: 3, Schedule: Schedule{Type: Weekly, DayOfWeek: 3, Hour: 17}}, false,
+		},
+		{
+			`C:\test|Z:\backupme|-|Yes`,
+			taskmaster.MonthlyTrigger{TaskTrigger: taskmaster.TaskTrigger{StartBoundary: startDate}, DaysOfMonth: taskmaster.Six},
+			Job{Version: 1, Label: "test", Src: `C:\test`, Dest: `Z:\backupme`, Overwrite: true, Schedule: Schedule{Type: Monthly, DayOfMonth: 6, Hour: 17}}, false,
+		},
+		{
+			`C:\test|Z:\backupme|11|No`,
+			taskmaster.DailyTrigger{TaskTrigger: taskmaster.TaskTrigger{StartBoundary: startDate}},
+			Job{Version: 1, Label: "test", Src: `C:\test`, Dest: `Z:\backupme`, BackupLimit: 11, Schedule: Schedule{Type: Daily, Hour: 17}}, false,
+		},
+		{
+			`{"version":1,"id":"a1","label":"test","src":"C:\\test","dest":"Z:\\backupme","schedule":{"type":"monthly","dayOfWeek":1,"dayOfMonth":0,"weekdays":2,"weeksOfMonth":17,"hour":17}}`,
+			taskmaster.MonthlyDOWTrigger{TaskTrigger: taskmaster.TaskTrigger{StartBoundary: startDate}, DaysOfWeek: taskmaster.Monday, WeeksOfMonth: taskmaster.First | taskmaster.LastWeek, RunOnLastWeekOfMonth: true},
+			Job{Version: 1, ID: "a1", Label: "test", Src: `C:\test`, Dest: `Z:\backupme`, Schedule: Schedule{Type: Monthly, DayOfWeek: time.Monday, Weekdays: 2, WeeksOfMonth: 17, Hour: 17}}, false,
+		},
+		{
+			`C:\test|Z:\backupme|3|No`,
+			taskmaster.MonthlyTrigger{TaskTrigger: taskmaster.TaskTrigger{StartBoundary: startDate}, DaysOfMonth: taskmaster.One | taskmaster.ThirtyOne, RunOnLastWeekOfMonth: true},
+			Job{Version: 1, Label: "test", Src: `C:\test`, Dest: `Z:\backupme`, BackupLimit: 3, Schedule: Schedule{Type: Monthly, DayOfMonth: 1, DaysOfMonth: 1 | LastDayOfMonth, Hour: 17}}, false,
+		},
+		{`C:\test|Z:\backupme|No`, taskmaster.DailyTrigger{}, Job{}, true},
+		{`C:\test|Z:\backupme|x|No`, taskmaster.DailyTrigger{}, Job{}, true},
+		{`C:\test|Z:\backupme|1|No`, taskmaster.BootTrigger{}, Job{}, true},
+	}
+	for _, tc := range testcases {
+		task := taskmaster.RegisteredTask{Name: "test", Definition: taskmaster.Definition{Triggers: []taskmaster.Trigger{tc.trigger}}}
+		task.Definition.RegistrationInfo.Documentation = tc.doc
+		result := parseTask(task)
+
+		if tc.wantError && result.Err == nil {
+			t.Errorf(`parseTask(%v) did not return an error`, tc.doc)
+		}
+		// Legacy tasks get the ID derived from their path
+		if len(tc.wantJob.ID) == 0 {
+			tc.wantJob.ID = legacyJobID(task.Path)
+		}
+		if !tc.wantError && (result.Err != nil || !reflect.DeepEqual(result.Job, tc.wantJob) || result.Name != "test") {
+			t.Errorf(`parseTask(%v) = %+v, %v want match for %+v`, tc.doc, result.Job, result.Err, tc.wantJob)
+		}
+	}
+}
+
+func TestMigrateDefinition(t *testing.T) {
+	const command = `C:\Program Files\GoBackup\GoBackup.exe`
+	start := time.Date(2022, 4, 2, 17, 0, 0, 0, time.Local)
+	legacy := taskmaster.RegisteredTask{Name: "test", Definition: taskmaster.Definition{
+		Triggers: []taskmaster.Trigger{taskmaster.WeeklyTrigger{TaskTrigger: taskmaster.TaskTrigger{StartBoundary: start}, DaysOfWeek: taskmaster.Wednesday}},
+		Actions:  []taskmaster.Action{taskmaster.ExecAction{Path: "powershell.exe", Args: `-Command "xcopy 'C:\test' 'Z:\backupme'"`}},
+	}}
+	legacy.Definition.RegistrationInfo.Documentation = `C:\test|Z:\backupme|3|No`
+	legacy.Definition.Principal.RunLevel = taskmaster.TASK_RUNLEVEL_HIGHEST
+	legacy.Definition.Settings.Enabled = false
+
+	def, ok := migrateDefinition(&taskmaster.TaskService{}, legacy, command)
+	if !ok {
+		t.Fatalf(`migrateDefinition(legacy task) = false, want a rebuilt definition`)
+	}
+	job, isLegacy, err := ParseJob(def.RegistrationInfo.Documentation)
+	wantSchedule := Schedule{Type: Weekly, DayOfWeek: time.Wednesday, Hour: 17}
+	if err != nil || isLegacy || job.Src != `C:\test` || job.BackupLimit != 3 || !reflect.DeepEqual(job.Schedule, wantSchedule) {
+		t.Errorf(`migrateDefinition(legacy task) job = %+v, %v, %v want the versioned job on its schedule`, job, isLegacy, err)
+	}
+	wantAction := taskmaster.ExecAction{Path: command, Args: "run -scheduler taskscheduler -job " + job.ID}
+	if len(def.Actions) != 1 || def.Actions[0] != wantAction {
+		t.Errorf(`migrateDefinition(legacy task) actions = %+v, want %+v`, def.Actions, wantAction)
+	}
+	if len(def.Triggers) != 1 || def.Principal.LogonType != taskmaster.TASK_LOGON_S4U || def.Settings.MultipleInstances != taskmaster.TASK_INSTANCES_IGNORE_NEW || def.Settings.Enabled {
+		t.Errorf(`migrateDefinition(legacy task) = %+v, want the definition of a new disabled task`, def)
+	}
+
+	current := taskmaster.RegisteredTask{Name: "test", Definition: def}
+	if _, ok := migrateDefinition(&taskmaster.TaskService{}, current, command); ok {
+		t.Errorf(`migrateDefinition(migrated task) = true, want the task left alone`)
+	}
+
+	// Tasks with versioned metadata were still created with the PowerShell script before GoBackup ran them itself
+	script := current
+	script.Definition.Actions = []taskmaster.Action{taskmaster.ExecAction{Path: "powershell.exe", Args: `-Command "xcopy 'C:\test' 'Z:\backupme'"`}}
+	def, ok = migrateDefinition(&taskmaster.TaskService{}, script, command)
+	if migrated, _, err := ParseJob(def.RegistrationInfo.Documentation); !ok || err != nil || migrated.ID != job.ID {
+		t.Fatalf(`migrateDefinition(script task) = %v, %v want a rebuilt definition of job %v`, ok, err, job.ID)
+	}
+	if len(def.Actions) != 1 || def.Actions[0] != wantAction {
+		t.Errorf(`migrateDefinition(script task) actions = %+v, want %+v`, def.Actions, wantAction)
+	}
+}
+
+func TestPauseDefinition(t *testing.T) {
+	start := time.Date(2022, 4, 2, 17, 0, 0, 0, time.Local)
+	testcases := []struct {
+		until, wantStart time.Time
+	}{
+		{time.Date(2022, 5, 1, 0, 0, 0, 0, time.Local), time.Date(2022, 5, 1, 17, 0, 0, 0, time.Local)},
+		{time.Date(2022, 5, 1, 18, 30, 0, 0, time.Local), time.Date(2022, 5, 2, 17, 0, 0, 0, time.Local)},
+	}
+	for _, tc := range testcases {
+		def := taskmaster.Definition{Triggers: []taskmaster.Trigger{
+			taskmaster.WeeklyTrigger{TaskTrigger: taskmaster.TaskTrigger{StartBoundary: start}, DaysOfWeek: taskmaster.Monday},
+		}}
+		def.RegistrationInfo.Documentation = `C:\test|Z:\backupme|3|No`
+		if err := pauseDefinition(&def, tc.until); err != nil {
+			t.Fatalf(`pauseDefinition(def, %v) returned error %v`, tc.until, err)
+		}
+
+		if result := def.Triggers[0].GetStartBoundary(); !result.Equal(tc.wantStart) {
+			t.Errorf(`pauseDefinition(def, %v) start = %v, want %v`, tc.until, result, tc.wantStart)
+		}
+		job, _, err := ParseJob(def.RegistrationInfo.Documentation)
+		if err != nil || !job.PausedUntil.Equal(tc.until) || job.BackupLimit != 3 {
+			t.Errorf(`pauseDefinition(def, %v) job = %+v, %v want PausedUntil %v`, tc.until, job, err, tc.until)
+		}
+
+		if err := setJobPause(&def, time.Time{}); err != nil {
+			t.Fatalf(`setJobPause(def, zero) returned error %v`, err)
+		}
+		if job, _, _ := ParseJob(def.RegistrationInfo.Documentation); !job.PausedUntil.IsZero() {
+			t.Errorf(`setJobPause(def, zero) = %v, want pause removed`, def.RegistrationInfo.Documentation)
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/rickb777/date/period"
+)
+
+// taskNamespace is the namespace of the task scheduler XML schema
+const taskNamespace = "http://schemas.microsoft.com/windows/2004/02/mit/task"
+
+// taskDateFormat is the local time of a start boundary, the task scheduler reads it without a time zone
+const taskDateFormat = "2006-01-02T15:04:05"
+
+var (
+	xmlWeekdays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	xmlMonths   = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	xmlWeeks    = []string{"1", "2", "3", "4", "Last"}
+)
+
+// TaskAction is the program a task runs with its arguments
+type TaskAction struct {
+	Command   string
+	Arguments string
+}
+
+// BackupTaskAction returns the action of the task that backs up a job, GoBackup runs the job itself in command line mode.
+// The command is the GoBackup executable, the running executable when it is empty.
+func BackupTaskAction(command string, job Job) (TaskAction, error) {
+	if len(command) == 0 {
+		exe, err := os.Executable()
+		if err != nil {
+			return TaskAction{}, fmt.Errorf("BackupTaskAction: %w", err)
+		}
+		command = exe
+	}
+	return TaskAction{
+		Command:   command,
+		Arguments: "run -scheduler taskscheduler -job " + windowsQuote(job.ID),
+	}, nil
+}
+
+// windowsQuote quotes an argument of a windows command line, backslashes only need escaping in front of a quote
+func windowsQuote(arg string) string {
+	if len(arg) > 0 && !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for _, c := range arg {
+		switch c {
+		case '\\':
+			slashes++
+		case '"':
+			b.WriteString(strings.Repeat(`\`, slashes+1))
+			slashes = 0
+		default:
+			slashes = 0
+		}
+		b.WriteRune(c)
+	}
+	b.WriteString(strings.Repeat(`\`, slashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// taskXML is a task in the task scheduler XML schema, limited to the parts GoBackup writes
+type taskXML struct {
+	XMLName          xml.Name            `xml:"Task"`
+	Version          string              `xml:"version,attr"`
+	Namespace        string              `xml:"xmlns,attr"`
+	RegistrationInfo xmlRegistrationInfo `xml:"RegistrationInfo"`
+	Triggers         xmlTriggers         `xml:"Triggers"`
+	Principals       xmlPrincipals       `xml:"Principals"`
+	Settings         xmlSettings         `xml:"Settings"`
+	Actions          xmlActions          `xml:"Actions"`
+}
+
+type xmlRegistrationInfo struct {
+	URI           string  `xml:"URI,omitempty"`
+	Documentation xmlText `xml:"Documentation"`
+}
+
+// xmlText is written as CDATA, so the job in the documentation stays readable
+type xmlText struct {
+	Text string `xml:",cdata"`
+}
+
+type xmlTriggers struct {
+	CalendarTriggers []xmlCalendarTrigger `xml:"CalendarTrigger"`
+	// Other holds the triggers GoBackup does not write, such as logon triggers
+	Other []xmlElement `xml:",any"`
+}
+
+type xmlElement struct {
+	XMLName xml.Name
+}
+
+type xmlCalendarTrigger struct {
+	Repetition               *xmlRepetition               `xml:"Repetition"`
+	StartBoundary            string                       `xml:"StartBoundary"`
+	Enabled                  bool                         `xml:"Enabled"`
+	RandomDelay              string                       `xml:"RandomDelay,omitempty"`
+	ScheduleByDay            *xmlScheduleByDay            `xml:"ScheduleByDay"`
+	ScheduleByWeek           *xmlScheduleByWeek           `xml:"ScheduleByWeek"`
+	ScheduleByMonth          *xmlScheduleByMonth          `xml:"ScheduleByMonth"`
+	ScheduleByMonthDayOfWeek *xmlScheduleByMonthDayOfWeek `xml:"ScheduleByMonthDayOfWeek"`
+}
+
+type xmlRepetition struct {
+	Interval          string `xml:"Interval"`
+	Duration          string `xml:"Duration"`
+	StopAtDurationEnd bool   `xml:"StopAtDurationEnd"`
+}
+
+type xmlScheduleByDay struct {
+	DaysInterval int `xml:"DaysInterval"`
+}
+
+type xmlScheduleByWeek struct {
+	DaysOfWeek    xmlNames `xml:"DaysOfWeek"`
+	WeeksInterval int      `xml:"WeeksInterval"`
+}
+
+type xmlScheduleByMonth struct {
+	DaysOfMonth []string `xml:"DaysOfMonth>Day"`
+	Months      xmlNames `xml:"Months"`
+}
+
+type xmlScheduleByMonthDayOfWeek struct {
+	Weeks      []string `xml:"Weeks>Week"`
+	DaysOfWeek xmlNames `xml:"DaysOfWeek"`
+	Months     xmlNames `xml:"Months"`
+}
+
+type xmlPrincipals struct {
+	Principal xmlPrincipal `xml:"Principal"`
+}
+
+type xmlPrincipal struct {
+	ID        string `xml:"id,attr"`
+	LogonType string `xml:"LogonType"`
+	RunLevel  string `xml:"RunLevel"`
+}
+
+type xmlSettings struct {
+	MultipleInstancesPolicy    string `xml:"MultipleInstancesPolicy"`
+	DisallowStartIfOnBatteries bool   `xml:"DisallowStartIfOnBatteries"`
+	StopIfGoingOnBatteries     bool   `xml:"StopIfGoingOnBatteries"`
+	AllowHardTerminate         bool   `xml:"AllowHardTerminate"`
+	AllowStartOnDemand         bool   `xml:"AllowStartOnDemand"`
+	Enabled                    bool   `xml:"Enabled"`
+	WakeToRun                  bool   `xml:"WakeToRun"`
+	ExecutionTimeLimit         string `xml:"ExecutionTimeLimit"`
+	Priority                   int    `xml:"Priority"`
+}
+
+type xmlActions struct {
+	Context string    `xml:"Context,attr"`
+	Exec    []xmlExec `xml:"Exec"`
+}
+
+type xmlExec struct {
+	Command   string `xml:"Command"`
+	Arguments string `xml:"Arguments,omitempty"`
+}
+
+// xmlNames is a list of empty elements named after weekdays or months, such as <Monday></Monday>
+type xmlNames []string
+
+func (n xmlNames) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, name := range n {
+		element := xml.StartElement{Name: xml.Name{Local: name}}
+		if err := e.EncodeToken(element); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(element.End()); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (n *xmlNames) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			*n = append(*n, t.Name.Local)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// ExportTaskXML returns the task the task scheduler runs the job with in its XML schema, as read by schtasks /create /xml.
+// The trigger starts with the next run after now, or after the pause of the job. Unlike registered tasks it never expires.
+func ExportTaskXML(job Job, action TaskAction, enabled bool, now time.Time) ([]byte, error) {
+	if err := localOnly(job.Schedule, "the task scheduler"); err != nil {
+		return nil, err
+	}
+	if job.Schedule.Type == Custom {
+		return nil, &ErrUnsupportedSchedule{Inner: fmt.Errorf("the task XML has no advanced schedules, %q", job.Schedule.Expr), Message: "use a daily, weekly or monthly schedule"}
+	}
+	// A paused job starts once the pause is over, like pauseDefinition
+	from := now
+	if job.PausedUntil.After(now) {
+		from = job.PausedUntil
+	}
+	trigger, err := calendarTrigger(job.Schedule, from)
+	if err != nil {
+		return nil, fmt.Errorf("ExportTaskXML: %w", err)
+	}
+	doc, err := job.encode()
+	if err != nil {
+		return nil, fmt.Errorf("ExportTaskXML: %w", err)
+	}
+	task := taskXML{
+		Version:   "1.2",
+		Namespace: taskNamespace,
+		RegistrationInfo: xmlRegistrationInfo{
+			URI:           fPath + `\` + parseTaskPath(job.Label, job.ID),
+			Documentation: xmlText{doc},
+		},
+		Triggers: xmlTriggers{CalendarTriggers: []xmlCalendarTrigger{trigger}},
+		// The settings of newBackupDefinition
+		Principals: xmlPrincipals{Principal: xmlPrincipal{ID: "Author", LogonType: "S4U", RunLevel: "HighestAvailable"}},
+		Settings: xmlSettings{
+			MultipleInstancesPolicy: "IgnoreNew",
+			AllowStartOnDemand:      true,
+			Enabled:                 enabled,
+			ExecutionTimeLimit:      "PT72H",
+			Priority:                7,
+		},
+		Actions: xmlActions{Context: "Author", Exec: []xmlExec{{Command: action.Command, Arguments: action.Arguments}}},
+	}
+	b, err := xml.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("ExportTaskXML: %w", err)
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// calendarTrigger returns the trigger of a daily, weekly or monthly schedule as createTrigger builds it, without an expiry
+func calendarTrigger(s Schedule, now time.Time) (xmlCalendarTrigger, error) {
+	if err := s.Validate(); err != nil {
+		return xmlCalendarTrigger{}, fmt.Errorf("calendarTrigger: %w", err)
+	}
+	trigger := xmlCalendarTrigger{
+		StartBoundary: startBoundary(now, int(s.Hour), int(s.Minute)).Format(taskDateFormat),
+		Enabled:       true,
+	}
+	if s.Repeats() {
+		times := s.times()
+		// See repetitionPattern, the duration ends half an interval after the last run
+		duration := (times[len(times)-1]-times[0])*60 + int(s.RepeatEvery)*30
+		trigger.Repetition = &xmlRepetition{
+			Interval: period.NewHMS(int(s.RepeatEvery)/60, int(s.RepeatEvery)%60, 0).String(),
+			Duration: period.NewHMS(duration/3600, duration/60%60, duration%60).String(),
+		}
+	}
+	if s.Jitter > 0 {
+		trigger.RandomDelay = period.NewHMS(int(s.Jitter)/60, int(s.Jitter)%60, 0).String()
+	}
+	switch {
+	case s.Type == Daily:
+		trigger.ScheduleByDay = &xmlScheduleByDay{DaysInterval: 1}
+	case s.Type == Weekly:
+		trigger.ScheduleByWeek = &xmlScheduleByWeek{DaysOfWeek: maskNames(uint64(s.weekdaySet()), xmlWeekdays), WeeksInterval: 1}
+	case s.WeeksOfMonth != 0:
+		trigger.ScheduleByMonthDayOfWeek = &xmlScheduleByMonthDayOfWeek{
+			Weeks:      maskNames(uint64(s.WeeksOfMonth), xmlWeeks),
+			DaysOfWeek: maskNames(uint64(s.weekdaySet()), xmlWeekdays),
+			Months:     xmlMonths,
+		}
+	default:
+		var days []string
+		for set := s.daySet(); set != 0; set &= set - 1 {
+			day := bits.TrailingZeros32(set)
+			if uint32(1)<<day == LastDayOfMonth {
+				days = append(days, "Last")
+			} else {
+				days = append(days, strconv.Itoa(day+1))
+			}
+		}
+		trigger.ScheduleByMonth = &xmlScheduleByMonth{DaysOfMonth: days, Months: xmlMonths}
+	}
+	return trigger, nil
+}
+
+// maskNames returns the names of the set bits of a mask, bit 0 is the first name
+func maskNames(mask uint64, names []string) xmlNames {
+	var result xmlNames
+	for i, name := range names {
+		if mask&(1<<i) != 0 {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// namesMask is the reverse of maskNames, it fails on unknown names
+func namesMask(list []string, names []string) (uint64, error) {
+	var mask uint64
+	for _, item := range list {
+		i := 0
+		for i < len(names) && names[i] != item {
+			i++
+		}
+		if i == len(names) {
+			return 0, fmt.Errorf("unknown %q", item)
+		}
+		mask |= 1 << i
+	}
+	return mask, nil
+}
+
+// ImportTaskXML reads the job of a task in the task scheduler XML schema, as written by ExportTaskXML or schtasks /query /xml.
+// The schedule is taken from the trigger as with a registered task, the job keeps its own as long as the trigger still runs on it.
+func ImportTaskXML(data []byte) (job Job, enabled bool, err error) {
+	var task taskXML
+	dec := xml.NewDecoder(bytes.NewReader(utf8Task(data)))
+	// utf8Task has decoded the document, whatever its declaration says
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := dec.Decode(&task); err != nil {
+		return Job{}, false, fmt.Errorf("ImportTaskXML: %w", err)
+	}
+	job, _, err = ParseJob(task.RegistrationInfo.Documentation.Text)
+	if err != nil {
+		return Job{}, false, fmt.Errorf("ImportTaskXML: %w", errors.New("the task was not created by GoBackup"))
+	}
+	if len(task.Triggers.Other) > 0 || len(task.Triggers.CalendarTriggers) != 1 {
+		return Job{}, false, fmt.Errorf("ImportTaskXML: %w", errors.New("the task needs a single calendar trigger"))
+	}
+	schedule, err := parseCalendarTrigger(task.Triggers.CalendarTriggers[0])
+	if err != nil {
+		return Job{}, false, fmt.Errorf("ImportTaskXML: %w", err)
+	}
+	if !sameSchedule(schedule, job.Schedule) || schedule.Jitter != job.Schedule.Jitter {
+		job.Schedule = schedule
+	}
+	return job, task.Settings.Enabled, nil
+}
+
+// utf8Task converts a UTF-16 document with a byte order mark, as schtasks writes it, to UTF-8
+func utf8Task(data []byte) []byte {
+	if len(data) < 2 || !(data[0] == 0xff && data[1] == 0xfe || data[0] == 0xfe && data[1] == 0xff) {
+		return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	}
+	bigEndian := data[0] == 0xfe
+	units := make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// parseCalendarTrigger reads the schedule of a trigger as written by calendarTrigger
+func parseCalendarTrigger(trigger xmlCalendarTrigger) (Schedule, error) {
+	start, err := time.Parse(taskDateFormat, trigger.StartBoundary)
+	if err != nil {
+		// The task scheduler also writes boundaries synchronized across time zones
+		if start, err = time.Parse(time.RFC3339, trigger.StartBoundary); err != nil {
+			return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", err)
+		}
+	}
+	s := Schedule{Hour: uint8(start.Hour()), Minute: uint8(start.Minute())}
+	unsupported := errors.New("unsupported trigger")
+	switch {
+	case trigger.ScheduleByDay != nil:
+		if trigger.ScheduleByDay.DaysInterval != 1 {
+			return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", unsupported)
+		}
+		s.Type = Daily
+	case trigger.ScheduleByWeek != nil:
+		days, err := namesMask(trigger.ScheduleByWeek.DaysOfWeek, xmlWeekdays)
+		if err != nil || days == 0 || trigger.ScheduleByWeek.WeeksInterval != 1 {
+			return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", unsupported)
+		}
+		s.Type, s.DayOfWeek = Weekly, time.Weekday(bits.TrailingZeros64(days))
+		if bits.OnesCount64(days) > 1 {
+			s.Weekdays = uint8(days)
+		}
+	case trigger.ScheduleByMonthDayOfWeek != nil:
+		weeks, errWeeks := namesMask(trigger.ScheduleByMonthDayOfWeek.Weeks, xmlWeeks)
+		days, errDays := namesMask(trigger.ScheduleByMonthDayOfWeek.DaysOfWeek, xmlWeekdays)
+		months, errMonths := namesMask(trigger.ScheduleByMonthDayOfWeek.Months, xmlMonths)
+		if errWeeks != nil || errDays != nil || errMonths != nil || months != 1<<12-1 {
+			return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", unsupported)
+		}
+		s.Type, s.DayOfWeek, s.Weekdays, s.WeeksOfMonth = Monthly, time.Weekday(bits.TrailingZeros64(days)), uint8(days), uint8(weeks)
+	case trigger.ScheduleByMonth != nil:
+		months, err := namesMask(trigger.ScheduleByMonth.Months, xmlMonths)
+		if err != nil || months != 1<<12-1 {
+			return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", unsupported)
+		}
+		var days uint32
+		for _, day := range trigger.ScheduleByMonth.DaysOfMonth {
+			if day == "Last" {
+				days |= LastDayOfMonth
+				continue
+			}
+			d, err := strconv.ParseUint(day, 10, 8)
+			if err != nil || d < 1 || d > 31 {
+				return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", fmt.Errorf("invalid day %q", day))
+			}
+			days |= 1 << (d - 1)
+		}
+		s.Type, s.DayOfMonth = Monthly, uint8(bits.TrailingZeros32(days))+1
+		if bits.OnesCount32(days) > 1 || days&LastDayOfMonth != 0 {
+			s.DaysOfMonth = days
+		}
+	default:
+		return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", unsupported)
+	}
+	if trigger.Repetition != nil && trigger.Repetition.Interval != "" {
+		every, errEvery := xmlDuration(trigger.Repetition.Interval)
+		duration, errDuration := xmlDuration(trigger.Repetition.Duration)
+		if errEvery != nil || errDuration != nil || every < time.Minute || duration < every {
+			return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", errors.New("invalid repetition"))
+		}
+		// The last run is the last one before the end of the duration
+		runs := (duration - 1) / every
+		s.RepeatEvery = uint16(every / time.Minute)
+		s.RepeatUntil = uint16(s.start() + int(runs)*int(s.RepeatEvery))
+	}
+	if trigger.RandomDelay != "" {
+		jitter, err := xmlDuration(trigger.RandomDelay)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", err)
+		}
+		s.Jitter = uint16(jitter / time.Minute)
+	}
+	if err := s.Validate(); err != nil {
+		return Schedule{}, fmt.Errorf("parseCalendarTrigger: %w", err)
+	}
+	return s, nil
+}
+
+// xmlDuration reads an XML duration such as PT1H30M
+func xmlDuration(s string) (time.Duration, error) {
+	p, err := period.Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	d, _ := p.Duration()
+	return d, nil
+}
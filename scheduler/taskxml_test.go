@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func TestExportTaskXML(t *testing.T) {
+	now := time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC) // a Saturday
+	action := TaskAction{Command: `C:\Program Files\GoBackup\GoBackup.exe`, Arguments: `run -scheduler taskscheduler -job 6f1c2a4e-8b3d-4e5f-9a0b-1c2d3e4f5a6b`}
+	testcases := []struct {
+		name     string
+		schedule Schedule
+		enabled  bool
+	}{
+		{"daily.xml", Schedule{Type: Daily, Hour: 17}, true},
+		{"weekly.xml", Schedule{Type: Weekly, DayOfWeek: time.Monday, Weekdays: 1<<time.Monday | 1<<time.Friday, Hour: 9, Minute: 15, RepeatEvery: 45, RepeatUntil: 16*60 + 45, Jitter: 10}, true},
+		{"monthly.xml", Schedule{Type: Monthly, DayOfMonth: 1, DaysOfMonth: 1 | 1<<14 | LastDayOfMonth, Hour: 23, Minute: 30}, false},
+		{"monthlydow.xml", Schedule{Type: Monthly, DayOfWeek: time.Friday, Weekdays: 1 << time.Friday, WeeksOfMonth: 1 | LastWeekOfMonth, Hour: 8, Jitter: 90}, true},
+	}
+	for _, tc := range testcases {
+		job := Job{Version: 1, ID: "6f1c2a4e-8b3d-4e5f-9a0b-1c2d3e4f5a6b", Label: "Tom & Jerry's <files>", Src: `C:\Users\tom\Tom & Jerry's <files>`, Dest: `E:\Backup`, BackupLimit: 3, Schedule: tc.schedule}
+		result, err := ExportTaskXML(job, action, tc.enabled, now)
+		if err != nil {
+			t.Fatalf(`ExportTaskXML(%+v) returned error %v`, job, err)
+		}
+		golden(t, filepath.Join("taskxml", tc.name), string(result))
+
+		parsed, enabled, err := ImportTaskXML(result)
+		if err != nil || !reflect.DeepEqual(parsed, job) || enabled != tc.enabled {
+			t.Errorf(`ImportTaskXML(ExportTaskXML(%+v)) = %+v, %v, %v want the same job`, job, parsed, enabled, err)
+		}
+	}
+
+	for _, schedule := range []Schedule{
+		{Type: Custom, Expr: "0 9 * * 1-5"},
+		{Type: Daily, Hour: 9, TimeZone: "Asia/Tokyo"},
+	} {
+		job := NewJob(3, `C:\test`, `E:\Backup`, false)
+		job.Schedule = schedule
+		if _, err := ExportTaskXML(job, action, true, now); err == nil {
+			t.Errorf(`ExportTaskXML(%+v) did not return an error`, job)
+		} else if _, ok := err.(*ErrUnsupportedSchedule); !ok {
+			t.Errorf(`ExportTaskXML(%+v) = %v, want ErrUnsupportedSchedule`, job, err)
+		}
+	}
+}
+
+func TestExportTaskXMLPaused(t *testing.T) {
+	now := time.Date(2022, 4, 2, 17, 0, 0, 0, time.UTC)
+	job := NewJob(3, `C:\test`, `E:\Backup`, false)
+	job.Schedule = Schedule{Type: Daily, Hour: 9}
+	job.PausedUntil = time.Date(2022, 4, 10, 0, 0, 0, 0, time.UTC)
+	result, err := ExportTaskXML(job, TaskAction{Command: "GoBackup.exe"}, true, now)
+	if err != nil || !strings.Contains(string(result), "<StartBoundary>2022-04-10T09:00:00</StartBoundary>") {
+		t.Errorf(`ExportTaskXML(%+v) = %s, %v want a start after the pause`, job, result, err)
+	}
+}
+
+func TestImportTaskXML(t *testing.T) {
+	doc := `{"version":1,"id":"6f1c2a4e-8b3d-4e5f-9a0b-1c2d3e4f5a6b","label":"test","src":"C:\\test","dest":"E:\\Backup","backupLimit":3,"overwrite":false,"schedule":{"type":"daily","dayOfWeek":0,"dayOfMonth":0,"hour":17},"pausedUntil":"0001-01-01T00:00:00Z"}`
+	task := func(registration, triggers string) string {
+		return `<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.4" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>` + registration + `</RegistrationInfo>
+  <Triggers>` + triggers + `</Triggers>
+  <Settings><Enabled>true</Enabled></Settings>
+  <Actions Context="Author"><Exec><Command>GoBackup.exe</Command></Exec></Actions>
+</Task>`
+	}
+	daily := `<CalendarTrigger><StartBoundary>2022-04-02T17:00:00</StartBoundary><Enabled>true</Enabled><ScheduleByDay><DaysInterval>1</DaysInterval></ScheduleByDay></CalendarTrigger>`
+	testcases := []struct {
+		name      string
+		xml       string
+		want      Schedule
+		wantError bool
+	}{
+		{"metadata schedule", task("<Documentation>"+doc+"</Documentation>", daily), Schedule{Type: Daily, Hour: 17}, false},
+		// An admin changed the trigger, as with a registered task the trigger wins
+		{"changed trigger", task("<Documentation>"+doc+"</Documentation>", `<CalendarTrigger>
+			<Repetition><Interval>PT2H</Interval><Duration>PT8H</Duration><StopAtDurationEnd>false</StopAtDurationEnd></Repetition>
+			<StartBoundary>2022-04-02T08:30:00+02:00</StartBoundary><Enabled>true</Enabled>
+			<ScheduleByWeek><DaysOfWeek><Tuesday /><Thursday /></DaysOfWeek><WeeksInterval>1</WeeksInterval></ScheduleByWeek>
+		</CalendarTrigger>`), Schedule{Type: Weekly, DayOfWeek: time.Tuesday, Weekdays: 1<<time.Tuesday | 1<<time.Thursday, Hour: 8, Minute: 30, RepeatEvery: 120, RepeatUntil: 14*60 + 30}, false},
+		{"monthly in some months", task("<Documentation>"+doc+"</Documentation>", `<CalendarTrigger><StartBoundary>2022-04-02T17:00:00</StartBoundary>
+			<ScheduleByMonth><DaysOfMonth><Day>1</Day></DaysOfMonth><Months><January /></Months></ScheduleByMonth></CalendarTrigger>`), Schedule{}, true},
+		{"every other day", task("<Documentation>"+doc+"</Documentation>", strings.Replace(daily, "<DaysInterval>1<", "<DaysInterval>2<", 1)), Schedule{}, true},
+		{"logon trigger", task("<Documentation>"+doc+"</Documentation>", daily+"<LogonTrigger><Enabled>true</Enabled></LogonTrigger>"), Schedule{}, true},
+		{"no GoBackup task", task("<Author>admin</Author>", daily), Schedule{}, true},
+		{"no trigger", task("<Documentation>"+doc+"</Documentation>", ""), Schedule{}, true},
+		{"not XML", "Task", Schedule{}, true},
+	}
+	for _, tc := range testcases {
+		job, enabled, err := ImportTaskXML([]byte(tc.xml))
+		if tc.wantError {
+			if err == nil {
+				t.Errorf(`%v: ImportTaskXML() = %+v, did not return an error`, tc.name, job)
+			}
+			continue
+		}
+		if err != nil || job.Schedule != tc.want || job.Src != `C:\test` || !enabled {
+			t.Errorf(`%v: ImportTaskXML() = %+v, %v, %v want schedule %+v`, tc.name, job, enabled, err, tc.want)
+		}
+	}
+}
+
+func TestImportTaskXMLUTF16(t *testing.T) {
+	job := NewJob(3, `C:\Users\tom\Bilder ü`, `E:\Backup`, false)
+	job.Schedule = Schedule{Type: Weekly, DayOfWeek: time.Sunday, Hour: 3}
+	result, err := ExportTaskXML(job, TaskAction{Command: "GoBackup.exe"}, true, time.Now())
+	if err != nil {
+		t.Fatalf(`ExportTaskXML(%+v) returned error %v`, job, err)
+	}
+	// schtasks /query /xml writes UTF-16 with a byte order mark
+	doc := strings.Replace(string(result), `encoding="UTF-8"`, `encoding="UTF-16"`, 1)
+	utf16le := []byte{0xff, 0xfe}
+	for _, u := range utf16.Encode([]rune(doc)) {
+		utf16le = append(utf16le, byte(u), byte(u>>8))
+	}
+	if parsed, _, err := ImportTaskXML(utf16le); err != nil || !reflect.DeepEqual(parsed, job) {
+		t.Errorf(`ImportTaskXML(UTF-16 of %+v) = %+v, %v want the same job`, job, parsed, err)
+	}
+}
+
+func TestWindowsQuote(t *testing.T) {
+	testcases := []struct {
+		arg, want string
+	}{
+		{"6f1c2a4e-8b3d-4e5f-9a0b-1c2d3e4f5a6b", "6f1c2a4e-8b3d-4e5f-9a0b-1c2d3e4f5a6b"},
+		{"", `""`},
+		{`C:\Backups`, `C:\Backups`},
+		{`C:\My Backups\`, `"C:\My Backups\\"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`a\"b c`, `"a\\\"b c"`},
+	}
+	for _, tc := range testcases {
+		if result := windowsQuote(tc.arg); result != tc.want {
+			t.Errorf(`windowsQuote(%v) = %v, want %v`, tc.arg, result, tc.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kinds of volume placeholders, a path such as {Label:BACKUP}\GoBackup or {UUID:1234-ABCD}/GoBackup starts on the
+// volume wherever it is mounted, e.g. on another drive letter than last time
+const (
+	VolumeLabel = "Label"
+	VolumeUUID  = "UUID"
+)
+
+// findVolume returns the root folder of the connected volume with the given label or UUID, tests replace it.
+// A volume that is not connected is an ErrVolumeNotFound.
+var findVolume = findSystemVolume
+
+// onVolume reports whether a path contains a volume placeholder such as {Label:BACKUP}
+func onVolume(path string) bool {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(path, -1) {
+		if len(m[3]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func volumeNotFound(kind, id string, inner error) error {
+	if inner == nil {
+		inner = errors.New("not connected")
+	}
+	message := fmt.Sprintf("volume %q is not connected", id)
+	if kind == VolumeUUID {
+		message = fmt.Sprintf("volume with UUID %v is not connected", id)
+	}
+	return &ErrVolumeNotFound{Inner: fmt.Errorf("find volume %v: %w", id, inner), Message: message}
+}
+
+// sameVolumeID compares two volume UUIDs regardless of case and dashes. Windows only knows the lower 32 bits of the
+// 64 bit serial number of NTFS, so 1234-ABCD is the same volume as 012345671234ABCD.
+func sameVolumeID(a, b string) bool {
+	normalize := func(id string) string {
+		return strings.ToUpper(strings.ReplaceAll(id, "-", ""))
+	}
+	a, b = normalize(a), normalize(b)
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	return a == b || len(a) == 16 && len(b) == 8 && strings.HasSuffix(a, b)
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// linuxVolumes finds volumes through the links udev keeps in /dev/disk and the mount table of the kernel.
+// Root is / on a real system and a folder with the same layout in tests.
+type linuxVolumes struct {
+	root string
+}
+
+func findSystemVolume(kind, id string) (string, error) {
+	return linuxVolumes{root: "/"}.find(kind, id)
+}
+
+// find returns the mount point of the device that is linked as the label or UUID in /dev/disk/by-label or by-uuid
+func (v linuxVolumes) find(kind, id string) (string, error) {
+	dir := "/dev/disk/by-uuid"
+	if kind == VolumeLabel {
+		dir = "/dev/disk/by-label"
+	}
+	// Without any volume of the kind the folder does not exist either
+	entries, _ := os.ReadDir(filepath.Join(v.root, dir))
+	device := ""
+	for _, entry := range entries {
+		name := unescapeUdev(entry.Name())
+		if kind == VolumeLabel && strings.EqualFold(name, id) || kind == VolumeUUID && sameVolumeID(name, id) {
+			device = v.resolve(filepath.Join(dir, entry.Name()))
+			break
+		}
+	}
+	if len(device) == 0 {
+		return "", volumeNotFound(kind, id, nil)
+	}
+
+	mounts, err := os.ReadFile(filepath.Join(v.root, "proc", "self", "mounts"))
+	if err != nil {
+		return "", fmt.Errorf("find: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(mounts))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/") {
+			continue
+		}
+		if v.resolve(unescapeMount(fields[0])) == device {
+			return unescapeMount(fields[1]), nil
+		}
+	}
+	return "", volumeNotFound(kind, id, errors.New("connected but not mounted"))
+}
+
+// resolve follows the links of a device such as /dev/disk/by-uuid/1234-ABCD or /dev/mapper/data to the device itself
+func (v linuxVolumes) resolve(path string) string {
+	for i := 0; i < 8; i++ {
+		link, err := os.Readlink(filepath.Join(v.root, path))
+		if err != nil {
+			break
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = filepath.Clean(link)
+	}
+	return path
+}
+
+// unescapeUdev decodes the \xNN escapes of the names in /dev/disk, such as BACKUP\x20DISK
+func unescapeUdev(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) && name[i+1] == 'x' {
+			if c, err := strconv.ParseUint(name[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// unescapeMount decodes the octal escapes of the mount table, such as /media/ann/BACKUP\040DISK
+func unescapeMount(field string) string {
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinuxVolumes(t *testing.T) {
+	v := linuxVolumes{root: filepath.Join("testdata", "volumes")}
+	testcases := []struct {
+		kind, id    string
+		want        string
+		wantMissing bool
+	}{
+		{VolumeLabel, "BACKUP", "/media/ann/BACKUP DISK", false},
+		{VolumeLabel, "backup", "/media/ann/BACKUP DISK", false},
+		{VolumeUUID, "1234-abcd", "/media/ann/BACKUP DISK", false},
+		{VolumeLabel, "OFFSITE", "/mnt/offsite", false},
+		{VolumeUUID, "0A1B2C3D-4E5F-6071-8293-A4B5C6D7E8F9", "/mnt/offsite", false},
+		// Mounted through a link in /dev/mapper
+		{VolumeUUID, "d1e2f3a4-0000-1111-2222-333344445555", "/mnt/vault", false},
+		// Connected but not mounted
+		{VolumeLabel, "SPARE", "", true},
+		{VolumeUUID, "89AB-CDEF", "", true},
+		{VolumeLabel, "MISSING", "", true},
+		{VolumeUUID, "FFFF-0000", "", true},
+	}
+	for _, tc := range testcases {
+		result, err := v.find(tc.kind, tc.id)
+		var volumeErr *ErrVolumeNotFound
+		if result != tc.want || errors.As(err, &volumeErr) != tc.wantMissing || err != nil && !tc.wantMissing {
+			t.Errorf(`find(%v, %v) = %v, %v want %v, missing %v`, tc.kind, tc.id, result, err, tc.want, tc.wantMissing)
+		}
+	}
+
+	// Without /dev/disk no volume is connected
+	if _, err := (linuxVolumes{root: t.TempDir()}).find(VolumeLabel, "BACKUP"); !errors.As(err, new(*ErrVolumeNotFound)) {
+		t.Errorf(`find(Label, BACKUP) on an empty root = %v, want ErrVolumeNotFound`, err)
+	}
+}
+
+func TestUnescapeMount(t *testing.T) {
+	testcases := []struct {
+		field, want string
+	}{
+		{`/media/ann/BACKUP\040DISK`, "/media/ann/BACKUP DISK"},
+		{`/mnt/tab\011and\134backslash`, "/mnt/tab\tand\\backslash"},
+		{`/mnt/plain`, "/mnt/plain"},
+		{`/mnt/end\04`, `/mnt/end\04`},
+	}
+	for _, tc := range testcases {
+		if result := unescapeMount(tc.field); result != tc.want {
+			t.Errorf(`unescapeMount(%v) = %q, want %q`, tc.field, result, tc.want)
+		}
+	}
+	if result := unescapeUdev(`BACKUP\x20DISK\x2`); result != `BACKUP DISK\x2` {
+		t.Errorf(`unescapeUdev(...) = %q, want %q`, result, `BACKUP DISK\x2`)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !linux
+
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// findSystemVolume returns the folder a volume with the given label is mounted on in /Volumes, as macOS does
+func findSystemVolume(kind, id string) (string, error) {
+	if kind != VolumeLabel {
+		return "", fmt.Errorf("findSystemVolume: volume UUIDs are only supported on windows and linux, use {%v:...}", VolumeLabel)
+	}
+	dir := filepath.Join("/Volumes", id)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", volumeNotFound(kind, id, nil)
+	}
+	return dir, nil
+}
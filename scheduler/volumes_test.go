@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// stubVolumes replaces the volumes of the system by the given mount points by label, for the duration of the test
+func stubVolumes(t *testing.T, mounted map[string]string) {
+	findVolume = func(kind, id string) (string, error) {
+		if root, ok := mounted[id]; ok && kind == VolumeLabel {
+			return root, nil
+		}
+		return "", volumeNotFound(kind, id, nil)
+	}
+	t.Cleanup(func() { findVolume = findSystemVolume })
+}
+
+func TestSameVolumeID(t *testing.T) {
+	testcases := []struct {
+		a, b string
+		want bool
+	}{
+		{"1234-ABCD", "1234-abcd", true},
+		{"1234ABCD", "1234-ABCD", true},
+		{"0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9", "0A1B2C3D-4E5F-6071-8293-A4B5C6D7E8F9", true},
+		// The serial number Windows shows for an NTFS volume
+		{"0123456789ABCDEF", "89AB-CDEF", true},
+		{"89AB-CDEF", "0123456789ABCDEF", true},
+		{"1234-ABCD", "1234-ABCE", false},
+		{"0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9", "C6D7E8F9", false},
+	}
+	for _, tc := range testcases {
+		if result := sameVolumeID(tc.a, tc.b); result != tc.want {
+			t.Errorf(`sameVolumeID(%v, %v) = %v, want %v`, tc.a, tc.b, result, tc.want)
+		}
+	}
+}
+
+func TestExpandVolume(t *testing.T) {
+	root := t.TempDir()
+	stubVolumes(t, map[string]string{"BACKUP": root})
+
+	if result, err := ExpandPath("{Label:BACKUP}/GoBackup"); err != nil || result != filepath.Join(root, "GoBackup") {
+		t.Errorf(`ExpandPath({Label:BACKUP}/GoBackup) = %v, %v want %v`, result, err, filepath.Join(root, "GoBackup"))
+	}
+	_, err := ExpandPath("{label:OFFSITE}/GoBackup")
+	var volumeErr *ErrVolumeNotFound
+	if !errors.As(err, &volumeErr) || volumeErr.Message != `volume "OFFSITE" is not connected` {
+		t.Errorf(`ExpandPath({label:OFFSITE}/GoBackup) = %v, want ErrVolumeNotFound`, err)
+	}
+	// Other kinds are part of the path
+	if result, err := ExpandPath("{Drive:BACKUP}/GoBackup"); err != nil || result != "{Drive:BACKUP}/GoBackup" {
+		t.Errorf(`ExpandPath({Drive:BACKUP}/GoBackup) = %v, %v want the path as it is`, result, err)
+	}
+}
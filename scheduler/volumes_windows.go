@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// findSystemVolume returns the root of the drive letter the volume with the given label or serial number is mounted on
+func findSystemVolume(kind, id string) (string, error) {
+	drives, err := windows.GetLogicalDrives()
+	if err != nil {
+		return "", fmt.Errorf("findSystemVolume: %w", err)
+	}
+	for i := 0; i < 26; i++ {
+		if drives&(1<<i) == 0 {
+			continue
+		}
+		root := string(rune('A'+i)) + `:\`
+		var name [windows.MAX_PATH + 1]uint16
+		var serial uint32
+		// Drives without a medium, such as an empty card reader, have no volume information
+		if err := windows.GetVolumeInformation(windows.StringToUTF16Ptr(root), &name[0], uint32(len(name)), &serial, nil, nil, nil, 0); err != nil {
+			continue
+		}
+		label := windows.UTF16ToString(name[:])
+		if kind == VolumeLabel && strings.EqualFold(label, id) || kind == VolumeUUID && sameVolumeID(fmt.Sprintf("%04X-%04X", serial>>16, serial&0xffff), id) {
+			return root, nil
+		}
+	}
+	return "", volumeNotFound(kind, id, nil)
+}